@@ -2,14 +2,19 @@ package gosprout
 
 import (
 	"archive/zip"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-// ZipFiles compresses one or many files into a single zip archive file.
+// ZipFiles compresses one or many files into a single zip archive file. The names are stored as they are given;
+// see WriteArchive for an archive of a directory with relative names.
 func ZipFiles(src []string, dest string) error {
 	newZipFile, err := os.Create(dest)
 	if err != nil {
@@ -17,15 +22,23 @@ func ZipFiles(src []string, dest string) error {
 	}
 	defer newZipFile.Close()
 
-	zipWriter := zip.NewWriter(newZipFile)
-	defer zipWriter.Close()
+	if err = ZipFilesTo(newZipFile, src); err != nil {
+		return err
+	}
+	return newZipFile.Close()
+}
 
+// ZipFilesTo is the same as ZipFiles, but writes the archive to w, e.g. a storage object writer or the body of an
+// upload, so no temporary file is needed.
+func ZipFilesTo(w io.Writer, src []string) error {
+	zipWriter := zip.NewWriter(w)
 	for _, file := range src {
-		if err = addFileToZip(zipWriter, file); err != nil {
+		if err := addFileToZip(zipWriter, file); err != nil {
+			zipWriter.Close()
 			return err
 		}
 	}
-	return nil
+	return zipWriter.Close()
 }
 
 func addFileToZip(zipWriter *zip.Writer, filename string) error {
@@ -57,53 +70,376 @@ func addFileToZip(zipWriter *zip.Writer, filename string) error {
 	return err
 }
 
-// Unzip an archive to multiple files
+var (
+	IllegalPathError      = errors.New("[gosprout] zip entry has an illegal file path")
+	SymlinkError          = errors.New("[gosprout] zip entry is a symlink which is not allowed")
+	TooManyFilesError     = errors.New("[gosprout] zip has too many entries")
+	FileTooLargeError     = errors.New("[gosprout] zip entry exceeds the maximum file size")
+	ArchiveTooLargeError  = errors.New("[gosprout] zip exceeds the maximum total size")
+	CompressionRatioError = errors.New("[gosprout] zip entry exceeds the maximum compression ratio")
+
+	defaultUnzipMaxFiles     = 10000
+	defaultUnzipMaxTotalSize = int64(1 << 30)
+	defaultUnzipMaxRatio     = int64(200)
+	unzipRatioMinSize        = int64(1 << 20)
+	maxSymlinkTargetSize     = int64(4096)
+)
+
+// SymlinkPolicy is how Unzip handles symlink entries.
+type SymlinkPolicy int
+
+const (
+	// RejectSymlinks fails the Unzip on a symlink entry.
+	RejectSymlinks SymlinkPolicy = iota
+	// AllowSymlinksInDest creates symlinks whose target, once resolved, is inside dest, and fails on any other.
+	AllowSymlinksInDest
+)
+
+// UnzipOptions are the limits of an Unzip. The zero value of each limit uses its default, and a negative value
+// means there is no limit.
+type UnzipOptions struct {
+	// MaxFiles is the maximum number of entries, including directories. It defaults to 10000.
+	MaxFiles int
+	// MaxFileSize is the maximum uncompressed size of a single entry. It defaults to MaxTotalSize.
+	MaxFileSize int64
+	// MaxTotalSize is the maximum uncompressed size of all the entries. It defaults to 1 GiB.
+	MaxTotalSize int64
+	// MaxRatio is the maximum ratio between the uncompressed and compressed size of an entry. It defaults to 200.
+	// Entries smaller than 1 MiB are not checked, since small files of repeated content legitimately compress well.
+	MaxRatio int64
+	Symlinks SymlinkPolicy
+}
+
+// UnzipError is returned when an entry cannot be extracted. It wraps one of the errors of this package, like
+// FileTooLargeError, or the underlying I/O error.
+type UnzipError struct {
+	Entry string
+	Err   error
+}
+
+func (e *UnzipError) Error() string {
+	return fmt.Sprintf("%v (entry %q)", e.Err, e.Entry)
+}
+
+func (e *UnzipError) Unwrap() error {
+	return e.Err
+}
+
+// Unzip an archive to multiple files, with the default UnzipOptions. The files are written directly into dest, so
+// see DeployArchive to replace a directory which is in use.
 func Unzip(src, dest string) ([]string, error) {
-	var filenames []string
+	return UnzipWithOptions(src, dest, UnzipOptions{})
+}
 
+// UnzipWithOptions unzips an archive to multiple files within the limits of the options. The sizes in the entry
+// headers are checked before extracting anything, and the actual sizes are checked while extracting, since the
+// headers cannot be trusted. Modification times are preserved, and symlinks are created after every other entry
+// so no file is written through one. The names of the files created so far are returned, even on error.
+func UnzipWithOptions(src, dest string, opts UnzipOptions) ([]string, error) {
 	r, err := zip.OpenReader(src)
 	if err != nil {
-		return filenames, err
+		return nil, err
 	}
 	defer r.Close()
+	return unzip(&r.Reader, dest, opts)
+}
 
-	for _, f := range r.File {
-		fpath := filepath.Join(dest, f.Name)
+// UnzipFrom is the same as UnzipWithOptions, for a zip archive of the given size read from r.
+func UnzipFrom(r io.ReaderAt, size int64, dest string, opts UnzipOptions) ([]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return unzip(zr, dest, opts)
+}
 
-		// Check for ZipSlip. More Info: http://bit.ly/2MsjAWE
-		if !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
-			return filenames, fmt.Errorf("%s: illegal file path", fpath)
-		}
+// UnzipStream is the same as UnzipWithOptions, for a zip archive read from r, like the reader provided on
+// Refresh. A zip archive can only be read with random access, so unless r is an io.ReaderAt with a Size method
+// (like *bytes.Reader), it is read into memory first, and an archive bigger than MaxTotalSize is rejected with
+// ArchiveTooLargeError.
+func UnzipStream(r io.Reader, dest string, opts UnzipOptions) ([]string, error) {
+	if ra, ok := r.(interface {
+		io.ReaderAt
+		Size() int64
+	}); ok {
+		return UnzipFrom(ra, ra.Size(), dest, opts)
+	}
 
-		filenames = append(filenames, fpath)
+	limit := opts.withDefaults().MaxTotalSize
+	if limit < 0 {
+		limit = math.MaxInt64 - 1
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ArchiveTooLargeError
+	}
+	return UnzipFrom(bytes.NewReader(data), int64(len(data)), dest, opts)
+}
 
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(fpath, os.ModePerm)
-			continue
-		}
+func unzip(r *zip.Reader, dest string, opts UnzipOptions) ([]string, error) {
+	var filenames []string
+	opts = opts.withDefaults()
 
-		if err = os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+	if opts.MaxFiles >= 0 && len(r.File) > opts.MaxFiles {
+		return filenames, TooManyFilesError
+	}
+	var declared uint64
+	for _, f := range r.File {
+		if err := opts.checkSize(f, f.UncompressedSize64); err != nil {
 			return filenames, err
 		}
+		declared += f.UncompressedSize64
+		if opts.MaxTotalSize >= 0 && declared > uint64(opts.MaxTotalSize) {
+			return filenames, &UnzipError{Entry: f.Name, Err: ArchiveTooLargeError}
+		}
+	}
 
-		outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+	dest = filepath.Clean(dest)
+	var dirs, links []*zip.File
+	var total int64
+	for _, f := range r.File {
+		fpath, err := entryPath(dest, f.Name)
 		if err != nil {
 			return filenames, err
 		}
 
-		rc, err := f.Open()
+		switch {
+		case f.Mode()&os.ModeSymlink != 0:
+			if opts.Symlinks != AllowSymlinksInDest {
+				return filenames, &UnzipError{Entry: f.Name, Err: SymlinkError}
+			}
+			links = append(links, f)
+			continue
+		case f.FileInfo().IsDir():
+			filenames = append(filenames, fpath)
+			if err := os.MkdirAll(fpath, os.ModePerm); err != nil {
+				return filenames, &UnzipError{Entry: f.Name, Err: err}
+			}
+			dirs = append(dirs, f)
+			continue
+		case !f.Mode().IsRegular():
+			return filenames, &UnzipError{Entry: f.Name, Err: fmt.Errorf("unsupported file mode %v", f.Mode())}
+		}
+
+		filenames = append(filenames, fpath)
+		n, err := extractFile(f, fpath, opts, total)
+		total += n
 		if err != nil {
 			return filenames, err
 		}
+	}
 
-		_, err = io.Copy(outFile, rc)
-
-		outFile.Close()
-		rc.Close()
-
-		if err != nil {
+	var created []string
+	for _, f := range links {
+		fpath, _ := entryPath(dest, f.Name)
+		if err := extractSymlink(f, fpath, dest); err != nil {
+			removeAll(created)
 			return filenames, err
 		}
+		created = append(created, fpath)
+	}
+	// A link created later can change where an earlier one resolves, so each is checked again against the
+	// finished tree. Nothing pointing outside dest is left behind.
+	for i, fpath := range created {
+		if err := checkSymlink(fpath, dest); err != nil {
+			removeAll(created)
+			return filenames, &UnzipError{Entry: links[i].Name, Err: err}
+		}
+	}
+	filenames = append(filenames, created...)
+
+	// Directory times are set last, since creating the files in them changes their modification time.
+	for _, f := range dirs {
+		fpath, _ := entryPath(dest, f.Name)
+		if err := os.Chtimes(fpath, f.Modified, f.Modified); err != nil {
+			return filenames, &UnzipError{Entry: f.Name, Err: err}
+		}
 	}
 	return filenames, nil
 }
+
+func (o UnzipOptions) withDefaults() UnzipOptions {
+	if o.MaxFiles == 0 {
+		o.MaxFiles = defaultUnzipMaxFiles
+	}
+	if o.MaxTotalSize == 0 {
+		o.MaxTotalSize = defaultUnzipMaxTotalSize
+	}
+	if o.MaxFileSize == 0 {
+		o.MaxFileSize = o.MaxTotalSize
+	}
+	if o.MaxRatio == 0 {
+		o.MaxRatio = defaultUnzipMaxRatio
+	}
+	return o
+}
+
+// checkSize checks an uncompressed size of the entry against the file size and compression ratio limits.
+func (o UnzipOptions) checkSize(f *zip.File, size uint64) error {
+	if o.MaxFileSize >= 0 && size > uint64(o.MaxFileSize) {
+		return &UnzipError{Entry: f.Name, Err: FileTooLargeError}
+	}
+	if o.MaxRatio >= 0 && size > uint64(unzipRatioMinSize) &&
+		(f.CompressedSize64 == 0 || size/f.CompressedSize64 > uint64(o.MaxRatio)) {
+		return &UnzipError{Entry: f.Name, Err: CompressionRatioError}
+	}
+	return nil
+}
+
+// entryPath returns the path of the entry in dest. Check for ZipSlip. More Info: http://bit.ly/2MsjAWE
+func entryPath(dest, name string) (string, error) {
+	fpath := filepath.Join(dest, name)
+	if !strings.HasPrefix(fpath, dest+string(os.PathSeparator)) {
+		return "", &UnzipError{Entry: name, Err: IllegalPathError}
+	}
+	return fpath, nil
+}
+
+// extractFile writes a regular file, stopping as soon as it goes over a limit. It returns the number of bytes
+// written.
+func extractFile(f *zip.File, fpath string, opts UnzipOptions, total int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+		return 0, &UnzipError{Entry: f.Name, Err: err}
+	}
+	outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode().Perm())
+	if err != nil {
+		return 0, &UnzipError{Entry: f.Name, Err: err}
+	}
+	defer outFile.Close()
+	rc, err := f.Open()
+	if err != nil {
+		return 0, &UnzipError{Entry: f.Name, Err: err}
+	}
+	defer rc.Close()
+
+	limit := int64(math.MaxInt64 - 1)
+	if opts.MaxFileSize >= 0 {
+		limit = opts.MaxFileSize
+	}
+	if opts.MaxTotalSize >= 0 && opts.MaxTotalSize-total < limit {
+		limit = opts.MaxTotalSize - total
+	}
+	n, err := io.Copy(outFile, io.LimitReader(rc, limit+1))
+	if err != nil {
+		return n, &UnzipError{Entry: f.Name, Err: err}
+	}
+	if err := opts.checkSize(f, uint64(n)); err != nil {
+		return n, err
+	}
+	if n > limit {
+		return n, &UnzipError{Entry: f.Name, Err: ArchiveTooLargeError}
+	}
+	if err := outFile.Close(); err != nil {
+		return n, &UnzipError{Entry: f.Name, Err: err}
+	}
+	if err := os.Chtimes(fpath, f.Modified, f.Modified); err != nil {
+		return n, &UnzipError{Entry: f.Name, Err: err}
+	}
+	return n, nil
+}
+
+// extractSymlink creates a symlink whose target must resolve to a path inside dest, following any symlink
+// already created.
+func extractSymlink(f *zip.File, fpath, dest string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return &UnzipError{Entry: f.Name, Err: err}
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(rc, maxSymlinkTargetSize))
+	rc.Close()
+	if err != nil {
+		return &UnzipError{Entry: f.Name, Err: err}
+	}
+	target := string(b)
+	if filepath.IsAbs(target) {
+		return &UnzipError{Entry: f.Name, Err: SymlinkError}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+		return &UnzipError{Entry: f.Name, Err: err}
+	}
+	root, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return &UnzipError{Entry: f.Name, Err: err}
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(fpath))
+	if err != nil {
+		return &UnzipError{Entry: f.Name, Err: err}
+	}
+	resolved, err := resolvePhysical(dir, target)
+	if err != nil {
+		return &UnzipError{Entry: f.Name, Err: err}
+	}
+	if resolved != root && !strings.HasPrefix(resolved, root+string(os.PathSeparator)) {
+		return &UnzipError{Entry: f.Name, Err: SymlinkError}
+	}
+	if err := os.Symlink(target, fpath); err != nil {
+		return &UnzipError{Entry: f.Name, Err: err}
+	}
+	return nil
+}
+
+// checkSymlink returns SymlinkError if the symlink resolves outside dest, following every link now in place. A
+// link whose target does not exist is resolved as far as it exists.
+func checkSymlink(fpath, dest string) error {
+	root, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(fpath)
+	if os.IsNotExist(err) {
+		var target, dir string
+		if target, err = os.Readlink(fpath); err != nil {
+			return err
+		}
+		if dir, err = filepath.EvalSymlinks(filepath.Dir(fpath)); err != nil {
+			return err
+		}
+		if resolved, err = resolvePhysical(dir, target); err != nil {
+			return SymlinkError
+		}
+	} else if err != nil {
+		// Like a loop of links.
+		return SymlinkError
+	}
+	if resolved != root && !strings.HasPrefix(resolved, root+string(os.PathSeparator)) {
+		return SymlinkError
+	}
+	return nil
+}
+
+func removeAll(paths []string) {
+	for _, p := range paths {
+		os.Remove(p)
+	}
+}
+
+// resolvePhysical resolves the target relative to dir one component at a time, following symlinks as the OS
+// would. A lexical clean is not enough, since ".." after a symlink goes to the parent of the symlink's target.
+func resolvePhysical(dir, target string) (string, error) {
+	current := dir
+	for _, c := range strings.Split(filepath.ToSlash(target), "/") {
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			current = filepath.Dir(current)
+			continue
+		}
+		next := filepath.Join(current, c)
+		info, err := os.Lstat(next)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if next, err = filepath.EvalSymlinks(next); err != nil {
+				return "", err
+			}
+		}
+		current = next
+	}
+	return current, nil
+}
@@ -0,0 +1,379 @@
+package gosprout
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type zipEntry struct {
+	name   string
+	body   []byte
+	mode   os.FileMode
+	method uint16
+}
+
+func writeZip(t *testing.T, dir string, entries []zipEntry) string {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: e.method, Modified: time.Unix(1600000000, 0)}
+		mode := e.mode
+		if mode == 0 {
+			mode = 0644
+		}
+		h.SetMode(mode)
+		f, err := w.CreateHeader(h)
+		if err != nil {
+			t.Fatalf("failed to create zip entry: %v\n", err)
+		}
+		f.Write(e.body)
+	}
+	w.Close()
+
+	src := filepath.Join(dir, "test.zip")
+	if err := ioutil.WriteFile(src, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write zip: %v\n", err)
+	}
+	return src
+}
+
+func TestUnzipWithOptions(t *testing.T) {
+	link := os.ModeSymlink | 0777
+	big := bytes.Repeat([]byte("a"), 2<<20)
+
+	tests := []struct {
+		entries []zipEntry
+		opts    UnzipOptions
+		err     error
+		entry   string
+		files   []string
+	}{
+		{
+			entries: []zipEntry{{name: "conf/"}, {name: "conf/app.yaml", body: []byte("name: app\n")}},
+			files:   []string{"conf", "conf/app.yaml"},
+		},
+		{
+			entries: []zipEntry{{name: "../escape", body: []byte("x")}},
+			err:     IllegalPathError,
+			entry:   "../escape",
+		},
+		{
+			entries: []zipEntry{{name: "a", body: []byte("1")}, {name: "b", body: []byte("2")}, {name: "c", body: []byte("3")}},
+			opts:    UnzipOptions{MaxFiles: 2},
+			err:     TooManyFilesError,
+		},
+		{
+			entries: []zipEntry{{name: "small", body: []byte("12")}, {name: "large", body: []byte("123456")}},
+			opts:    UnzipOptions{MaxFileSize: 5},
+			err:     FileTooLargeError,
+			entry:   "large",
+		},
+		{
+			entries: []zipEntry{{name: "a", body: []byte("123")}, {name: "b", body: []byte("456")}},
+			opts:    UnzipOptions{MaxTotalSize: 5},
+			err:     ArchiveTooLargeError,
+			entry:   "b",
+		},
+		{
+			entries: []zipEntry{{name: "bomb", body: big, method: zip.Deflate}},
+			err:     CompressionRatioError,
+			entry:   "bomb",
+		},
+		{
+			entries: []zipEntry{{name: "bomb", body: big, method: zip.Deflate}},
+			opts:    UnzipOptions{MaxRatio: -1},
+			files:   []string{"bomb"},
+		},
+		{
+			entries: []zipEntry{{name: "conf/app.yaml", body: []byte("x")}, {name: "link", body: []byte("conf/app.yaml"), mode: link}},
+			err:     SymlinkError,
+			entry:   "link",
+		},
+		{
+			entries: []zipEntry{{name: "conf/app.yaml", body: []byte("x")}, {name: "link", body: []byte("conf/app.yaml"), mode: link}},
+			opts:    UnzipOptions{Symlinks: AllowSymlinksInDest},
+			files:   []string{"conf/app.yaml", "link"},
+		},
+		{
+			entries: []zipEntry{{name: "link", body: []byte("../outside"), mode: link}},
+			opts:    UnzipOptions{Symlinks: AllowSymlinksInDest},
+			err:     SymlinkError,
+			entry:   "link",
+		},
+		{
+			entries: []zipEntry{{name: "link", body: []byte("/etc/passwd"), mode: link}},
+			opts:    UnzipOptions{Symlinks: AllowSymlinksInDest},
+			err:     SymlinkError,
+			entry:   "link",
+		},
+		{
+			// x resolves to c, so the ".." in y goes up from c, not from a/b.
+			entries: []zipEntry{
+				{name: "c/"},
+				{name: "a/b/x", body: []byte("../../c"), mode: link},
+				{name: "a/b/y", body: []byte("x/../.."), mode: link},
+			},
+			opts:  UnzipOptions{Symlinks: AllowSymlinksInDest},
+			err:   SymlinkError,
+			entry: "a/b/y",
+		},
+		{
+			// When a is created, b does not exist yet and a stays inside dest. Once b links to dest itself,
+			// a resolves to the parent of dest.
+			entries: []zipEntry{
+				{name: "c/"},
+				{name: "a", body: []byte("b/c/../.."), mode: link},
+				{name: "b", body: []byte("."), mode: link},
+			},
+			opts:  UnzipOptions{Symlinks: AllowSymlinksInDest},
+			err:   SymlinkError,
+			entry: "a",
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gosprout-unzip")
+			if err != nil {
+				t.Fatalf("failed to create temp dir: %v\n", err)
+			}
+			defer os.RemoveAll(dir)
+			src := writeZip(t, dir, test.entries)
+			dest := filepath.Join(dir, "dest")
+
+			files, err := UnzipWithOptions(src, dest, test.opts)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("expected %v, but got %v\n", test.err, err)
+				}
+				var unzipErr *UnzipError
+				if errors.As(err, &unzipErr) != (test.entry != "") || (unzipErr != nil && unzipErr.Entry != test.entry) {
+					t.Errorf("expected the error to name entry %q, but got %v\n", test.entry, err)
+				}
+				filepath.Walk(dest, func(p string, info os.FileInfo, err error) error {
+					if err == nil && info.Mode()&os.ModeSymlink != 0 {
+						t.Errorf("expected no symlinks to be left after a failure, but found %s\n", p)
+					}
+					return nil
+				})
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+			if len(files) != len(test.files) {
+				t.Fatalf("expected files %v, but got %v\n", test.files, files)
+			}
+			for j, name := range test.files {
+				p := filepath.Join(dest, filepath.FromSlash(name))
+				if files[j] != p {
+					t.Errorf("expected %s, but got %s\n", p, files[j])
+				}
+				info, err := os.Lstat(p)
+				if err != nil {
+					t.Fatalf("failed to stat %s: %v\n", p, err)
+				}
+				if info.Mode()&os.ModeSymlink == 0 && !info.ModTime().Equal(time.Unix(1600000000, 0)) {
+					t.Errorf("expected the modification time of %s to be kept, but got %v\n", name, info.ModTime())
+				}
+			}
+		})
+	}
+}
+
+func TestUnzip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosprout-unzip")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	src := writeZip(t, dir, []zipEntry{{name: "a/b.txt", body: []byte("hello"), method: zip.Deflate}})
+
+	files, err := Unzip(src, filepath.Join(dir, "dest"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one file, but got %v and %v\n", files, err)
+	}
+	if b, _ := ioutil.ReadFile(files[0]); string(b) != "hello" {
+		t.Errorf("expected %q, but got %q\n", "hello", b)
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	root, err := ioutil.TempDir("", "gosprout-archive")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		"conf/app.yaml":     "name: app\n",
+		"conf/db.yaml":      "host: localhost\n",
+		"conf/.git/HEAD":    "ref: main\n",
+		"scripts/run.sh":    "#!/bin/sh\n",
+		"scripts/notes.txt": "notes\n",
+	}
+	for name, body := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(body), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v\n", name, err)
+		}
+	}
+	os.Chmod(filepath.Join(root, "scripts", "run.sh"), 0755)
+
+	tests := []struct {
+		opts     ArchiveOptions
+		expected []string
+	}{
+		{
+			opts:     ArchiveOptions{Format: ZipArchive, Exclude: []string{".git"}},
+			expected: []string{"conf/app.yaml", "conf/db.yaml", "scripts/notes.txt", "scripts/run.sh"},
+		},
+		{
+			opts:     ArchiveOptions{Format: TarArchive, Include: []string{"*.yaml", "scripts/*.sh"}},
+			expected: []string{"conf/app.yaml", "conf/db.yaml", "scripts/run.sh"},
+		},
+		{
+			opts:     ArchiveOptions{Format: TarGzArchive, Exclude: []string{".git", "*.txt"}, ModTime: time.Unix(1600000000, 0)},
+			expected: []string{"conf/app.yaml", "conf/db.yaml", "scripts/run.sh"},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var first, second bytes.Buffer
+			m, err := WriteArchive(&first, root, test.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+			if _, err := WriteArchive(&second, root, test.opts); err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+			if !bytes.Equal(first.Bytes(), second.Bytes()) {
+				t.Errorf("expected the archive to be reproducible\n")
+			}
+
+			if len(m.Files) != len(test.expected) {
+				t.Fatalf("expected %v, but got %v\n", test.expected, m.Files)
+			}
+			for j, name := range test.expected {
+				if m.Files[j].Name != name {
+					t.Errorf("expected %s, but got %s\n", name, m.Files[j].Name)
+				}
+			}
+
+			// The archive unpacks to the same files.
+			UpdateFromArchive(func(files FileSet) {
+				if files.Manifest().Digest != m.Digest {
+					t.Errorf("expected digest %s, but got %s\n", m.Digest, files.Manifest().Digest)
+				}
+				f, err := files.Open("scripts/run.sh")
+				if err != nil {
+					t.Fatalf("failed to open file: %v\n", err)
+				}
+				defer f.Close()
+				if info, _ := f.Stat(); info.Mode().Perm() != 0755 {
+					t.Errorf("expected mode 0755, but got %v\n", info.Mode())
+				}
+				if !test.opts.ModTime.IsZero() {
+					if info, _ := f.Stat(); !info.ModTime().Equal(test.opts.ModTime) {
+						t.Errorf("expected time %v, but got %v\n", test.opts.ModTime, info.ModTime())
+					}
+				}
+			}, ArchiveConfig{ErrorHandler: func(e error) { t.Errorf("unexpected error: %v\n", e) }})(&first)
+		})
+	}
+}
+
+func TestCreateArchive(t *testing.T) {
+	root, err := ioutil.TempDir("", "gosprout-archive")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(root)
+	if err := ioutil.WriteFile(filepath.Join(root, "app.yaml"), []byte("name: app\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v\n", err)
+	}
+
+	dest := filepath.Join(root, "bundle.zip")
+	for i := 0; i < 2; i++ {
+		m, err := CreateArchive(dest, root, ArchiveOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v\n", err)
+		}
+		if len(m.Files) != 1 || m.Files[0].Name != "app.yaml" {
+			t.Errorf("expected only app.yaml, but got %v\n", m.Files)
+		}
+	}
+	if entries, _ := ioutil.ReadDir(root); len(entries) != 2 {
+		t.Errorf("expected no temporary files to be left, but got %d entries\n", len(entries))
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosprout-stream")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	os.MkdirAll(src, 0755)
+	if err := ioutil.WriteFile(filepath.Join(src, "app.yaml"), []byte("name: app\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v\n", err)
+	}
+
+	var zipped bytes.Buffer
+	if err := ZipFilesTo(&zipped, []string{filepath.Join(src, "app.yaml")}); err != nil {
+		t.Fatalf("failed to zip: %v\n", err)
+	}
+	if _, err := zip.NewReader(bytes.NewReader(zipped.Bytes()), int64(zipped.Len())); err != nil {
+		t.Errorf("expected a valid zip, but got %v\n", err)
+	}
+
+	streamed, err := ioutil.ReadAll(ArchiveReader(src, ArchiveOptions{}))
+	if err != nil {
+		t.Fatalf("failed to read archive: %v\n", err)
+	}
+
+	tests := []struct {
+		reader io.Reader
+		opts   UnzipOptions
+		err    error
+	}{
+		{reader: bytes.NewReader(streamed)},
+		{reader: io.MultiReader(bytes.NewReader(streamed))},
+		{reader: io.MultiReader(bytes.NewReader(streamed)), opts: UnzipOptions{MaxTotalSize: 10}, err: ArchiveTooLargeError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			dest := filepath.Join(dir, "dest"+strconv.Itoa(i))
+			files, err := UnzipStream(test.reader, dest, test.opts)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("expected %v, but got %v\n", test.err, err)
+				}
+				return
+			}
+			if err != nil || len(files) != 1 {
+				t.Fatalf("expected one file, but got %v and %v\n", files, err)
+			}
+			if b, _ := ioutil.ReadFile(filepath.Join(dest, "app.yaml")); string(b) != "name: app\n" {
+				t.Errorf("unexpected content %q\n", b)
+			}
+		})
+	}
+
+	r := ArchiveReader(filepath.Join(dir, "missing"), ArchiveOptions{})
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Errorf("expected an error for a missing root\n")
+	}
+	r.Close()
+}
@@ -0,0 +1,269 @@
+package gosprout
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat is the format written by WriteArchive.
+type ArchiveFormat int
+
+const (
+	ZipArchive ArchiveFormat = iota
+	TarArchive
+	TarGzArchive
+)
+
+// ArchiveOptions select the files of an archive and how they are written.
+//
+// A pattern without a slash matches the name of a file or directory at any depth, and one with a slash matches
+// the whole slash separated path relative to the root, both with path.Match syntax. An excluded directory is
+// skipped with everything in it. When there are include patterns, only the files matching one of them are written.
+type ArchiveOptions struct {
+	Format  ArchiveFormat
+	Include []string
+	Exclude []string
+	// ModTime is used as the modification time of every entry when set. Otherwise the modification time of each
+	// file is used, truncated to the second.
+	ModTime time.Time
+}
+
+// WriteArchive writes the files under root to w as an archive, and returns its manifest. Entries are named by
+// their clean slash separated path relative to root and written in lexical order, with only the permission bits
+// and modification time of the files, so the same files always produce the same archive and digest. Symlinks are
+// stored as symlinks rather than followed, and directories are implied by the file names.
+func WriteArchive(w io.Writer, root string, opts ArchiveOptions) (Manifest, error) {
+	return writeArchive(w, root, opts, nil)
+}
+
+// ArchiveReader streams an archive of the files under root, see WriteArchive. The archive is written as it is
+// read, so it can be given directly as the body of an upload. An error while writing is returned by Read, and
+// closing the reader early stops the writing.
+func ArchiveReader(root string, opts ArchiveOptions) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := WriteArchive(pw, root, opts)
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// CreateArchive writes the files under root as an archive to the dest file, see WriteArchive. The archive is
+// written to a temporary file first and renamed to dest, so dest is never a partial archive. If dest is under
+// root, it is not included in the archive.
+func CreateArchive(dest, root string, opts ArchiveOptions) (Manifest, error) {
+	f, err := ioutil.TempFile(filepath.Dir(dest), "."+filepath.Base(dest)+".tmp")
+	if err != nil {
+		return Manifest{}, err
+	}
+	tmp := f.Name()
+	skip := map[string]bool{}
+	for _, p := range []string{dest, tmp} {
+		if abs, err := filepath.Abs(p); err == nil {
+			skip[abs] = true
+		}
+	}
+
+	m, err := writeArchive(f, root, opts, skip)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, dest)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return Manifest{}, err
+	}
+	return m, nil
+}
+
+func writeArchive(w io.Writer, root string, opts ArchiveOptions, skip map[string]bool) (Manifest, error) {
+	var aw archiveWriter
+	switch opts.Format {
+	case ZipArchive:
+		aw = &zipArchiveWriter{w: zip.NewWriter(w)}
+	case TarArchive:
+		aw = &tarArchiveWriter{w: tar.NewWriter(w)}
+	case TarGzArchive:
+		gz := gzip.NewWriter(w)
+		aw = &tarArchiveWriter{w: tar.NewWriter(gz), gz: gz}
+	default:
+		return Manifest{}, fmt.Errorf("[gosprout] unknown archive format %d", opts.Format)
+	}
+
+	files := &fileSet{index: map[string]int{}}
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if abs, e := filepath.Abs(p); e == nil && skip[abs] {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if name == "." {
+			return nil
+		}
+		if matchAny(opts.Exclude, name) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || (len(opts.Include) > 0 && !matchAny(opts.Include, name)) {
+			return nil
+		}
+
+		modTime := opts.ModTime
+		if modTime.IsZero() {
+			modTime = info.ModTime().Truncate(time.Second)
+		}
+		modTime = modTime.UTC()
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return aw.symlink(name, filepath.ToSlash(target), modTime)
+		case info.Mode().IsRegular():
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			h := sha256.New()
+			mode := info.Mode().Perm()
+			if err := aw.file(name, mode, modTime, info.Size(), io.TeeReader(f, h)); err != nil {
+				return fmt.Errorf("[gosprout] %s: %w", name, err)
+			}
+			files.manifest.Files = append(files.manifest.Files, ManifestEntry{
+				Name:    name,
+				Size:    info.Size(),
+				Mode:    mode,
+				ModTime: modTime,
+				SHA256:  hex.EncodeToString(h.Sum(nil)),
+			})
+			return nil
+		default:
+			return nil
+		}
+	})
+	if err != nil {
+		aw.close()
+		return Manifest{}, err
+	}
+	if err := aw.close(); err != nil {
+		return Manifest{}, err
+	}
+	files.finish()
+	return files.manifest, nil
+}
+
+// matchAny reports whether the slash separated path matches any of the patterns, see ArchiveOptions.
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		target := name
+		if !strings.Contains(p, "/") {
+			target = path.Base(name)
+		}
+		if ok, _ := path.Match(p, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type archiveWriter interface {
+	file(name string, mode os.FileMode, modTime time.Time, size int64, r io.Reader) error
+	symlink(name, target string, modTime time.Time) error
+	close() error
+}
+
+type zipArchiveWriter struct {
+	w *zip.Writer
+}
+
+func (z *zipArchiveWriter) file(name string, mode os.FileMode, modTime time.Time, _ int64, r io.Reader) error {
+	h := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+	h.SetMode(mode)
+	w, err := z.w.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+func (z *zipArchiveWriter) symlink(name, target string, modTime time.Time) error {
+	h := &zip.FileHeader{Name: name, Method: zip.Store, Modified: modTime}
+	h.SetMode(os.ModeSymlink | 0777)
+	w, err := z.w.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(target))
+	return err
+}
+
+func (z *zipArchiveWriter) close() error {
+	return z.w.Close()
+}
+
+type tarArchiveWriter struct {
+	w  *tar.Writer
+	gz *gzip.Writer
+}
+
+func (t *tarArchiveWriter) file(name string, mode os.FileMode, modTime time.Time, size int64, r io.Reader) error {
+	err := t.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(mode),
+		Size:     size,
+		ModTime:  modTime,
+	})
+	if err != nil {
+		return err
+	}
+	// The size in the header was taken before reading, so a file which changes size fails here instead of
+	// producing a corrupt archive.
+	_, err = io.Copy(t.w, r)
+	return err
+}
+
+func (t *tarArchiveWriter) symlink(name, target string, modTime time.Time) error {
+	return t.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeSymlink,
+		Name:     name,
+		Linkname: target,
+		Mode:     0777,
+		ModTime:  modTime,
+	})
+}
+
+func (t *tarArchiveWriter) close() error {
+	err := t.w.Close()
+	if t.gz != nil {
+		if e := t.gz.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,358 @@
+package gosprout
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/fire00f1y/go-sprout/resource"
+	"github.com/fire00f1y/go-sprout/resource/decompress"
+	"github.com/fire00f1y/go-sprout/resource/gcs"
+	"io"
+	"io/ioutil"
+	"math"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	UnknownArchiveError = errors.New("[gosprout] data is not a zip or tar archive")
+
+	defaultArchiveMaxSize int64 = 100 << 20
+)
+
+// ArchiveConfig controls how UpdateFromArchive unpacks an archive.
+type ArchiveConfig struct {
+	// StagingDir unpacks the files into a new directory under it, instead of holding them in memory. The directory
+	// is removed once the update func returns.
+	StagingDir string
+	// MaxSize is the maximum size in bytes of the archive and of the files in it, each after decompression. It
+	// defaults to 100 MiB, and a negative value means there is no limit.
+	MaxSize int64
+	// ErrorHandler is given the errors, instead of the DefaultErrorHandler.
+	ErrorHandler ErrorHandler
+}
+
+// ManifestEntry describes a regular file of an archive.
+type ManifestEntry struct {
+	// Name is the slash separated path of the file in the archive, without any leading "./".
+	Name    string
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+	SHA256  string
+}
+
+// Manifest lists the regular files of an archive, sorted by name. Directories are implied by the file names, and
+// other entries like symlinks are left out.
+type Manifest struct {
+	Files []ManifestEntry
+	// Digest is a digest of the names and contents of all the files, so two archives with the same files have the
+	// same digest, whatever their format or timestamps.
+	Digest string
+}
+
+// File is a file opened from a FileSet.
+type File interface {
+	io.ReadCloser
+	Stat() (os.FileInfo, error)
+}
+
+// FileSet is the set of files unpacked from an archive. It mirrors fs.FS from newer Go versions: names are slash
+// separated and relative to the root of the archive, and a missing file is an *os.PathError for os.ErrNotExist.
+type FileSet interface {
+	Open(name string) (File, error)
+	ReadFile(name string) ([]byte, error)
+	Manifest() Manifest
+}
+
+// UpdateFromArchive is an UpdateFunction for a zip, tar or compressed tar archive (see the decompress package),
+// which unpacks it and gives the files to the update func as a FileSet. This allows a bundle of files to be
+// shipped as a single object. The format is taken from the content type or name in the metadata of the reader,
+// or from the data itself.
+//
+// The update func is not called if the archive cannot be unpacked. The FileSet is only valid until the update
+// func returns.
+func UpdateFromArchive(update func(FileSet), cfg ArchiveConfig) UpdateFunction {
+	return func(r io.Reader) {
+		files, err := unpackArchive(r, cfg)
+		if files != nil {
+			defer files.remove()
+		}
+		if err != nil {
+			reportError(cfg.ErrorHandler, err)
+			return
+		}
+		update(files)
+	}
+}
+
+func unpackArchive(r io.Reader, cfg ArchiveConfig) (*fileSet, error) {
+	maxSize := cfg.MaxSize
+	if maxSize == 0 {
+		maxSize = defaultArchiveMaxSize
+	}
+	remaining := maxSize
+	if maxSize < 0 {
+		remaining = math.MaxInt64 - 1
+	}
+
+	dr, err := decompress.NewReader(r, decompress.Config{MaxSize: maxSize})
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
+	m, _ := resource.MetadataOf(dr)
+	br := bufio.NewReaderSize(dr, 512)
+	head, _ := br.Peek(262)
+
+	files := &fileSet{index: map[string]int{}}
+	if cfg.StagingDir != "" {
+		if files.dir, err = ioutil.TempDir(cfg.StagingDir, "bundle-"); err != nil {
+			return nil, err
+		}
+	} else {
+		files.data = map[string][]byte{}
+	}
+
+	switch archiveFormat(m, head) {
+	case "zip":
+		data, err := ioutil.ReadAll(io.LimitReader(br, remaining+1))
+		if err != nil {
+			return files, err
+		}
+		if int64(len(data)) > remaining {
+			return files, decompress.SizeLimitError
+		}
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return files, err
+		}
+		for _, f := range zr.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return files, err
+			}
+			err = files.add(f.Name, f.Mode(), f.Modified, rc, &remaining)
+			rc.Close()
+			if err != nil {
+				return files, err
+			}
+		}
+	case "tar":
+		tr := tar.NewReader(br)
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return files, err
+			}
+			if h.Typeflag != tar.TypeReg && h.Typeflag != tar.TypeRegA {
+				continue
+			}
+			if err := files.add(h.Name, h.FileInfo().Mode(), h.ModTime, tr, &remaining); err != nil {
+				return files, err
+			}
+		}
+	default:
+		return files, UnknownArchiveError
+	}
+
+	files.finish()
+	return files, nil
+}
+
+// archiveFormat returns "zip" or "tar" from the content type, the name or the magic bytes, or "" if none match.
+func archiveFormat(m resource.Metadata, head []byte) string {
+	t, _, _ := mime.ParseMediaType(m.ContentType)
+	switch {
+	case t == gcs.ZipContentType || t == gcs.XZipContentType:
+		return "zip"
+	case t == "application/x-tar":
+		return "tar"
+	}
+	switch strings.ToLower(path.Ext(m.Name)) {
+	case ".zip":
+		return "zip"
+	case ".tar":
+		return "tar"
+	}
+	if bytes.HasPrefix(head, []byte("PK\x03\x04")) || bytes.HasPrefix(head, []byte("PK\x05\x06")) {
+		return "zip"
+	}
+	if len(head) >= 262 && string(head[257:262]) == "ustar" {
+		return "tar"
+	}
+	return ""
+}
+
+// cleanEntryName returns the clean slash separated name of an archive entry, and rejects names which would point
+// outside of the archive root.
+func cleanEntryName(name string) (string, error) {
+	c := path.Clean(strings.Replace(name, "\\", "/", -1))
+	if path.IsAbs(c) || c == "." || c == ".." || strings.HasPrefix(c, "../") {
+		return "", fmt.Errorf("[gosprout] %s: illegal file path", name)
+	}
+	return c, nil
+}
+
+// fileSet holds the files either in memory or in a staging directory.
+type fileSet struct {
+	dir      string
+	data     map[string][]byte
+	index    map[string]int
+	manifest Manifest
+}
+
+// add reads a file into the set, replacing an earlier file with the same name. The remaining size is decremented
+// by the size of the file.
+func (s *fileSet) add(name string, mode os.FileMode, modTime time.Time, r io.Reader, remaining *int64) error {
+	name, err := cleanEntryName(name)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	lr := io.LimitReader(r, *remaining+1)
+	var n int64
+	if s.dir == "" {
+		var buf bytes.Buffer
+		n, err = io.Copy(io.MultiWriter(&buf, h), lr)
+		s.data[name] = buf.Bytes()
+	} else {
+		p := filepath.Join(s.dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		var f *os.File
+		if f, err = os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm()|0600); err != nil {
+			return err
+		}
+		n, err = io.Copy(io.MultiWriter(f, h), lr)
+		if e := f.Close(); err == nil {
+			err = e
+		}
+		if err == nil && !modTime.IsZero() {
+			err = os.Chtimes(p, modTime, modTime)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if n > *remaining {
+		return decompress.SizeLimitError
+	}
+	*remaining -= n
+
+	entry := ManifestEntry{
+		Name:    name,
+		Size:    n,
+		Mode:    mode,
+		ModTime: modTime,
+		SHA256:  hex.EncodeToString(h.Sum(nil)),
+	}
+	if i, ok := s.index[name]; ok {
+		s.manifest.Files[i] = entry
+	} else {
+		s.index[name] = len(s.manifest.Files)
+		s.manifest.Files = append(s.manifest.Files, entry)
+	}
+	return nil
+}
+
+// finish sorts the manifest and computes its digest.
+func (s *fileSet) finish() {
+	sort.Slice(s.manifest.Files, func(i, j int) bool {
+		return s.manifest.Files[i].Name < s.manifest.Files[j].Name
+	})
+	h := sha256.New()
+	for i, f := range s.manifest.Files {
+		s.index[f.Name] = i
+		fmt.Fprintf(h, "%s\x00%s\n", f.Name, f.SHA256)
+	}
+	s.manifest.Digest = hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *fileSet) remove() {
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+	}
+}
+
+func (s *fileSet) Manifest() Manifest {
+	return s.manifest
+}
+
+func (s *fileSet) Open(name string) (File, error) {
+	i, ok := s.index[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	info := entryInfo{s.manifest.Files[i]}
+	if s.dir == "" {
+		return &memFile{Reader: bytes.NewReader(s.data[name]), info: info}, nil
+	}
+	f, err := os.Open(filepath.Join(s.dir, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	return &stagedFile{File: f, info: info}, nil
+}
+
+func (s *fileSet) ReadFile(name string) ([]byte, error) {
+	f, err := s.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// entryInfo is the os.FileInfo of a manifest entry.
+type entryInfo struct {
+	entry ManifestEntry
+}
+
+func (i entryInfo) Name() string       { return path.Base(i.entry.Name) }
+func (i entryInfo) Size() int64        { return i.entry.Size }
+func (i entryInfo) Mode() os.FileMode  { return i.entry.Mode }
+func (i entryInfo) ModTime() time.Time { return i.entry.ModTime }
+func (i entryInfo) IsDir() bool        { return false }
+func (i entryInfo) Sys() interface{}   { return nil }
+
+type memFile struct {
+	*bytes.Reader
+	info entryInfo
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+// stagedFile reports the mode and time from the archive, rather than those of the staged copy.
+type stagedFile struct {
+	*os.File
+	info entryInfo
+}
+
+func (f *stagedFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
@@ -0,0 +1,190 @@
+package gosprout
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"github.com/fire00f1y/go-sprout/resource"
+	"github.com/fire00f1y/go-sprout/resource/decompress"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type archiveEntry struct {
+	name string
+	body string
+	link bool
+}
+
+func zipArchive(t *testing.T, entries []archiveEntry) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: time.Unix(1600000000, 0)}
+		h.SetMode(0644)
+		if e.link {
+			h.SetMode(os.ModeSymlink | 0777)
+		}
+		f, err := w.CreateHeader(h)
+		if err != nil {
+			t.Fatalf("failed to create zip entry: %v\n", err)
+		}
+		f.Write([]byte(e.body))
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+func tarArchive(t *testing.T, entries []archiveEntry, compress bool) []byte {
+	var buf bytes.Buffer
+	var out io.Writer = &buf
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		out = gz
+	}
+	w := tar.NewWriter(out)
+	w.WriteHeader(&tar.Header{Name: "./conf/", Typeflag: tar.TypeDir, Mode: 0755})
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(e.body)), ModTime: time.Unix(1600000000, 0)}
+		if e.link {
+			h = &tar.Header{Name: e.name, Typeflag: tar.TypeSymlink, Linkname: e.body}
+		}
+		if err := w.WriteHeader(h); err != nil {
+			t.Fatalf("failed to write tar header: %v\n", err)
+		}
+		if !e.link {
+			w.Write([]byte(e.body))
+		}
+	}
+	w.Close()
+	if gz != nil {
+		gz.Close()
+	}
+	return buf.Bytes()
+}
+
+func TestUpdateFromArchive(t *testing.T) {
+	entries := []archiveEntry{
+		{name: "./conf/app.yaml", body: "name: app\n"},
+		{name: "conf/db.yaml", body: "host: localhost\n"},
+		{name: "conf/current", body: "app.yaml", link: true},
+		{name: "README", body: "bundle"},
+	}
+	expected := map[string]string{
+		"README":        "bundle",
+		"conf/app.yaml": "name: app\n",
+		"conf/db.yaml":  "host: localhost\n",
+	}
+	stagingDir, err := ioutil.TempDir("", "gosprout-bundle")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	tests := []struct {
+		data     []byte
+		metadata *resource.Metadata
+		cfg      ArchiveConfig
+	}{
+		{data: zipArchive(t, entries)},
+		{data: zipArchive(t, entries), metadata: &resource.Metadata{ContentType: "application/zip"}},
+		{data: tarArchive(t, entries, false)},
+		{data: tarArchive(t, entries, true)},
+		{data: tarArchive(t, entries, true), metadata: &resource.Metadata{Name: "bundle.tgz"}},
+		{data: tarArchive(t, entries, true), cfg: ArchiveConfig{StagingDir: stagingDir}},
+		{data: zipArchive(t, entries), cfg: ArchiveConfig{StagingDir: stagingDir}},
+	}
+
+	var digest string
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var r io.Reader = bytes.NewReader(test.data)
+			if test.metadata != nil {
+				r = resource.WithMetadata(r, *test.metadata)
+			}
+			test.cfg.ErrorHandler = func(e error) {
+				t.Errorf("unexpected error: %v\n", e)
+			}
+
+			called := false
+			UpdateFromArchive(func(files FileSet) {
+				called = true
+				m := files.Manifest()
+				if len(m.Files) != len(expected) {
+					t.Errorf("expected %d files, but got %v\n", len(expected), m.Files)
+				}
+				if digest == "" {
+					digest = m.Digest
+				} else if m.Digest != digest {
+					t.Errorf("expected the digest to be the same for every format\n")
+				}
+				for name, body := range expected {
+					b, err := files.ReadFile(name)
+					if err != nil || string(b) != body {
+						t.Errorf("expected %s to be %q, but got %q and %v\n", name, body, b, err)
+					}
+				}
+
+				f, err := files.Open("conf/app.yaml")
+				if err != nil {
+					t.Fatalf("failed to open file: %v\n", err)
+				}
+				defer f.Close()
+				info, _ := f.Stat()
+				if info.Name() != "app.yaml" || info.Size() != 10 || !info.ModTime().Equal(time.Unix(1600000000, 0)) {
+					t.Errorf("unexpected file info: %v %v %v\n", info.Name(), info.Size(), info.ModTime())
+				}
+				if _, err := files.Open("conf/current"); !os.IsNotExist(err) {
+					t.Errorf("expected the symlink to be left out, but got %v\n", err)
+				}
+			}, test.cfg)(r)
+
+			if !called {
+				t.Errorf("expected the update func to be called\n")
+			}
+			if left, _ := ioutil.ReadDir(stagingDir); len(left) != 0 {
+				t.Errorf("expected the staging dir to be cleaned up, but found %d entries\n", len(left))
+			}
+		})
+	}
+}
+
+func TestUpdateFromArchive_Errors(t *testing.T) {
+	tests := []struct {
+		data []byte
+		cfg  ArchiveConfig
+		err  error
+	}{
+		{data: []byte("not an archive"), err: UnknownArchiveError},
+		{data: zipArchive(t, []archiveEntry{{name: "../escape", body: "x"}})},
+		{data: tarArchive(t, []archiveEntry{{name: "/etc/passwd", body: "x"}}, false)},
+		{data: zipArchive(t, []archiveEntry{{name: "big", body: string(make([]byte, 1000))}}), cfg: ArchiveConfig{MaxSize: 999}, err: decompress.SizeLimitError},
+		{data: tarArchive(t, []archiveEntry{{name: "a", body: "1234"}, {name: "b", body: "5678"}}, true), cfg: ArchiveConfig{MaxSize: 3000}, err: decompress.SizeLimitError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var errs []error
+			test.cfg.ErrorHandler = func(e error) {
+				errs = append(errs, e)
+			}
+			UpdateFromArchive(func(FileSet) {
+				t.Errorf("unexpected update\n")
+			}, test.cfg)(bytes.NewReader(test.data))
+
+			if len(errs) != 1 {
+				t.Fatalf("expected an error, but got %v\n", errs)
+			}
+			if test.err != nil && !errors.Is(errs[0], test.err) {
+				t.Errorf("expected %v, but got %v\n", test.err, errs[0])
+			}
+		})
+	}
+}
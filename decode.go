@@ -0,0 +1,337 @@
+package gosprout
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/fire00f1y/go-sprout/resource"
+	"github.com/fire00f1y/go-sprout/resource/decompress"
+	"github.com/fire00f1y/go-sprout/resource/gcs"
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v3"
+	"io"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	UnknownContentTypeError  = errors.New("[gosprout] no decoder registered for the content type")
+	notPointerError          = errors.New("[gosprout] the update target must be a non-nil pointer")
+	unsupportedCsvError      = errors.New("[gosprout] csv can only be decoded into *[][]string, *[]map[string]string or a pointer to a slice of structs")
+	missingContentTypeSniffs = []string{"", gcs.OctetStreamContentType, gcs.BinaryOctetStreamContentType, gcs.TextPlainContentType}
+
+	decodersMu = &sync.RWMutex{}
+	decoders   = map[string]Decoder{
+		"application/json":   JsonDecoder,
+		"text/json":          JsonDecoder,
+		"application/yaml":   YamlDecoder,
+		"application/x-yaml": YamlDecoder,
+		"text/yaml":          YamlDecoder,
+		"text/x-yaml":        YamlDecoder,
+		"application/toml":   TomlDecoder,
+		"text/toml":          TomlDecoder,
+		"application/xml":    XmlDecoder,
+		gcs.XmlContentType:   XmlDecoder,
+		gcs.CsvContentType:   CsvDecoder,
+	}
+)
+
+// Decoder decodes the data from a reader into the value v points to.
+type Decoder func(r io.Reader, v interface{}) error
+
+// RegisterDecoder registers the decoder for a content type, replacing any existing one, or removes it if the decoder
+// is nil. Parameters of the content type, like the charset, are ignored.
+func RegisterDecoder(contentType string, d Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	if d == nil {
+		delete(decoders, mediaType(contentType))
+		return
+	}
+	decoders[mediaType(contentType)] = d
+}
+
+// DecoderFor returns the decoder registered for a content type.
+func DecoderFor(contentType string) (Decoder, bool) {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	d, ok := decoders[mediaType(contentType)]
+	return d, ok
+}
+
+// UpdateInto is an UpdateFunction which decodes the data into the value ptr points to, with the decoder registered
+// for the content type in the metadata of the reader (see resource.MetadataOf). Compressed data is decompressed
+// first, with the default limits of the decompress package. Without a specific content type, JSON and XML are
+// recognized by their first character.
+//
+// The data is decoded into the current value, unless the Replace option is given. If ptr is a Serializer, it is
+// locked while the value changes and its Pointer() is used as the target, otherwise the caller is responsible for
+// synchronizing access. Errors are given to the DefaultErrorHandler, unless another one is provided with
+// WithErrorHandler. The Strict option has no effect, since the registered decoders have no strict mode.
+func UpdateInto(ptr interface{}, opts ...DecodeOption) UpdateFunction {
+	o := decodeOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return func(r io.Reader) {
+		if e := decodeInto(r, ptr, o.replace); e != nil {
+			reportError(o.errorHandler, e)
+		}
+	}
+}
+
+func decodeInto(r io.Reader, ptr interface{}, replace bool) error {
+	var locker sync.Locker
+	if s, ok := ptr.(Serializer); ok {
+		ptr = s.Pointer()
+		locker = s
+	}
+	if target := reflect.ValueOf(ptr); target.Kind() != reflect.Ptr || target.IsNil() {
+		return notPointerError
+	}
+
+	dr, err := decompress.NewReader(r, decompress.Config{})
+	if err != nil {
+		return err
+	}
+	defer dr.Close()
+	r = dr
+	m, _ := resource.MetadataOf(r)
+	contentType := mediaType(m.ContentType)
+
+	for _, t := range missingContentTypeSniffs {
+		if contentType == t {
+			var br *bufio.Reader
+			br, contentType = sniff(r)
+			r = br
+			break
+		}
+	}
+	d, ok := DecoderFor(contentType)
+	if !ok {
+		return fmt.Errorf("%w: %q", UnknownContentTypeError, contentType)
+	}
+
+	return decodeValue(ptr, locker, replace, func(v interface{}) error {
+		if err := d(r, v); err != nil {
+			return fmt.Errorf("[gosprout] failed to decode %s: %w", contentType, err)
+		}
+		return nil
+	})
+}
+
+// decodeValue decodes into the value ptr points to, holding the locker, if there is one, while the value changes.
+// With replace, the data is decoded into a new value first, which replaces the current one only if decoding
+// succeeds.
+func decodeValue(ptr interface{}, locker sync.Locker, replace bool, decode func(v interface{}) error) error {
+	target := reflect.ValueOf(ptr)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return notPointerError
+	}
+	if !replace {
+		if locker != nil {
+			locker.Lock()
+			defer locker.Unlock()
+		}
+		return decode(ptr)
+	}
+
+	v := reflect.New(target.Type().Elem())
+	if err := decode(v.Interface()); err != nil {
+		return err
+	}
+	if locker != nil {
+		locker.Lock()
+		defer locker.Unlock()
+	}
+	target.Elem().Set(v.Elem())
+	return nil
+}
+
+// sniff recognizes JSON and XML by their first non-space character.
+func sniff(r io.Reader) (*bufio.Reader, string) {
+	br := bufio.NewReader(r)
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return br, ""
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{', '[':
+			br.UnreadByte()
+			return br, "application/json"
+		case '<':
+			br.UnreadByte()
+			return br, "application/xml"
+		default:
+			br.UnreadByte()
+			return br, ""
+		}
+	}
+}
+
+func mediaType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return t
+}
+
+// JsonDecoder decodes JSON with encoding/json.
+func JsonDecoder(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// YamlDecoder decodes YAML with gopkg.in/yaml.v3.
+func YamlDecoder(r io.Reader, v interface{}) error {
+	return yaml.NewDecoder(r).Decode(v)
+}
+
+// TomlDecoder decodes TOML with github.com/pelletier/go-toml.
+func TomlDecoder(r io.Reader, v interface{}) error {
+	return toml.NewDecoder(r).Decode(v)
+}
+
+// XmlDecoder decodes XML with encoding/xml.
+func XmlDecoder(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// CsvDecoder decodes CSV into a *[][]string with every record, or into a *[]map[string]string or a pointer to a
+// slice of structs (or struct pointers) using the first record as the header. Struct fields are matched to columns
+// by their `csv` tag, or by name ignoring case, and can be strings, bools, integers or floats. A field tagged
+// `csv:"-"` is skipped.
+func CsvDecoder(r io.Reader, v interface{}) error {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return err
+	}
+
+	switch t := v.(type) {
+	case *[][]string:
+		*t = records
+		return nil
+	case *[]map[string]string:
+		if len(records) == 0 {
+			*t = nil
+			return nil
+		}
+		rows := make([]map[string]string, 0, len(records)-1)
+		for _, record := range records[1:] {
+			row := make(map[string]string, len(record))
+			for i, name := range records[0] {
+				if i < len(record) {
+					row[name] = record[i]
+				}
+			}
+			rows = append(rows, row)
+		}
+		*t = rows
+		return nil
+	}
+
+	slice := reflect.ValueOf(v)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return unsupportedCsvError
+	}
+	slice = slice.Elem()
+	elem := slice.Type().Elem()
+	isPtr := elem.Kind() == reflect.Ptr
+	if isPtr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return unsupportedCsvError
+	}
+	if len(records) == 0 {
+		slice.Set(reflect.Zero(slice.Type()))
+		return nil
+	}
+
+	fields := csvFields(elem, records[0])
+	out := reflect.MakeSlice(slice.Type(), 0, len(records)-1)
+	for line, record := range records[1:] {
+		item := reflect.New(elem)
+		for i, f := range fields {
+			if f < 0 || i >= len(record) {
+				continue
+			}
+			if err := setField(item.Elem().Field(f), record[i]); err != nil {
+				return fmt.Errorf("record %d, column %q: %w", line+1, records[0][i], err)
+			}
+		}
+		if isPtr {
+			out = reflect.Append(out, item)
+		} else {
+			out = reflect.Append(out, item.Elem())
+		}
+	}
+	slice.Set(out)
+	return nil
+}
+
+// csvFields maps each column of the header to the index of its struct field, or -1.
+func csvFields(t reflect.Type, header []string) []int {
+	fields := make([]int, len(header))
+	for i, name := range header {
+		fields[i] = -1
+		for f := 0; f < t.NumField(); f++ {
+			field := t.Field(f)
+			if field.PkgPath != "" {
+				continue
+			}
+			tag := strings.Split(field.Tag.Get("csv"), ",")[0]
+			if tag == "-" {
+				continue
+			}
+			if tag == name || (tag == "" && strings.EqualFold(field.Name, name)) {
+				fields[i] = f
+				break
+			}
+		}
+	}
+	return fields
+}
+
+func setField(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package gosprout
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"github.com/fire00f1y/go-sprout/resource"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type decodeConfig struct {
+	Name  string `json:"name" yaml:"name" toml:"name" xml:"name"`
+	Port  int    `json:"port" yaml:"port" toml:"port" xml:"port"`
+	Debug bool   `json:"debug" yaml:"debug" toml:"debug" xml:"debug"`
+}
+
+type lockedConfig struct {
+	sync.Mutex
+	cfg decodeConfig
+}
+
+func (l *lockedConfig) Pointer() interface{} {
+	return &l.cfg
+}
+
+func TestUpdateInto(t *testing.T) {
+	defer SetErrorHandler(DefaultErrorHandler)
+	expected := decodeConfig{Name: "app", Port: 8080, Debug: true}
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(`{"name": "app", "port": 8080, "debug": true}`))
+	w.Close()
+
+	tests := []struct {
+		data     string
+		metadata *resource.Metadata
+		err      bool
+	}{
+		{
+			data:     `{"name": "app", "port": 8080, "debug": true}`,
+			metadata: &resource.Metadata{ContentType: "application/json; charset=utf-8"},
+		},
+		{
+			data:     "name: app\nport: 8080\ndebug: true\n",
+			metadata: &resource.Metadata{ContentType: "application/yaml"},
+		},
+		{
+			data:     "name = \"app\"\nport = 8080\ndebug = true\n",
+			metadata: &resource.Metadata{ContentType: "application/toml"},
+		},
+		{
+			data:     "<config><name>app</name><port>8080</port><debug>true</debug></config>",
+			metadata: &resource.Metadata{ContentType: "text/xml"},
+		},
+		{
+			data: `  {"name": "app", "port": 8080, "debug": true}`,
+		},
+		{
+			data:     gz.String(),
+			metadata: &resource.Metadata{ContentType: "application/json", Encoding: "gzip"},
+		},
+		{
+			data:     gz.String(),
+			metadata: &resource.Metadata{ContentType: "application/gzip"},
+		},
+		{
+			data: "name: app",
+			err:  true,
+		},
+		{
+			data:     `{"name": "app", "port": "not a number"}`,
+			metadata: &resource.Metadata{ContentType: "application/json"},
+			err:      true,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var errs []error
+			SetErrorHandler(func(e error) { errs = append(errs, e) })
+
+			var r io.Reader = strings.NewReader(test.data)
+			if test.metadata != nil {
+				r = resource.WithMetadata(r, *test.metadata)
+			}
+			l := &lockedConfig{cfg: decodeConfig{Name: "old"}}
+			UpdateInto(l, Replace())(r)
+
+			if test.err {
+				if len(errs) != 1 {
+					t.Errorf("expected an error, but got %v\n", errs)
+				}
+				if l.cfg.Name != "old" {
+					t.Errorf("expected the value to be unchanged, but got %v\n", l.cfg)
+				}
+				return
+			}
+			if len(errs) != 0 {
+				t.Errorf("unexpected errors: %v\n", errs)
+			}
+			if l.cfg != expected {
+				t.Errorf("expected %v, but got %v\n", expected, l.cfg)
+			}
+		})
+	}
+}
+
+func TestUpdateInto_InPlace(t *testing.T) {
+	l := &lockedConfig{cfg: decodeConfig{Name: "app", Debug: true}}
+	UpdateInto(l)(resource.WithMetadata(strings.NewReader(`{"port": 8080}`), resource.Metadata{ContentType: "application/json"}))
+	if expected := (decodeConfig{Name: "app", Port: 8080, Debug: true}); l.cfg != expected {
+		t.Errorf("expected the fields missing from the data to be kept, %v, but got %v\n", expected, l.cfg)
+	}
+}
+
+func TestUpdateInto_Errors(t *testing.T) {
+	defer SetErrorHandler(DefaultErrorHandler)
+	var errs []error
+	SetErrorHandler(func(e error) { errs = append(errs, e) })
+
+	var cfg decodeConfig
+	UpdateInto(cfg)(strings.NewReader("{}"))
+	UpdateInto(&cfg)(resource.WithMetadata(strings.NewReader("{}"), resource.Metadata{ContentType: "image/png"}))
+	if len(errs) != 2 || errs[0] != notPointerError || !errors.Is(errs[1], UnknownContentTypeError) {
+		t.Errorf("expected a pointer error and an unknown content type error, but got %v\n", errs)
+	}
+
+	RegisterDecoder("image/png", func(r io.Reader, v interface{}) error {
+		v.(*decodeConfig).Name = "png"
+		return nil
+	})
+	defer RegisterDecoder("image/png", nil)
+	errs = nil
+	UpdateInto(&cfg)(resource.WithMetadata(strings.NewReader("{}"), resource.Metadata{ContentType: "image/png"}))
+	if len(errs) != 0 || cfg.Name != "png" {
+		t.Errorf("expected the registered decoder to be used, but got %v and %v\n", cfg, errs)
+	}
+}
+
+func TestCsvDecoder(t *testing.T) {
+	type row struct {
+		Name    string
+		Port    int     `csv:"port_number"`
+		Weight  float64 `csv:"weight"`
+		Skipped string  `csv:"-"`
+	}
+	data := "name,port_number,weight,skipped\napp,8080,0.5,x\ndb,5432,1,y\n"
+
+	tests := []struct {
+		target   interface{}
+		expected interface{}
+		err      bool
+	}{
+		{
+			target:   &[][]string{},
+			expected: &[][]string{{"name", "port_number", "weight", "skipped"}, {"app", "8080", "0.5", "x"}, {"db", "5432", "1", "y"}},
+		},
+		{
+			target: &[]map[string]string{},
+			expected: &[]map[string]string{
+				{"name": "app", "port_number": "8080", "weight": "0.5", "skipped": "x"},
+				{"name": "db", "port_number": "5432", "weight": "1", "skipped": "y"},
+			},
+		},
+		{
+			target:   &[]row{},
+			expected: &[]row{{Name: "app", Port: 8080, Weight: 0.5}, {Name: "db", Port: 5432, Weight: 1}},
+		},
+		{
+			target:   &[]*row{},
+			expected: &[]*row{{Name: "app", Port: 8080, Weight: 0.5}, {Name: "db", Port: 5432, Weight: 1}},
+		},
+		{
+			target: &map[string]string{},
+			err:    true,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			err := CsvDecoder(strings.NewReader(data), test.target)
+			if test.err {
+				if err == nil {
+					t.Errorf("expected an error, but got none\n")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+			if !reflect.DeepEqual(test.target, test.expected) {
+				t.Errorf("expected %v, but got %v\n", test.expected, test.target)
+			}
+		})
+	}
+}
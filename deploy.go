@@ -0,0 +1,248 @@
+package gosprout
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	currentLink     = "current"
+	releasesDir     = "releases"
+	stagingPrefix   = "bundle-"
+	releaseTimeFmt  = "20060102T150405.000000000"
+	defaultReleases = 3
+)
+
+var (
+	NoPreviousReleaseError = errors.New("[gosprout] there is no release before the current one")
+
+	deployMu = &sync.Mutex{}
+)
+
+// PruneError is returned by DeployArchive when the new release is current, but the old releases could not all be
+// removed.
+type PruneError struct {
+	Err error
+}
+
+func (e *PruneError) Error() string {
+	return fmt.Sprintf("[gosprout] release is current, but old releases were not removed: %v", e.Err)
+}
+
+func (e *PruneError) Unwrap() error {
+	return e.Err
+}
+
+// DeployConfig controls how DeployArchive unpacks and swaps releases.
+type DeployConfig struct {
+	// KeepReleases is how many releases before the current one are kept for Rollback. It defaults to 3, and a
+	// negative value keeps none.
+	KeepReleases int
+	// MaxSize is the same as in ArchiveConfig.
+	MaxSize int64
+	// Verify is an optional check of an unpacked release directory before it becomes current, e.g. parsing the
+	// config files in it. The release is discarded if it returns an error.
+	Verify func(dir string) error
+	// Deployed is called by UpdateDeployArchive with the directory of each release once it is current.
+	Deployed func(dir string)
+	// ErrorHandler is given the errors of UpdateDeployArchive, instead of the DefaultErrorHandler.
+	ErrorHandler ErrorHandler
+}
+
+// DeployArchive unpacks a zip or tar archive (see UpdateFromArchive) as a new release of the root directory, and
+// makes it current. It is unpacked into a staging directory under root/releases, checked against its manifest and
+// the Verify func, and renamed to its release name. Then the root/current symlink is replaced atomically to point
+// to it, so readers going through root/current see either the old or the new tree, never a mix. Failing at any
+// step leaves the current release in place.
+//
+// The release directory is returned. If the archive has the same files as the current release, nothing changes
+// and the current release is returned. Releases older than the ones kept are removed, and a failure to remove them
+// is returned as a *PruneError along with the directory of the release, which is current all the same.
+func DeployArchive(r io.Reader, root string, cfg DeployConfig) (string, error) {
+	deployMu.Lock()
+	defer deployMu.Unlock()
+
+	releases := filepath.Join(root, releasesDir)
+	if err := os.MkdirAll(releases, 0755); err != nil {
+		return "", err
+	}
+	files, err := unpackArchive(r, ArchiveConfig{StagingDir: releases, MaxSize: cfg.MaxSize})
+	if err != nil {
+		if files != nil {
+			files.remove()
+		}
+		return "", err
+	}
+	if err := verifyRelease(files); err != nil {
+		files.remove()
+		return "", err
+	}
+	if cfg.Verify != nil {
+		if err := cfg.Verify(files.dir); err != nil {
+			files.remove()
+			return "", fmt.Errorf("[gosprout] release failed verification: %w", err)
+		}
+	}
+
+	suffix := "-" + files.manifest.Digest[:12]
+	if current, err := CurrentRelease(root); err == nil && strings.HasSuffix(current, suffix) {
+		files.remove()
+		return current, nil
+	}
+	name := time.Now().UTC().Format(releaseTimeFmt) + suffix
+	if err := os.Rename(files.dir, filepath.Join(releases, name)); err != nil {
+		files.remove()
+		return "", err
+	}
+	if err := os.Chmod(filepath.Join(releases, name), 0755); err != nil {
+		os.RemoveAll(filepath.Join(releases, name))
+		return "", err
+	}
+	if err := swapCurrent(root, name); err != nil {
+		os.RemoveAll(filepath.Join(releases, name))
+		return "", err
+	}
+
+	keep := cfg.KeepReleases
+	if keep == 0 {
+		keep = defaultReleases
+	}
+	if err := pruneReleases(root, name, keep); err != nil {
+		return filepath.Join(releases, name), &PruneError{Err: err}
+	}
+	return filepath.Join(releases, name), nil
+}
+
+// UpdateDeployArchive is an UpdateFunction which deploys every update of a bundle resource with DeployArchive. A
+// *PruneError is given to the error handler, and Deployed is still called since the release is current.
+func UpdateDeployArchive(root string, cfg DeployConfig) UpdateFunction {
+	return func(r io.Reader) {
+		dir, err := DeployArchive(r, root, cfg)
+		if err != nil {
+			reportError(cfg.ErrorHandler, err)
+			var pruneErr *PruneError
+			if !errors.As(err, &pruneErr) {
+				return
+			}
+		}
+		if cfg.Deployed != nil {
+			cfg.Deployed(dir)
+		}
+	}
+}
+
+// CurrentRelease returns the directory of the current release of the root directory.
+func CurrentRelease(root string) (string, error) {
+	target, err := os.Readlink(filepath.Join(root, currentLink))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, target), nil
+}
+
+// Rollback makes the release before the current one current again, and returns its directory. The release which
+// was current is kept, so it is possible to roll forward by deploying it again.
+func Rollback(root string) (string, error) {
+	deployMu.Lock()
+	defer deployMu.Unlock()
+
+	current, err := CurrentRelease(root)
+	if err != nil {
+		return "", err
+	}
+	names, err := releaseNames(root)
+	if err != nil {
+		return "", err
+	}
+	i := sort.SearchStrings(names, filepath.Base(current))
+	if i == 0 {
+		return "", NoPreviousReleaseError
+	}
+	if err := swapCurrent(root, names[i-1]); err != nil {
+		return "", err
+	}
+	return filepath.Join(root, releasesDir, names[i-1]), nil
+}
+
+// verifyRelease reads the staged files back and checks their size and digest against the manifest.
+func verifyRelease(files *fileSet) error {
+	for _, entry := range files.manifest.Files {
+		b, err := ioutil.ReadFile(filepath.Join(files.dir, filepath.FromSlash(entry.Name)))
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		if int64(len(b)) != entry.Size || hex.EncodeToString(sum[:]) != entry.SHA256 {
+			return fmt.Errorf("[gosprout] %s: staged file does not match the archive", entry.Name)
+		}
+	}
+	return nil
+}
+
+// swapCurrent points root/current to the release by renaming a new symlink over it, which is atomic.
+func swapCurrent(root, name string) error {
+	tmp := filepath.Join(root, "."+currentLink+"-"+name)
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Join(releasesDir, name), tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(root, currentLink)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// pruneReleases removes the releases older than the current one and the ones kept, and any staging directories
+// left behind by an interrupted deploy.
+func pruneReleases(root, current string, keep int) error {
+	if keep < 0 {
+		keep = 0
+	}
+	entries, err := ioutil.ReadDir(filepath.Join(root, releasesDir))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), stagingPrefix) {
+			os.RemoveAll(filepath.Join(root, releasesDir, e.Name()))
+		}
+	}
+
+	names, err := releaseNames(root)
+	if err != nil {
+		return err
+	}
+	for i := sort.SearchStrings(names, current) - keep - 1; i >= 0; i-- {
+		if err := os.RemoveAll(filepath.Join(root, releasesDir, names[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseNames returns the names of the releases, which sort from oldest to newest.
+func releaseNames(root string) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(root, releasesDir))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), stagingPrefix) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,139 @@
+package gosprout
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeployArchive(t *testing.T) {
+	root, err := ioutil.TempDir("", "gosprout-deploy")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(root)
+
+	bundle := func(version string) *bytes.Reader {
+		return bytes.NewReader(tarArchive(t, []archiveEntry{
+			{name: "conf/app.yaml", body: "version: " + version + "\n"},
+			{name: "README", body: "bundle"},
+		}, true))
+	}
+	readCurrent := func() string {
+		b, err := ioutil.ReadFile(filepath.Join(root, "current", "conf", "app.yaml"))
+		if err != nil {
+			t.Fatalf("failed to read current release: %v\n", err)
+		}
+		return string(b)
+	}
+	releases := func() int {
+		names, err := releaseNames(root)
+		if err != nil {
+			t.Fatalf("failed to list releases: %v\n", err)
+		}
+		return len(names)
+	}
+
+	if _, err := Rollback(root); err == nil {
+		t.Errorf("expected an error rolling back without releases\n")
+	}
+
+	v1, err := DeployArchive(bundle("1"), root, DeployConfig{})
+	if err != nil {
+		t.Fatalf("failed to deploy: %v\n", err)
+	}
+	if current, _ := CurrentRelease(root); current != v1 || readCurrent() != "version: 1\n" {
+		t.Errorf("expected %s with version 1 to be current, but got %s\n", v1, current)
+	}
+	if info, err := os.Stat(filepath.Join(root, "current", "README")); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("expected the file mode to be kept, but got %v and %v\n", info, err)
+	}
+	if _, err := Rollback(root); err != NoPreviousReleaseError {
+		t.Errorf("expected %v, but got %v\n", NoPreviousReleaseError, err)
+	}
+
+	if again, err := DeployArchive(bundle("1"), root, DeployConfig{}); err != nil || again != v1 || releases() != 1 {
+		t.Errorf("expected the same archive to keep %s, but got %s and %v\n", v1, again, err)
+	}
+
+	v2, err := DeployArchive(bundle("2"), root, DeployConfig{})
+	if err != nil || readCurrent() != "version: 2\n" || releases() != 2 {
+		t.Errorf("expected version 2 to be current, but got %v\n", err)
+	}
+
+	verifyErr := errors.New("bad config")
+	_, err = DeployArchive(bundle("3"), root, DeployConfig{Verify: func(string) error { return verifyErr }})
+	if !errors.Is(err, verifyErr) || readCurrent() != "version: 2\n" || releases() != 2 {
+		t.Errorf("expected the failed release to be discarded, but got %v\n", err)
+	}
+	if _, err := DeployArchive(bytes.NewReader([]byte("not an archive")), root, DeployConfig{}); err == nil {
+		t.Errorf("expected an error for a bad archive\n")
+	}
+	if entries, _ := ioutil.ReadDir(filepath.Join(root, releasesDir)); len(entries) != 2 {
+		t.Errorf("expected no staging directories to be left, but got %d entries\n", len(entries))
+	}
+
+	if dir, err := Rollback(root); err != nil || dir != v1 || readCurrent() != "version: 1\n" {
+		t.Errorf("expected to roll back to %s, but got %s and %v\n", v1, dir, err)
+	}
+	if dir, err := DeployArchive(bundle("2"), root, DeployConfig{}); err != nil || dir == v2 {
+		t.Errorf("expected version 2 to be deployed as a new release, but got %s and %v\n", dir, err)
+	}
+
+	var deployed []string
+	update := UpdateDeployArchive(root, DeployConfig{
+		KeepReleases: 1,
+		Deployed:     func(dir string) { deployed = append(deployed, dir) },
+		ErrorHandler: func(e error) { t.Errorf("unexpected error: %v\n", e) },
+	})
+	update(bundle("4"))
+	update(bundle("5"))
+	if len(deployed) != 2 || readCurrent() != "version: 5\n" || releases() != 2 {
+		t.Errorf("expected version 5 and one previous release, but got %v and %d releases\n", deployed, releases())
+	}
+}
+
+func TestUpdateDeployArchive_PruneError(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions do not stop root from removing a release")
+	}
+	root, err := ioutil.TempDir("", "gosprout-deploy")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(root)
+	bundle := func(version string) *bytes.Reader {
+		return bytes.NewReader(tarArchive(t, []archiveEntry{{name: "app.yaml", body: "version: " + version + "\n"}}, true))
+	}
+
+	v1, err := DeployArchive(bundle("1"), root, DeployConfig{})
+	if err != nil {
+		t.Fatalf("failed to deploy: %v\n", err)
+	}
+	// A release with a directory which cannot be emptied cannot be removed.
+	locked := filepath.Join(v1, "locked")
+	if err := os.MkdirAll(filepath.Join(locked, "dir"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v\n", err)
+	}
+	os.Chmod(locked, 0555)
+	defer os.Chmod(locked, 0755)
+
+	var deployed []string
+	var errs []error
+	UpdateDeployArchive(root, DeployConfig{
+		KeepReleases: -1,
+		Deployed:     func(dir string) { deployed = append(deployed, dir) },
+		ErrorHandler: func(e error) { errs = append(errs, e) },
+	})(bundle("2"))
+
+	var pruneErr *PruneError
+	if len(errs) != 1 || !errors.As(errs[0], &pruneErr) {
+		t.Errorf("expected a *PruneError, but got %v\n", errs)
+	}
+	if current, _ := CurrentRelease(root); len(deployed) != 1 || deployed[0] != current || current == v1 {
+		t.Errorf("expected the new release %s to be current and deployed, but got %v\n", current, deployed)
+	}
+}
@@ -2,4 +2,12 @@ module github.com/fire00f1y/go-sprout
 
 go 1.13
 
-require cloud.google.com/go/storage v1.6.0
+require (
+	cloud.google.com/go/storage v1.6.0
+	github.com/go-zookeeper/zk v1.0.3
+	github.com/klauspost/compress v1.11.13
+	github.com/pelletier/go-toml v1.9.5
+	github.com/ulikunitz/xz v0.5.15
+	gopkg.in/ini.v1 v1.67.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -23,6 +23,10 @@ type ErrorHandler func(error)
 // called if there is any issue during processing. The provided ctx defines whether
 // to continue or not - if it is Done() then updates will be permanently stopped.
 //
+// If the resource is a resource.Notifier, its notifications are used instead of polling
+// and the interval is ignored. Errors while watching are given to the errorHandler in
+// that case, since there is no poll to fail.
+//
 // To use this, the user will need to provide its own logic of what to do with the data
 // of a resource if it is a new version. There are some basic examples defined, but
 // specific business logic will need to be provided in most cases.
@@ -33,6 +37,16 @@ func Watch(ctx context.Context,
 	errorHandler ErrorHandler) <-chan error {
 	ch := make(chan error)
 
+	if n, ok := res.(resource.Notifier); ok {
+		go func(r resource.Resource) {
+			defer close(ch)
+			for range n.Notify(ctx, errorHandler) {
+				r.Refresh(ctx, updateFunc, errorHandler)
+			}
+		}(res)
+		return ch
+	}
+
 	go func(r resource.Resource) {
 		timer := time.NewTimer(interval)
 		defer timer.Stop()
@@ -40,14 +54,16 @@ func Watch(ctx context.Context,
 			select {
 			case <-timer.C:
 				{
-					isNew, err := res.Poll(ctx)
+					isNew, err := r.Poll(ctx)
 					if err != nil {
-						ch <- err
-						continue
-					}
-					if isNew {
+						select {
+						case ch <- err:
+						case <-ctx.Done():
+						}
+					} else if isNew {
 						r.Refresh(ctx, updateFunc, errorHandler)
 					}
+					timer.Reset(interval)
 				}
 			case <-ctx.Done():
 				{
@@ -2,6 +2,7 @@ package gosprout
 
 import (
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"log"
@@ -190,3 +191,118 @@ func TestWatch_Update(t *testing.T) {
 		})
 	}
 }
+
+// This implements the Notifier interface on top of MemTest, for testing push based resources.
+type MemNotifierTest struct {
+	MemTest
+	notifications chan struct{}
+}
+
+func (m *MemNotifierTest) Poll(ctx context.Context) (bool, error) {
+	return false, errors.New("poll should not be called on a notifier")
+}
+
+func (m *MemNotifierTest) Notify(ctx context.Context, errorFunc func(error)) <-chan struct{} {
+	ch := make(chan struct{})
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case <-m.notifications:
+				ch <- struct{}{}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func TestWatch_Notifier(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	res := &MemNotifierTest{
+		MemTest:       MemTest{data: "pushed"},
+		notifications: make(chan struct{}),
+	}
+	values := make(chan string)
+	ch := Watch(ctx,
+		time.Millisecond,
+		res,
+		func(r io.Reader) {
+			b, _ := ioutil.ReadAll(r)
+			values <- string(b)
+		}, func(e error) {
+			t.Errorf("expected no errors, but got %v\n", e)
+		})
+
+	select {
+	case v := <-values:
+		t.Errorf("expected no update before a notification, but got %s\n", v)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	res.notifications <- struct{}{}
+	select {
+	case v := <-values:
+		if v != "pushed" {
+			t.Errorf("expected pushed; got %s\n", v)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("timeout hit before update received")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Errorf("expected the watch channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("watch channel not closed after cancel")
+	}
+}
+
+func TestWatch_Poll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	refreshed := make(chan struct{}, 10)
+	Watch(ctx, 10*time.Millisecond, &MemTest{data: "test"}, func(r io.Reader) {
+		refreshed <- struct{}{}
+	}, func(e error) {
+		t.Errorf("unexpected error: %v\n", e)
+	})
+
+	// The resource is polled again after every interval, not only once.
+	for i := 0; i < 3; i++ {
+		select {
+		case <-refreshed:
+		case <-time.After(time.Second):
+			t.Fatalf("expected 3 refreshes, but got %d\n", i)
+		}
+	}
+}
+
+func TestWatch_PollErrorNotReceived(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := Watch(ctx, 10*time.Millisecond, &MemTest{pollError: io.EOF}, func(r io.Reader) {
+		t.Errorf("expected no update call, but got one\n")
+	}, func(e error) {
+		t.Errorf("unexpected error: %v\n", e)
+	})
+
+	// Nobody receives the poll error, which must not keep the watch from stopping.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case err, ok := <-ch:
+		if ok {
+			t.Errorf("expected the channel to be closed once the context is done, but got %v\n", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected the channel to be closed once the context is done\n")
+	}
+}
@@ -0,0 +1,201 @@
+// The azblob package implements a resource for Azure Blob Storage. Requests are authorized with the account
+// key (SharedKey) or a SAS token, and work against the Azurite emulator through a custom endpoint.
+package azblob
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	missingAccountError   = errors.New("[gosprout] azure storage account could not be determined")
+	missingContainerError = errors.New("[gosprout] azblob path is missing a container")
+	missingBlobError      = errors.New("[gosprout] azblob path is missing a blob name")
+
+	// BlobHostSuffix is the host suffix of the public Azure blob service. A "https://" path ending in this
+	// suffix will be created as an azblob resource.
+	BlobHostSuffix = ".blob.core.windows.net"
+)
+
+// Config controls how a Resource talks to the blob service. The zero value uses the AZURE_STORAGE_CONNECTION_STRING,
+// or the AZURE_STORAGE_ACCOUNT, AZURE_STORAGE_KEY and AZURE_STORAGE_SAS_TOKEN environment variables to fill
+// in anything left empty.
+type Config struct {
+	Account string
+	// AccountKey is the base64 encoded shared key of the account. It takes precedence over SASToken.
+	AccountKey string
+	SASToken   string
+	// Endpoint is the blob service url, e.g. "http://127.0.0.1:10000/devstoreaccount1" for Azurite. When
+	// empty, "https://<account>.blob.core.windows.net" is used.
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+// Resource is a blob in Azure storage. To detect a change, we will poll on an interval with a conditional HEAD
+// request using the ETag from the last Poll. Refresh reads the blob only if it still matches that ETag.
+type Resource struct {
+	container string
+	blob      string
+	account   string
+	key       []byte
+	sas       url.Values
+	endpoint  *url.URL
+	client    *http.Client
+
+	mu          *sync.Mutex
+	lastETag    string
+	contentType string
+}
+
+// NewResource creates a blob resource from a "container/blob" path, configured from the environment.
+func NewResource(path string) (*Resource, error) {
+	return NewResourceWithConfig(path, Config{})
+}
+
+// NewResourceFromURL creates a blob resource from a full blob url without the scheme, such as
+// "account.blob.core.windows.net/container/blob". The account is taken from the host.
+func NewResourceFromURL(path string) (*Resource, error) {
+	i := strings.Index(path, "/")
+	if i < 0 {
+		return nil, missingContainerError
+	}
+	host := path[:i]
+	return NewResourceWithConfig(path[i+1:], Config{
+		Account:  strings.TrimSuffix(host, BlobHostSuffix),
+		Endpoint: "https://" + host,
+	})
+}
+
+// NewResourceWithConfig creates a blob resource from a "container/blob" path. Empty fields in the config
+// are filled in from the environment.
+func NewResourceWithConfig(path string, cfg Config) (*Resource, error) {
+	container, blob, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if cs := os.Getenv("AZURE_STORAGE_CONNECTION_STRING"); cs != "" {
+		cfg = mergeConfig(cfg, parseConnectionString(cs))
+	}
+	cfg = mergeConfig(cfg, Config{
+		Account:    os.Getenv("AZURE_STORAGE_ACCOUNT"),
+		AccountKey: os.Getenv("AZURE_STORAGE_KEY"),
+		SASToken:   os.Getenv("AZURE_STORAGE_SAS_TOKEN"),
+	})
+	if cfg.Account == "" {
+		return nil, missingAccountError
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://" + cfg.Account + BlobHostSuffix
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	sas, err := url.ParseQuery(strings.TrimPrefix(cfg.SASToken, "?"))
+	if err != nil {
+		return nil, err
+	}
+	var key []byte
+	if cfg.AccountKey != "" {
+		if key, err = decodeKey(cfg.AccountKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Resource{
+		container: container,
+		blob:      blob,
+		account:   cfg.Account,
+		key:       key,
+		sas:       sas,
+		endpoint:  endpoint,
+		client:    cfg.HTTPClient,
+		mu:        &sync.Mutex{},
+	}, nil
+}
+
+// ContentType returns the content type of the blob as of the last Poll.
+func (r *Resource) ContentType() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.contentType
+}
+
+func splitPath(path string) (container, blob string, err error) {
+	path = strings.TrimPrefix(path, "/")
+	i := strings.Index(path, "/")
+	if i <= 0 {
+		if path == "" || i == 0 {
+			return "", "", missingContainerError
+		}
+		return "", "", missingBlobError
+	}
+	container, blob = path[:i], path[i+1:]
+	if blob == "" {
+		return "", "", missingBlobError
+	}
+	return container, blob, nil
+}
+
+// mergeConfig fills the empty fields of c from d.
+func mergeConfig(c, d Config) Config {
+	if c.Account == "" {
+		c.Account = d.Account
+	}
+	if c.AccountKey == "" {
+		c.AccountKey = d.AccountKey
+	}
+	if c.SASToken == "" {
+		c.SASToken = d.SASToken
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = d.Endpoint
+	}
+	return c
+}
+
+// Well known credentials of the Azurite storage emulator.
+const (
+	devStoreAccount  = "devstoreaccount1"
+	devStoreKey      = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+	devStoreEndpoint = "http://127.0.0.1:10000/devstoreaccount1"
+)
+
+// parseConnectionString reads the blob settings out of an Azure storage connection string.
+//
+// See: https://docs.microsoft.com/en-us/azure/storage/common/storage-configure-connection-string
+func parseConnectionString(s string) Config {
+	values := map[string]string{}
+	for _, part := range strings.Split(s, ";") {
+		if i := strings.Index(part, "="); i > 0 {
+			values[strings.TrimSpace(part[:i])] = strings.TrimSpace(part[i+1:])
+		}
+	}
+	if strings.EqualFold(values["UseDevelopmentStorage"], "true") {
+		return Config{Account: devStoreAccount, AccountKey: devStoreKey, Endpoint: devStoreEndpoint}
+	}
+
+	cfg := Config{
+		Account:    values["AccountName"],
+		AccountKey: values["AccountKey"],
+		SASToken:   values["SharedAccessSignature"],
+		Endpoint:   values["BlobEndpoint"],
+	}
+	if cfg.Endpoint == "" && cfg.Account != "" && values["EndpointSuffix"] != "" {
+		protocol := values["DefaultEndpointsProtocol"]
+		if protocol == "" {
+			protocol = "https"
+		}
+		cfg.Endpoint = protocol + "://" + cfg.Account + ".blob." + values["EndpointSuffix"]
+	}
+	return cfg
+}
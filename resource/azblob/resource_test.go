@@ -0,0 +1,96 @@
+package azblob
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestNewResource(t *testing.T) {
+	tests := []struct {
+		path          string
+		container     string
+		blob          string
+		expectedError error
+	}{
+		{"container/one", "container", "one", nil},
+		{"container/dir/config.yaml", "container", "dir/config.yaml", nil},
+		{"container/", "", "", missingBlobError},
+		{"container", "", "", missingBlobError},
+		{"", "", "", missingContainerError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, err := NewResourceWithConfig(test.path, Config{Account: "account"})
+			if err != test.expectedError {
+				t.Errorf("expected error %v; got %v\n", test.expectedError, err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if res.container != test.container || res.blob != test.blob {
+				t.Errorf("expected %s/%s; got %s/%s\n", test.container, test.blob, res.container, res.blob)
+			}
+		})
+	}
+}
+
+func TestNewResourceFromURL(t *testing.T) {
+	res, err := NewResourceFromURL("myaccount.blob.core.windows.net/configs/app.json")
+	if err != nil {
+		t.Errorf("error creating new resource: %v\n", err)
+		return
+	}
+	if res.account != "myaccount" {
+		t.Errorf("expected account myaccount; got %s\n", res.account)
+	}
+	expected := "https://myaccount.blob.core.windows.net/configs/app.json"
+	if u := res.blobURL().String(); u != expected {
+		t.Errorf("expected %s; got %s\n", expected, u)
+	}
+}
+
+func TestParseConnectionString(t *testing.T) {
+	tests := []struct {
+		cs       string
+		expected Config
+	}{
+		{
+			cs:       "UseDevelopmentStorage=true",
+			expected: Config{Account: devStoreAccount, AccountKey: devStoreKey, Endpoint: devStoreEndpoint},
+		},
+		{
+			cs:       "DefaultEndpointsProtocol=https;AccountName=acct;AccountKey=a2V5;EndpointSuffix=core.windows.net",
+			expected: Config{Account: "acct", AccountKey: "a2V5", Endpoint: "https://acct.blob.core.windows.net"},
+		},
+		{
+			cs:       "BlobEndpoint=http://localhost:10000/acct;SharedAccessSignature=sv=2019&sig=abc",
+			expected: Config{SASToken: "sv=2019&sig=abc", Endpoint: "http://localhost:10000/acct"},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if c := parseConnectionString(test.cs); c != test.expected {
+				t.Errorf("expected %+v; got %+v\n", test.expected, c)
+			}
+		})
+	}
+}
+
+func TestStringToSign(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:10000/devstoreaccount1/container/blob?comp=metadata&Timeout=30", nil)
+	req.Header.Set("If-Match", `"0x8D7"`)
+	req.Header.Set("x-ms-version", apiVersion)
+	req.Header.Set("x-ms-date", "Fri, 26 Jun 2015 23:39:12 GMT")
+
+	expected := "GET\n\n\n\n\n\n\n\n\"0x8D7\"\n\n\n\n" +
+		"x-ms-date:Fri, 26 Jun 2015 23:39:12 GMT\n" +
+		"x-ms-version:" + apiVersion + "\n" +
+		"/devstoreaccount1/devstoreaccount1/container/blob\ncomp:metadata\ntimeout:30"
+	if s := stringToSign(req, devStoreAccount); s != expected {
+		t.Errorf("expected %q; got %q\n", expected, s)
+	}
+}
@@ -0,0 +1,82 @@
+package azblob
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const apiVersion = "2019-12-12"
+
+// signedStandardHeaders are the headers which are part of the SharedKey string to sign, in order.
+var signedStandardHeaders = []string{
+	"Content-Encoding",
+	"Content-Language",
+	"Content-Length",
+	"Content-MD5",
+	"Content-Type",
+	"Date",
+	"If-Modified-Since",
+	"If-Match",
+	"If-None-Match",
+	"If-Unmodified-Since",
+	"Range",
+}
+
+func decodeKey(key string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(key)
+}
+
+// sign adds a SharedKey Authorization header to the request. Only requests without a body are made by this
+// package, so Content-Length is always left empty.
+//
+// See: https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+func sign(req *http.Request, account string, key []byte, now time.Time) {
+	req.Header.Set("x-ms-date", now.UTC().Format(http.TimeFormat))
+
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(stringToSign(req, account)))
+	req.Header.Set("Authorization", "SharedKey "+account+":"+base64.StdEncoding.EncodeToString(h.Sum(nil)))
+}
+
+func stringToSign(req *http.Request, account string) string {
+	lines := []string{req.Method}
+	for _, name := range signedStandardHeaders {
+		lines = append(lines, req.Header.Get(name))
+	}
+
+	var msHeaders []string
+	for name := range req.Header {
+		if n := strings.ToLower(name); strings.HasPrefix(n, "x-ms-") {
+			msHeaders = append(msHeaders, n)
+		}
+	}
+	sort.Strings(msHeaders)
+	for _, n := range msHeaders {
+		lines = append(lines, n+":"+strings.TrimSpace(req.Header.Get(n)))
+	}
+
+	resource := "/" + account + req.URL.EscapedPath()
+	query := map[string][]string{}
+	for k, v := range req.URL.Query() {
+		k = strings.ToLower(k)
+		query[k] = append(query[k], v...)
+	}
+	params := make([]string, 0, len(query))
+	for k := range query {
+		params = append(params, k)
+	}
+	sort.Strings(params)
+	for _, k := range params {
+		values := query[k]
+		sort.Strings(values)
+		resource += "\n" + k + ":" + strings.Join(values, ",")
+	}
+	lines = append(lines, resource)
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,144 @@
+package azblob
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// BlobChangedError is given to the error handler if the blob was modified between the Poll and the Refresh.
+	// The next Poll will detect the new version.
+	BlobChangedError = errors.New("[gosprout] blob changed since it was last polled")
+)
+
+// Poll sends a HEAD request for the blob with If-None-Match set to the ETag from the last Poll, so an unchanged
+// blob is answered with 304 Not Modified. The Content-Type is stored so it can be referenced when deciding how
+// to use the reader provided.
+func (r *Resource) Poll(ctx context.Context) (bool, error) {
+	r.mu.Lock()
+	etag := r.lastETag
+	r.mu.Unlock()
+
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	resp, err := r.do(ctx, http.MethodHead, header)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, responseError(http.MethodHead, resp)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := resp.Header.Get("ETag") != r.lastETag
+	r.lastETag = resp.Header.Get("ETag")
+	r.contentType = resp.Header.Get("Content-Type")
+	return changed, nil
+}
+
+// Refresh provides a reader for the blob. The read is conditional on the ETag seen by the last Poll, so the
+// data always matches what was detected; if the blob changed in between, BlobChangedError is reported instead.
+// The body is closed after the update func returns, and the reader carries the blob's metadata.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	r.mu.Lock()
+	etag := r.lastETag
+	r.mu.Unlock()
+
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-Match", etag)
+	}
+	resp, err := r.do(ctx, http.MethodGet, header)
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+	defer func() {
+		if e := resp.Body.Close(); e != nil {
+			errorHandler(e)
+		}
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		md := meta.Metadata{
+			Name:        r.blob,
+			ContentType: resp.Header.Get("Content-Type"),
+			Encoding:    resp.Header.Get("Content-Encoding"),
+			Size:        resp.ContentLength,
+			Version:     resp.Header.Get("ETag"),
+			Attributes:  meta.AttributesFromHeader(resp.Header, "x-ms-meta-"),
+		}
+		// The transport gunzips an object stored with the gzip encoding, and the checksums are of the stored bytes.
+		if !resp.Uncompressed {
+			md.Checksums = meta.ChecksumsFromHeader(resp.Header)
+		}
+		updateFunc(meta.NewReader(resp.Body, md))
+	case http.StatusPreconditionFailed:
+		errorHandler(BlobChangedError)
+	default:
+		errorHandler(responseError(http.MethodGet, resp))
+	}
+}
+
+func (r *Resource) do(ctx context.Context, method string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, r.blobURL().String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("x-ms-version", apiVersion)
+	if r.key != nil {
+		sign(req, r.account, r.key, time.Now())
+	}
+	return r.client.Do(req)
+}
+
+func (r *Resource) blobURL() *url.URL {
+	u := *r.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + r.container + "/" + r.blob
+	if r.key == nil && len(r.sas) > 0 {
+		u.RawQuery = r.sas.Encode()
+	}
+	return &u
+}
+
+// errorResponse is the XML body the blob service returns alongside a failed request.
+type errorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func responseError(method string, resp *http.Response) error {
+	code := resp.Header.Get("x-ms-error-code")
+	var e errorResponse
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if xml.Unmarshal(b, &e) == nil && e.Code != "" {
+		return fmt.Errorf("[gosprout] azblob %s failed with %s: %s: %s", method, resp.Status, e.Code, e.Message)
+	}
+	if code != "" {
+		return fmt.Errorf("[gosprout] azblob %s failed with %s: %s", method, resp.Status, code)
+	}
+	return fmt.Errorf("[gosprout] azblob %s failed with %s", method, resp.Status)
+}
@@ -0,0 +1,226 @@
+package azblob
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakeBlobService is an httptest stand-in for the blob service. It checks the SharedKey signature of every
+// request and honors If-Match and If-None-Match.
+type fakeBlobService struct {
+	mu    sync.Mutex
+	blobs map[string]string
+	etags map[string]int
+}
+
+func newFakeBlobService() (*fakeBlobService, *httptest.Server) {
+	f := &fakeBlobService{blobs: map[string]string{}, etags: map[string]int{}}
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeBlobService) put(path, data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.blobs[path] = data
+	f.etags[path]++
+}
+
+func (f *fakeBlobService) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key, _ := decodeKey(devStoreKey)
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(stringToSign(req, devStoreAccount)))
+	if req.Header.Get("Authorization") != "SharedKey "+devStoreAccount+":"+base64.StdEncoding.EncodeToString(h.Sum(nil)) {
+		w.Header().Set("x-ms-error-code", "AuthenticationFailed")
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	data, ok := f.blobs[req.URL.Path]
+	if !ok {
+		w.Header().Set("x-ms-error-code", "BlobNotFound")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	etag := fmt.Sprintf(`"0x%d"`, f.etags[req.URL.Path])
+	if m := req.Header.Get("If-Match"); m != "" && m != etag {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if req.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		io.WriteString(w, data)
+	}
+}
+
+func newTestResource(url, path string) *Resource {
+	res, _ := NewResourceWithConfig(path, Config{
+		Account:    devStoreAccount,
+		AccountKey: devStoreKey,
+		Endpoint:   url + "/" + devStoreAccount,
+	})
+	return res
+}
+
+func TestResource_Poll(t *testing.T) {
+	fake, server := newFakeBlobService()
+	defer server.Close()
+	fake.put("/devstoreaccount1/configs/app.json", `{"a":1}`)
+	res := newTestResource(server.URL, "configs/app.json")
+
+	tests := []struct {
+		put     string
+		updated bool
+	}{
+		{put: "", updated: true},
+		{put: "", updated: false},
+		{put: `{"a":2}`, updated: true},
+		{put: "", updated: false},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if test.put != "" {
+				fake.put("/devstoreaccount1/configs/app.json", test.put)
+			}
+			updated, err := res.Poll(context.Background())
+			if err != nil {
+				t.Errorf("error polling: %v\n", err)
+			}
+			if updated != test.updated {
+				t.Errorf("expected updated to be %v; got %v\n", test.updated, updated)
+			}
+		})
+	}
+
+	if ct := res.ContentType(); ct != "application/json" {
+		t.Errorf("expected content type application/json; got %s\n", ct)
+	}
+}
+
+func TestResource_PollMissing(t *testing.T) {
+	_, server := newFakeBlobService()
+	defer server.Close()
+	res := newTestResource(server.URL, "configs/missing.json")
+
+	updated, err := res.Poll(context.Background())
+	if updated || err == nil {
+		t.Errorf("expected error and no update; got %v, %v\n", updated, err)
+	}
+}
+
+func TestResource_Refresh(t *testing.T) {
+	tests := []struct {
+		changeAfterPoll bool
+		expected        string
+		expectedError   error
+	}{
+		{changeAfterPoll: false, expected: "first", expectedError: nil},
+		{changeAfterPoll: true, expected: "", expectedError: BlobChangedError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			fake, server := newFakeBlobService()
+			defer server.Close()
+			fake.put("/devstoreaccount1/configs/app.json", "first")
+			res := newTestResource(server.URL, "configs/app.json")
+
+			if _, err := res.Poll(context.Background()); err != nil {
+				t.Errorf("error polling: %v\n", err)
+				return
+			}
+			if test.changeAfterPoll {
+				fake.put("/devstoreaccount1/configs/app.json", "second")
+			}
+
+			value := ""
+			var err error
+			res.Refresh(context.Background(),
+				func(r io.Reader) {
+					b, _ := ioutil.ReadAll(r)
+					value = string(b)
+				}, func(e error) {
+					err = e
+				})
+			if value != test.expected {
+				t.Errorf("expected %s; got %s\n", test.expected, value)
+			}
+			if err != test.expectedError {
+				t.Errorf("expected error %v; got %v\n", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestResource_RefreshGzip(t *testing.T) {
+	const data = `{"a":1}`
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	io.WriteString(w, data)
+	w.Close()
+
+	tests := []struct {
+		body      []byte
+		encoding  string
+		checksums bool
+	}{
+		{body: []byte(data), checksums: true},
+		{body: gz.Bytes(), encoding: "gzip", checksums: false},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			sum := md5.Sum(test.body)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("ETag", `"1"`)
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+				if test.encoding != "" {
+					w.Header().Set("Content-Encoding", test.encoding)
+				}
+				w.Write(test.body)
+			}))
+			defer server.Close()
+			res := newTestResource(server.URL, "configs/app.json")
+
+			value := ""
+			var m meta.Metadata
+			res.Refresh(context.Background(),
+				func(r io.Reader) {
+					m, _ = meta.Of(r)
+					b, _ := ioutil.ReadAll(r)
+					value = string(b)
+				}, func(e error) {
+					t.Errorf("error during refresh: %v\n", e)
+				})
+			if value != data {
+				t.Errorf("expected %s; got %s\n", data, value)
+			}
+			if _, ok := m.Checksums["md5"]; ok != test.checksums {
+				t.Errorf("expected checksums %v; got %v\n", test.checksums, m.Checksums)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// The cache package implements a wrapper which keeps the last known good contents of any resource on disk, so a
+// process which starts while the source is unavailable still gets the data it last applied.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/fire00f1y/go-sprout/resource"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	corruptCacheError = errors.New("[gosprout] cached data does not match its metadata")
+
+	defaultName = "resource"
+)
+
+// Config controls where the cache is kept.
+type Config struct {
+	// Dir is the directory holding the cache. It defaults to "gosprout" in the user cache directory.
+	Dir string
+	// Name is the base name of the cache files, which must be unique within Dir. It defaults to "resource".
+	Name string
+}
+
+// Metadata describes the cached data. It is written next to the data, and the digest is checked when it is read.
+type Metadata struct {
+	Digest  string    `json:"digest"`
+	Size    int64     `json:"size"`
+	SavedAt time.Time `json:"saved_at"`
+	// The metadata of the source when the data was saved, if it provided any.
+	ContentType string            `json:"content_type,omitempty"`
+	Encoding    string            `json:"encoding,omitempty"`
+	Version     string            `json:"version,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+}
+
+// Resource wraps another resource. Every payload the source provides is written to the cache once the update func
+// returns, and the cached copy is provided instead when the source fails to Refresh. An update func created with
+// UpdateFunc can reject a payload, so one which fails to decode or apply never becomes the last known good copy.
+type Resource struct {
+	resource.Resource
+	dataPath string
+	metaPath string
+
+	mu     *sync.Mutex
+	served bool
+}
+
+// NewResource wraps the resource with a cache, creating the cache directory if needed.
+func NewResource(r resource.Resource, cfg Config) (*Resource, error) {
+	if cfg.Dir == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Dir = filepath.Join(dir, "gosprout")
+	}
+	if cfg.Name == "" {
+		cfg.Name = defaultName
+	}
+	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &Resource{
+		Resource: r,
+		dataPath: filepath.Join(cfg.Dir, cfg.Name+".data"),
+		metaPath: filepath.Join(cfg.Dir, cfg.Name+".meta.json"),
+		mu:       &sync.Mutex{},
+	}, nil
+}
+
+// Metadata returns the metadata of the cached data, or false if nothing valid is cached.
+func (r *Resource) Metadata() (Metadata, bool) {
+	_, meta, err := r.load()
+	return meta, err == nil
+}
+
+// load reads the cached data and checks it against its metadata.
+func (r *Resource) load() ([]byte, Metadata, error) {
+	var m Metadata
+	b, err := ioutil.ReadFile(r.metaPath)
+	if err != nil {
+		return nil, m, err
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, m, err
+	}
+	data, err := ioutil.ReadFile(r.dataPath)
+	if err != nil {
+		return nil, m, err
+	}
+	if digest(data) != m.Digest || int64(len(data)) != m.Size {
+		return nil, m, corruptCacheError
+	}
+	return data, m, nil
+}
+
+// save writes the data and then its metadata. Each file is replaced atomically, and a reader never trusts data
+// which does not match the metadata, so an interrupted save leaves either the old or the new cache in place.
+func (r *Resource) save(data []byte, source meta.Metadata) error {
+	metadata, err := json.Marshal(Metadata{
+		Digest:      digest(data),
+		Size:        int64(len(data)),
+		SavedAt:     time.Now().UTC(),
+		ContentType: source.ContentType,
+		Encoding:    source.Encoding,
+		Version:     source.Version,
+		Attributes:  source.Attributes,
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeAtomic(r.dataPath, data); err != nil {
+		return err
+	}
+	return writeAtomic(r.metaPath, metadata)
+}
+
+// writeAtomic writes to a temporary file in the same directory, and renames it over the path once it is synced.
+func writeAtomic(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"io"
+	"io/ioutil"
+)
+
+// Poll polls the source. If it fails before any data was provided by this resource, e.g. when the process starts
+// while the source is down, an update is reported instead so the cached copy is provided by Refresh.
+func (r *Resource) Poll(ctx context.Context) (bool, error) {
+	changed, err := r.Resource.Poll(ctx)
+	if err == nil {
+		return changed, nil
+	}
+
+	r.mu.Lock()
+	served := r.served
+	r.mu.Unlock()
+	if !served {
+		if _, _, e := r.load(); e == nil {
+			return true, nil
+		}
+	}
+	return false, err
+}
+
+// confirmReader is the reader given to the update func, which UpdateFunc records the outcome of the update on.
+type confirmReader struct {
+	meta.Reader
+	err error
+}
+
+// UpdateFunc wraps an update func which returns an error when it did not apply the data, to be used as the update
+// func of this resource. Data the update rejects is not saved to the cache, and the error is given to the error
+// handler.
+func (r *Resource) UpdateFunc(update func(io.Reader) error) func(io.Reader) {
+	return func(reader io.Reader) {
+		err := update(reader)
+		if c, ok := reader.(*confirmReader); ok {
+			c.err = err
+		}
+	}
+}
+
+// Refresh reads the data from the source and provides it to the update func. If the source did not report any
+// error, the data is saved to the cache once the update func returns, unless an update func from UpdateFunc
+// rejected it. If the source fails before providing the data, the cached copy is provided instead and the error is
+// still given to the error handler, so the failure is not hidden.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	var data []byte
+	var metadata meta.Metadata
+	var readErr, sourceErr error
+	delivered := false
+	r.Resource.Refresh(ctx, func(reader io.Reader) {
+		metadata, _ = meta.Of(reader)
+		data, readErr = ioutil.ReadAll(reader)
+		delivered = readErr == nil
+	}, func(e error) {
+		if e != nil && sourceErr == nil {
+			sourceErr = e
+		}
+	})
+
+	if !delivered {
+		err := sourceErr
+		if readErr != nil {
+			err = readErr
+		}
+		if err != nil {
+			r.refreshFromCache(updateFunc)
+			errorHandler(err)
+		}
+		return
+	}
+
+	metadata.Size = int64(len(data))
+	reader := &confirmReader{Reader: meta.NewReader(bytes.NewReader(data), metadata)}
+	updateFunc(reader)
+	r.setServed()
+	if sourceErr != nil {
+		errorHandler(sourceErr)
+		return
+	}
+	if reader.err != nil {
+		errorHandler(fmt.Errorf("[gosprout] data was not applied, so it was not cached: %w", reader.err))
+		return
+	}
+	if e := r.save(data, metadata); e != nil {
+		errorHandler(fmt.Errorf("[gosprout] failed to save cache: %w", e))
+	}
+}
+
+func (r *Resource) refreshFromCache(updateFunc func(io.Reader)) {
+	data, m, err := r.load()
+	if err != nil {
+		return
+	}
+	updateFunc(meta.NewReader(bytes.NewReader(data), meta.Metadata{
+		ContentType: m.ContentType,
+		Encoding:    m.Encoding,
+		Size:        m.Size,
+		Version:     m.Version,
+		Checksums:   map[string]string{"sha256": m.Digest},
+		Attributes:  m.Attributes,
+	}))
+	r.setServed()
+}
+
+func (r *Resource) setServed() {
+	r.mu.Lock()
+	r.served = true
+	r.mu.Unlock()
+}
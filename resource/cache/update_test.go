@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/fire00f1y/go-sprout/resource/mem"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestResource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosprout-cache")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	down := errors.New("unreachable")
+
+	source := mem.NewResource([]byte("v1"))
+	r, err := NewResource(source, Config{Dir: dir, Name: "app"})
+	if err != nil {
+		t.Fatalf("failed to create resource: %v\n", err)
+	}
+	if _, ok := r.Metadata(); ok {
+		t.Errorf("expected nothing to be cached\n")
+	}
+	if changed, err := r.Poll(ctx); !changed || err != nil {
+		t.Errorf("expected a change, but got %v and %v\n", changed, err)
+	}
+	data, errs := refresh(r)
+	if data != "v1" || len(errs) != 0 {
+		t.Errorf("expected v1 and no errors, but got %q and %v\n", data, errs)
+	}
+	meta, ok := r.Metadata()
+	if !ok || meta.Size != 2 || meta.Digest != digest([]byte("v1")) {
+		t.Errorf("expected v1 to be cached, but got %v\n", meta)
+	}
+
+	// A failed Refresh provides the cached copy and still reports the error.
+	source.SetError(down)
+	data, errs = refresh(r)
+	if data != "v1" || len(errs) != 1 || errs[0] != down {
+		t.Errorf("expected v1 and the source error, but got %q and %v\n", data, errs)
+	}
+
+	// A new process with the source down starts from the cache.
+	restarted, err := NewResource(source, Config{Dir: dir, Name: "app"})
+	if err != nil {
+		t.Fatalf("failed to create resource: %v\n", err)
+	}
+	if changed, err := restarted.Poll(ctx); !changed || err != nil {
+		t.Errorf("expected a change from the cache, but got %v and %v\n", changed, err)
+	}
+	data, _ = refresh(restarted)
+	if data != "v1" {
+		t.Errorf("expected v1 from the cache, but got %q\n", data)
+	}
+	if _, err := restarted.Poll(ctx); err != down {
+		t.Errorf("expected the source error once the cache was served, but got %v\n", err)
+	}
+
+	// A corrupt cache is not served.
+	if err := ioutil.WriteFile(filepath.Join(dir, "app.data"), []byte("tampered"), 0600); err != nil {
+		t.Fatalf("failed to write cache: %v\n", err)
+	}
+	if _, ok := r.Metadata(); ok {
+		t.Errorf("expected the corrupt cache to be invalid\n")
+	}
+	data, errs = refresh(r)
+	if data != "" || len(errs) != 1 {
+		t.Errorf("expected only the source error, but got %q and %v\n", data, errs)
+	}
+
+	// The source recovering replaces the cache.
+	source.Set([]byte("v2"))
+	data, errs = refresh(r)
+	if data != "v2" || len(errs) != 0 {
+		t.Errorf("expected v2 and no errors, but got %q and %v\n", data, errs)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "app.data")); string(b) != "v2" {
+		t.Errorf("expected v2 to be cached, but got %q\n", b)
+	}
+	entries, _ := ioutil.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("expected only the data and metadata files, but got %d files\n", len(entries))
+	}
+}
+
+func refresh(r *Resource) (string, []error) {
+	var data string
+	var errs []error
+	r.Refresh(context.Background(), r.UpdateFunc(func(reader io.Reader) error {
+		b, _ := ioutil.ReadAll(reader)
+		data = string(b)
+		return nil
+	}), func(e error) {
+		errs = append(errs, e)
+	})
+	return data, errs
+}
+
+func TestResource_UpdateRejected(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosprout-cache")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	invalid := errors.New("invalid config")
+
+	source := mem.NewResource([]byte("v1"))
+	r, err := NewResource(source, Config{Dir: dir, Name: "app"})
+	if err != nil {
+		t.Fatalf("failed to create resource: %v\n", err)
+	}
+	if _, errs := refresh(r); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v\n", errs)
+	}
+
+	tests := []struct {
+		data   string
+		update func(io.Reader)
+		err    error
+		cached string
+	}{
+		{
+			data: "bad",
+			update: r.UpdateFunc(func(io.Reader) error {
+				return invalid
+			}),
+			err:    invalid,
+			cached: "v1",
+		},
+		{
+			data:   "plain",
+			update: func(io.Reader) {},
+			cached: "plain",
+		},
+		{
+			data: "good",
+			update: r.UpdateFunc(func(io.Reader) error {
+				return nil
+			}),
+			cached: "good",
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			source.Set([]byte(test.data))
+			var errs []error
+			r.Refresh(context.Background(), test.update, func(e error) {
+				errs = append(errs, e)
+			})
+			if test.err == nil && len(errs) != 0 {
+				t.Errorf("unexpected errors: %v\n", errs)
+			}
+			if test.err != nil && (len(errs) != 1 || !errors.Is(errs[0], test.err)) {
+				t.Errorf("expected %v; got %v\n", test.err, errs)
+			}
+			if b, _ := ioutil.ReadFile(filepath.Join(dir, "app.data")); string(b) != test.cached {
+				t.Errorf("expected %q to be cached; got %q\n", test.cached, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package composite
+
+import (
+	"fmt"
+)
+
+// merge deep-merges src over dst and returns the result. Neither is modified.
+func merge(dst, src interface{}, arrays ArrayStrategy) interface{} {
+	switch s := src.(type) {
+	case map[string]interface{}:
+		d, ok := dst.(map[string]interface{})
+		if !ok {
+			return s
+		}
+		out := make(map[string]interface{}, len(d)+len(s))
+		for k, v := range d {
+			out[k] = v
+		}
+		for k, v := range s {
+			if v == nil {
+				delete(out, k)
+				continue
+			}
+			if existing, ok := out[k]; ok {
+				out[k] = merge(existing, v, arrays)
+			} else {
+				out[k] = v
+			}
+		}
+		return out
+	case []interface{}:
+		d, ok := dst.([]interface{})
+		if !ok {
+			return s
+		}
+		switch arrays {
+		case ArrayAppend:
+			out := make([]interface{}, 0, len(d)+len(s))
+			return append(append(out, d...), s...)
+		case ArrayMergeByIndex:
+			out := make([]interface{}, len(d))
+			copy(out, d)
+			for i, v := range s {
+				if i < len(out) {
+					out[i] = merge(out[i], v, arrays)
+				} else {
+					out = append(out, v)
+				}
+			}
+			return out
+		default:
+			return s
+		}
+	default:
+		return src
+	}
+}
+
+// normalize converts the maps decoded from YAML to map[string]interface{}, so every document merges (and encodes
+// to JSON) the same way.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			out[fmt.Sprint(k)] = normalize(e)
+		}
+		return out
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = normalize(e)
+		}
+		return t
+	case []interface{}:
+		for i, e := range t {
+			t[i] = normalize(e)
+		}
+		return t
+	default:
+		return v
+	}
+}
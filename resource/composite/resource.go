@@ -0,0 +1,81 @@
+// The composite package implements a resource made of several layered resources, such as a base config, a region
+// overlay and a local override file. Each layer is a JSON or YAML document, and they are deep-merged into one
+// document for the update func.
+package composite
+
+import (
+	"errors"
+	"github.com/fire00f1y/go-sprout/resource"
+	"sync"
+)
+
+var (
+	noLayersError = errors.New("[gosprout] composite resource has no layers")
+)
+
+// Format is the encoding of the merged document.
+type Format int
+
+const (
+	JSON Format = iota
+	YAML
+)
+
+// ArrayStrategy is how an array in a layer is merged with the array at the same place in the layers below it.
+type ArrayStrategy int
+
+const (
+	// ArrayReplace uses the array from the higher layer as-is.
+	ArrayReplace ArrayStrategy = iota
+	// ArrayAppend appends the array from the higher layer to the one below.
+	ArrayAppend
+	// ArrayMergeByIndex deep-merges the elements at the same index, keeping any extra elements of either.
+	ArrayMergeByIndex
+)
+
+// Layer is one of the documents of a composite resource.
+type Layer struct {
+	resource.Resource
+	// Optional layers which fail to Poll or Refresh are left out of the merge instead of failing it, e.g. for a
+	// local override file which does not exist on every host.
+	Optional bool
+}
+
+// Config controls how the layers are merged.
+type Config struct {
+	Output Format
+	Arrays ArrayStrategy
+}
+
+// Resource merges its layers in order, so a later layer takes precedence over an earlier one. Maps are merged
+// key by key, arrays according to the ArrayStrategy, and any other value is replaced. A null value in a higher
+// layer removes the key.
+type Resource struct {
+	layers []Layer
+	cfg    Config
+
+	mu     *sync.Mutex
+	failed []bool
+}
+
+// NewResource creates a composite resource with the layers from lowest to highest precedence.
+func NewResource(cfg Config, layers ...Layer) (*Resource, error) {
+	if len(layers) == 0 {
+		return nil, noLayersError
+	}
+	return &Resource{
+		layers: layers,
+		cfg:    cfg,
+		mu:     &sync.Mutex{},
+		failed: make([]bool, len(layers)),
+	}, nil
+}
+
+// Layers wraps the resources as required layers, from lowest to highest precedence.
+func Layers(resources ...resource.Resource) []Layer {
+	layers := make([]Layer, len(resources))
+	for i, r := range resources {
+		layers[i] = Layer{Resource: r}
+	}
+	return layers
+}
@@ -0,0 +1,109 @@
+package composite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+)
+
+// Poll polls every layer and reports an update if any of them changed. A required layer which fails makes the
+// Poll fail; an optional one is left out of the next merge, which is reported as an update when that changes.
+func (r *Resource) Poll(ctx context.Context) (bool, error) {
+	changed := false
+	var firstErr error
+	for i, l := range r.layers {
+		c, err := l.Poll(ctx)
+		if err != nil && !l.Optional && firstErr == nil {
+			firstErr = fmt.Errorf("[gosprout] composite layer %d: %w", i, err)
+		}
+
+		failed := err != nil
+		r.mu.Lock()
+		if failed != r.failed[i] {
+			c = true
+		}
+		r.failed[i] = failed
+		r.mu.Unlock()
+		changed = changed || c
+	}
+	if firstErr != nil {
+		return false, firstErr
+	}
+	return changed, nil
+}
+
+// Refresh reads every layer, merges them and provides a reader for the merged document. If a required layer
+// cannot be read or decoded, the error handler is called and the update func is not.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	var merged interface{}
+	for i, l := range r.layers {
+		doc, err := readLayer(ctx, l)
+		if err != nil {
+			if !l.Optional {
+				errorHandler(fmt.Errorf("[gosprout] composite layer %d: %w", i, err))
+				return
+			}
+			continue
+		}
+		if doc == nil {
+			continue
+		}
+		if merged == nil {
+			merged = doc
+		} else {
+			merged = merge(merged, doc, r.cfg.Arrays)
+		}
+	}
+	if merged == nil {
+		merged = map[string]interface{}{}
+	}
+
+	var out []byte
+	var err error
+	contentType := "application/json"
+	switch r.cfg.Output {
+	case YAML:
+		contentType = "application/yaml"
+		out, err = yaml.Marshal(merged)
+	default:
+		out, err = json.Marshal(merged)
+	}
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+	updateFunc(meta.NewReader(bytes.NewReader(out), meta.Metadata{
+		ContentType: contentType,
+		Size:        int64(len(out)),
+	}))
+}
+
+// readLayer refreshes a layer and decodes it. Since JSON is valid YAML, both are decoded with the YAML decoder.
+func readLayer(ctx context.Context, l Layer) (interface{}, error) {
+	var data []byte
+	var readErr, refreshErr error
+	l.Refresh(ctx, func(r io.Reader) {
+		data, readErr = ioutil.ReadAll(r)
+	}, func(e error) {
+		if e != nil && refreshErr == nil {
+			refreshErr = e
+		}
+	})
+	if refreshErr != nil {
+		return nil, refreshErr
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return normalize(doc), nil
+}
@@ -0,0 +1,196 @@
+package composite
+
+import (
+	"context"
+	"errors"
+	"github.com/fire00f1y/go-sprout/resource/mem"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		dst      interface{}
+		src      interface{}
+		arrays   ArrayStrategy
+		expected interface{}
+	}{
+		{
+			dst:      map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": 2, "d": 3}},
+			src:      map[string]interface{}{"b": map[string]interface{}{"d": 4}, "e": 5},
+			expected: map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": 2, "d": 4}, "e": 5},
+		},
+		{
+			dst:      map[string]interface{}{"a": 1, "b": 2},
+			src:      map[string]interface{}{"a": nil},
+			expected: map[string]interface{}{"b": 2},
+		},
+		{
+			dst:      map[string]interface{}{"a": map[string]interface{}{"b": 1}},
+			src:      map[string]interface{}{"a": "scalar"},
+			expected: map[string]interface{}{"a": "scalar"},
+		},
+		{
+			dst:      []interface{}{1, 2},
+			src:      []interface{}{3},
+			arrays:   ArrayReplace,
+			expected: []interface{}{3},
+		},
+		{
+			dst:      []interface{}{1, 2},
+			src:      []interface{}{3},
+			arrays:   ArrayAppend,
+			expected: []interface{}{1, 2, 3},
+		},
+		{
+			dst:      []interface{}{map[string]interface{}{"a": 1, "b": 2}, 5},
+			src:      []interface{}{map[string]interface{}{"b": 3}, 6, 7},
+			arrays:   ArrayMergeByIndex,
+			expected: []interface{}{map[string]interface{}{"a": 1, "b": 3}, 6, 7},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			actual := merge(test.dst, test.src, test.arrays)
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %v, but got %v\n", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestResource_Refresh(t *testing.T) {
+	base := `{"name": "app", "servers": ["a", "b"], "db": {"host": "localhost", "port": 5432}}`
+	region := "db:\n  host: db.eu\nservers: [c]\n"
+
+	tests := []struct {
+		layers   []Layer
+		cfg      Config
+		expected string
+		err      bool
+	}{
+		{
+			layers:   Layers(mem.NewResource([]byte(base)), mem.NewResource([]byte(region))),
+			expected: `{"db":{"host":"db.eu","port":5432},"name":"app","servers":["c"]}`,
+		},
+		{
+			layers:   Layers(mem.NewResource([]byte(base)), mem.NewResource([]byte(region))),
+			cfg:      Config{Arrays: ArrayAppend},
+			expected: `{"db":{"host":"db.eu","port":5432},"name":"app","servers":["a","b","c"]}`,
+		},
+		{
+			layers:   Layers(mem.NewResource([]byte(region)), mem.NewResource([]byte(base))),
+			expected: `{"db":{"host":"localhost","port":5432},"name":"app","servers":["a","b"]}`,
+		},
+		{
+			layers:   Layers(mem.NewResource([]byte(`{"a": 1}`)), mem.NewResource(nil)),
+			expected: `{"a":1}`,
+		},
+		{
+			layers:   Layers(mem.NewResource([]byte(`{"a": 1}`))),
+			cfg:      Config{Output: YAML},
+			expected: "a: 1\n",
+		},
+		{
+			layers: []Layer{
+				{Resource: mem.NewResource([]byte(`{"a": 1}`))},
+				{Resource: failing(), Optional: true},
+			},
+			expected: `{"a":1}`,
+		},
+		{
+			layers: Layers(mem.NewResource([]byte(`{"a": 1}`)), failing()),
+			err:    true,
+		},
+		{
+			layers: Layers(mem.NewResource([]byte(`{"a": [}`))),
+			err:    true,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			r, err := NewResource(test.cfg, test.layers...)
+			if err != nil {
+				t.Fatalf("failed to create resource: %v\n", err)
+			}
+
+			var actual string
+			var refreshErr error
+			r.Refresh(context.Background(), func(reader io.Reader) {
+				b, _ := ioutil.ReadAll(reader)
+				actual = string(b)
+			}, func(e error) {
+				refreshErr = e
+			})
+
+			if test.err {
+				if refreshErr == nil {
+					t.Errorf("expected an error, but got none\n")
+				}
+				return
+			}
+			if refreshErr != nil {
+				t.Fatalf("unexpected error: %v\n", refreshErr)
+			}
+			if actual != test.expected {
+				t.Errorf("expected %q, but got %q\n", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestResource_Poll(t *testing.T) {
+	base := mem.NewResource([]byte(`{"a": 1}`))
+	override := mem.NewResource([]byte(`{"b": 2}`))
+	r, err := NewResource(Config{}, Layer{Resource: base}, Layer{Resource: override, Optional: true})
+	if err != nil {
+		t.Fatalf("failed to create resource: %v\n", err)
+	}
+	ctx := context.Background()
+
+	steps := []struct {
+		change   func()
+		expected bool
+		err      bool
+	}{
+		{change: func() {}, expected: true},
+		{change: func() {}, expected: false},
+		{change: func() { override.Set([]byte(`{"b": 3}`)) }, expected: true},
+		{change: func() { override.SetError(errors.New("gone")) }, expected: true},
+		{change: func() {}, expected: false},
+		{change: func() { base.SetError(errors.New("gone")) }, err: true},
+		{change: func() { base.Set([]byte(`{"a": 2}`)) }, expected: true},
+	}
+
+	for i, step := range steps {
+		step.change()
+		changed, err := r.Poll(ctx)
+		if step.err {
+			if err == nil {
+				t.Errorf("step %d: expected an error, but got none\n", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("step %d: unexpected error: %v\n", i, err)
+		}
+		if changed != step.expected {
+			t.Errorf("step %d: expected changed to be %v, but got %v\n", i, step.expected, changed)
+		}
+	}
+
+	if _, err := NewResource(Config{}); err == nil {
+		t.Errorf("expected an error for no layers\n")
+	}
+}
+
+func failing() *mem.Resource {
+	r := mem.NewResource(nil)
+	r.SetError(errors.New("unavailable"))
+	return r
+}
@@ -0,0 +1,118 @@
+// The consul package implements a resource for the Consul KV store. Changes are detected with blocking queries,
+// so a Poll returns as soon as the key (or prefix) is modified instead of on the next interval.
+//
+// Paths have the form "host:port/kv/<key>" and accept the query parameters "recurse", "dc" and "token", e.g.
+// "consul://localhost:8500/kv/features/?recurse&dc=dc1".
+package consul
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	missingKeyError = errors.New("[gosprout] consul path is missing a key")
+	// KeyNotFoundError is returned when a single key does not exist. A missing prefix is not an error when
+	// reading recursively; it is delivered as an empty object.
+	KeyNotFoundError = errors.New("[gosprout] consul key not found")
+
+	defaultAddress  = "127.0.0.1:8500"
+	defaultWaitTime = 5 * time.Minute
+)
+
+// Config controls how a Resource talks to the Consul agent. The zero value uses the CONSUL_HTTP_ADDR,
+// CONSUL_HTTP_TOKEN and CONSUL_HTTP_SSL environment variables to fill in anything left empty.
+type Config struct {
+	// Address is the "host:port" of the agent.
+	Address string
+	// Scheme is "http" or "https".
+	Scheme     string
+	Token      string
+	Datacenter string
+	// Recurse reads every key under the path as a prefix. The update func receives a JSON object mapping each
+	// key, relative to the prefix, to its value.
+	Recurse bool
+	// WaitTime is the longest a blocking query will wait for a change before Poll returns with no update.
+	WaitTime   time.Duration
+	HTTPClient *http.Client
+}
+
+// Resource is a key or a prefix in Consul KV. Every Poll is a blocking query using the X-Consul-Index of the last
+// response, and reports an update only if the content is different. The data read by Poll is kept, so a
+// following Refresh does not need another request.
+type Resource struct {
+	key    string
+	cfg    Config
+	client *http.Client
+
+	mu        *sync.Mutex
+	lastIndex uint64
+	digest    string
+	data      []byte
+}
+
+// NewResource creates a resource from a "host:port/kv/<key>" path with optional query parameters, configured
+// from the environment.
+func NewResource(path string) (*Resource, error) {
+	u, err := url.Parse("consul://" + strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	_, recurse := q["recurse"]
+	return NewResourceWithConfig(u.Path, Config{
+		Address:    u.Host,
+		Token:      q.Get("token"),
+		Datacenter: q.Get("dc"),
+		Recurse:    recurse,
+	})
+}
+
+// NewResourceWithConfig creates a resource for the key, which may be prefixed with "kv/". Empty fields in the
+// config are filled in from the environment.
+func NewResourceWithConfig(key string, cfg Config) (*Resource, error) {
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "/"), "kv/")
+	if key == "" && !cfg.Recurse {
+		return nil, missingKeyError
+	}
+
+	if cfg.Address == "" {
+		cfg.Address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if i := strings.Index(cfg.Address, "://"); i >= 0 {
+		if cfg.Scheme == "" {
+			cfg.Scheme = cfg.Address[:i]
+		}
+		cfg.Address = cfg.Address[i+3:]
+	}
+	if cfg.Address == "" {
+		cfg.Address = defaultAddress
+	}
+	if cfg.Scheme == "" {
+		cfg.Scheme = "http"
+		if strings.EqualFold(os.Getenv("CONSUL_HTTP_SSL"), "true") {
+			cfg.Scheme = "https"
+		}
+	}
+	if cfg.Token == "" {
+		cfg.Token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	if cfg.WaitTime <= 0 {
+		cfg.WaitTime = defaultWaitTime
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	return &Resource{
+		key:    key,
+		cfg:    cfg,
+		client: cfg.HTTPClient,
+		mu:     &sync.Mutex{},
+	}, nil
+}
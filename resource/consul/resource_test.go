@@ -0,0 +1,60 @@
+package consul
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewResource(t *testing.T) {
+	tests := []struct {
+		path          string
+		key           string
+		address       string
+		datacenter    string
+		token         string
+		recurse       bool
+		expectedError error
+	}{
+		{path: "localhost:8500/kv/config/app", key: "config/app", address: "localhost:8500"},
+		{path: "consul.service:8500/config/app?dc=dc2", key: "config/app", address: "consul.service:8500", datacenter: "dc2"},
+		{path: "localhost:8500/kv/features/?recurse&token=secret", key: "features/", address: "localhost:8500", token: "secret", recurse: true},
+		{path: "localhost:8500/kv/", expectedError: missingKeyError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, err := NewResource(test.path)
+			if err != test.expectedError {
+				t.Errorf("expected error %v; got %v\n", test.expectedError, err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if res.key != test.key {
+				t.Errorf("key mismatch; expected: %s, got: %s\n", test.key, res.key)
+			}
+			if res.cfg.Address != test.address {
+				t.Errorf("address mismatch; expected: %s, got: %s\n", test.address, res.cfg.Address)
+			}
+			if res.cfg.Datacenter != test.datacenter || res.cfg.Token != test.token || res.cfg.Recurse != test.recurse {
+				t.Errorf("config mismatch; got %+v\n", res.cfg)
+			}
+			if res.cfg.WaitTime != defaultWaitTime {
+				t.Errorf("expected default wait time; got %v\n", res.cfg.WaitTime)
+			}
+		})
+	}
+}
+
+func TestNewResourceWithConfig_Address(t *testing.T) {
+	res, err := NewResourceWithConfig("kv/app", Config{Address: "https://consul:8501", WaitTime: time.Second})
+	if err != nil {
+		t.Errorf("error creating resource: %v\n", err)
+		return
+	}
+	if res.cfg.Scheme != "https" || res.cfg.Address != "consul:8501" {
+		t.Errorf("expected https and consul:8501; got %s and %s\n", res.cfg.Scheme, res.cfg.Address)
+	}
+}
@@ -0,0 +1,146 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// kvPair is a single entry of a KV read.
+//
+// See: https://www.consul.io/api/kv.html#read-key
+type kvPair struct {
+	Key         string
+	Value       []byte
+	ModifyIndex uint64
+}
+
+// Poll makes a blocking query for the key, waiting until the X-Consul-Index moves past the one from the last
+// Poll or the configured wait time expires. The content is compared with the last read, so an index change caused
+// by an unrelated write does not report an update. The first Poll does not block.
+func (r *Resource) Poll(ctx context.Context) (bool, error) {
+	r.mu.Lock()
+	index := r.lastIndex
+	r.mu.Unlock()
+
+	data, newIndex, err := r.read(ctx, index)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// The index can go backwards (e.g. after a snapshot restore), in which case it must be reset so the next
+	// query does not block on an index which will never be reached.
+	//
+	// See: https://www.consul.io/api/features/blocking.html#implementation-details
+	if newIndex < r.lastIndex {
+		newIndex = 0
+	}
+	r.lastIndex = newIndex
+	if err != nil {
+		return false, err
+	}
+
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	changed := digest != r.digest
+	r.digest = digest
+	r.data = data
+	return changed, nil
+}
+
+// Refresh provides a reader for the data read by the last Poll. For a single key this is the raw value; when
+// reading recursively it is a JSON object of the keys under the prefix. If there was no Poll yet, the key is
+// read directly.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	r.mu.Lock()
+	data := r.data
+	r.mu.Unlock()
+
+	if data == nil {
+		var err error
+		if data, _, err = r.read(ctx, 0); err != nil {
+			errorHandler(err)
+			return
+		}
+	}
+	updateFunc(bytes.NewReader(data))
+}
+
+func (r *Resource) read(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	q := url.Values{}
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", r.cfg.WaitTime.String())
+	}
+	if r.cfg.Datacenter != "" {
+		q.Set("dc", r.cfg.Datacenter)
+	}
+	if r.cfg.Recurse {
+		q.Set("recurse", "")
+	}
+	u := url.URL{
+		Scheme:   r.cfg.Scheme,
+		Host:     r.cfg.Address,
+		Path:     "/v1/kv/" + r.key,
+		RawQuery: q.Encode(),
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req = req.WithContext(ctx)
+	if r.cfg.Token != "" {
+		req.Header.Set("X-Consul-Token", r.cfg.Token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	var pairs []kvPair
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(&pairs); err != nil {
+			return nil, newIndex, err
+		}
+	case http.StatusNotFound:
+		if !r.cfg.Recurse {
+			return nil, newIndex, KeyNotFoundError
+		}
+	default:
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, newIndex, fmt.Errorf("[gosprout] consul read failed with %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	if !r.cfg.Recurse {
+		if len(pairs) == 0 {
+			return nil, newIndex, KeyNotFoundError
+		}
+		if pairs[0].Value == nil {
+			return []byte{}, newIndex, nil
+		}
+		return pairs[0].Value, newIndex, nil
+	}
+
+	values := map[string]string{}
+	for _, p := range pairs {
+		// Folders are keys ending in a slash with no value, and only exist to organize the tree.
+		if strings.HasSuffix(p.Key, "/") && p.Value == nil {
+			continue
+		}
+		values[strings.TrimPrefix(p.Key, r.key)] = string(p.Value)
+	}
+	data, err := json.Marshal(values)
+	return data, newIndex, err
+}
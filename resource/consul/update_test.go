@@ -0,0 +1,175 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConsul is a minimal stand-in for the Consul KV HTTP API which supports blocking queries.
+type fakeConsul struct {
+	mu      sync.Mutex
+	index   uint64
+	kv      map[string]kvPair
+	changed chan struct{}
+	token   string
+	dc      string
+}
+
+func newFakeConsul() (*fakeConsul, *httptest.Server) {
+	f := &fakeConsul{index: 1, kv: map[string]kvPair{}, changed: make(chan struct{})}
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeConsul) put(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.index++
+	f.kv[key] = kvPair{Key: key, Value: []byte(value), ModifyIndex: f.index}
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	f.mu.Lock()
+	f.token = req.Header.Get("X-Consul-Token")
+	f.dc = q.Get("dc")
+	index, changed := f.index, f.changed
+	f.mu.Unlock()
+
+	if want, _ := strconv.ParseUint(q.Get("index"), 10, 64); want > 0 && want >= index {
+		wait, _ := time.ParseDuration(q.Get("wait"))
+		select {
+		case <-changed:
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := strings.TrimPrefix(req.URL.Path, "/v1/kv/")
+	var pairs []kvPair
+	for k, p := range f.kv {
+		if k == key || (q["recurse"] != nil && strings.HasPrefix(k, key)) {
+			pairs = append(pairs, p)
+		}
+	}
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+	if len(pairs) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(pairs)
+}
+
+func TestResource_PollBlocking(t *testing.T) {
+	fake, server := newFakeConsul()
+	defer server.Close()
+	fake.put("config/app", "v1")
+
+	res, _ := NewResourceWithConfig("kv/config/app", Config{
+		Address:    strings.TrimPrefix(server.URL, "http://"),
+		Token:      "secret",
+		Datacenter: "dc1",
+		WaitTime:   200 * time.Millisecond,
+	})
+
+	updated, err := res.Poll(context.Background())
+	if !updated || err != nil {
+		t.Errorf("expected first poll to update; got %v, %v\n", updated, err)
+	}
+	if fake.token != "secret" || fake.dc != "dc1" {
+		t.Errorf("token and datacenter not sent; got %s and %s\n", fake.token, fake.dc)
+	}
+
+	// Without a write, the blocking query waits out the wait time and nothing is updated.
+	start := time.Now()
+	updated, err = res.Poll(context.Background())
+	if updated || err != nil {
+		t.Errorf("expected no update; got %v, %v\n", updated, err)
+	}
+	if time.Since(start) < 200*time.Millisecond {
+		t.Errorf("poll returned before the wait time without a change\n")
+	}
+
+	// A write to an unrelated key moves the index but not the content.
+	fake.put("config/other", "x")
+	updated, err = res.Poll(context.Background())
+	if updated || err != nil {
+		t.Errorf("expected no update for an unrelated write; got %v, %v\n", updated, err)
+	}
+
+	res.cfg.WaitTime = 5 * time.Second
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		fake.put("config/app", "v2")
+	}()
+	start = time.Now()
+	updated, err = res.Poll(context.Background())
+	if !updated || err != nil {
+		t.Errorf("expected update; got %v, %v\n", updated, err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("blocking poll did not return promptly after a write\n")
+	}
+}
+
+func TestResource_Refresh(t *testing.T) {
+	tests := []struct {
+		key      string
+		recurse  bool
+		expected string
+	}{
+		{key: "config/app", expected: "v1"},
+		{key: "features/", recurse: true, expected: `{"a":"on","b":"off"}`},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			fake, server := newFakeConsul()
+			defer server.Close()
+			fake.put("config/app", "v1")
+			fake.put("features/a", "on")
+			fake.put("features/b", "off")
+
+			res, _ := NewResourceWithConfig(test.key, Config{
+				Address: strings.TrimPrefix(server.URL, "http://"),
+				Recurse: test.recurse,
+			})
+
+			value := ""
+			res.Refresh(context.Background(),
+				func(r io.Reader) {
+					b, _ := ioutil.ReadAll(r)
+					value = string(b)
+				}, func(e error) {
+					t.Errorf("error during refresh: %v\n", e)
+				})
+			if value != test.expected {
+				t.Errorf("expected %s; got %s\n", test.expected, value)
+			}
+		})
+	}
+}
+
+func TestResource_PollMissing(t *testing.T) {
+	_, server := newFakeConsul()
+	defer server.Close()
+
+	res, _ := NewResourceWithConfig("missing", Config{Address: strings.TrimPrefix(server.URL, "http://")})
+	updated, err := res.Poll(context.Background())
+	if updated || err != KeyNotFoundError {
+		t.Errorf("expected KeyNotFoundError and no update; got %v, %v\n", updated, err)
+	}
+}
@@ -0,0 +1,226 @@
+// The decompress package detects compressed data and decompresses it, either for a single reader with NewReader
+// or for every Refresh of a resource with NewResource. Gzip, zstd, bzip2 and xz are supported. The format is
+// taken from the first of these which identifies one: the content encoding, the content type, the extension of
+// the name (see resource.Metadata), and finally the magic bytes at the start of the data.
+//
+// The decompressed size is limited, so a small compressed payload cannot exhaust memory or disk when it is read.
+package decompress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"io"
+	"mime"
+	"path"
+	"strings"
+)
+
+var (
+	// SizeLimitError is returned by a read which goes over the maximum decompressed size.
+	SizeLimitError = errors.New("[gosprout] decompressed data exceeds the maximum size")
+
+	defaultMaxSize int64 = 100 << 20
+)
+
+// Format is a compression format.
+type Format string
+
+const (
+	None  Format = ""
+	Gzip  Format = "gzip"
+	Zstd  Format = "zstd"
+	Bzip2 Format = "bzip2"
+	Xz    Format = "xz"
+)
+
+var (
+	encodings = map[string]Format{
+		"gzip":   Gzip,
+		"x-gzip": Gzip,
+		"zstd":   Zstd,
+		"bzip2":  Bzip2,
+		"xz":     Xz,
+	}
+	contentTypes = map[string]Format{
+		"application/gzip":    Gzip,
+		"application/x-gzip":  Gzip,
+		"application/zstd":    Zstd,
+		"application/x-bzip2": Bzip2,
+		"application/x-xz":    Xz,
+	}
+	extensions = map[string]Format{
+		".gz":  Gzip,
+		".tgz": Gzip,
+		".zst": Zstd,
+		".bz2": Bzip2,
+		".xz":  Xz,
+	}
+	magic = []struct {
+		prefix []byte
+		format Format
+	}{
+		{[]byte{0x1f, 0x8b}, Gzip},
+		{[]byte{0x28, 0xb5, 0x2f, 0xfd}, Zstd},
+		{[]byte("BZh"), Bzip2},
+		{[]byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, Xz},
+	}
+)
+
+// Config controls detection and the size limit.
+type Config struct {
+	// MaxSize is the maximum decompressed size in bytes. It defaults to 100 MiB, and a negative value means there is
+	// no limit.
+	MaxSize int64
+	// DisableSniffing only uses the metadata to detect compression, never the data itself.
+	DisableSniffing bool
+}
+
+// Detect returns the compression format identified by the metadata, or None.
+func Detect(m meta.Metadata) Format {
+	if f, ok := encodings[strings.ToLower(strings.TrimSpace(m.Encoding))]; ok {
+		return f
+	}
+	if t, _, err := mime.ParseMediaType(m.ContentType); err == nil {
+		if f, ok := contentTypes[t]; ok {
+			return f
+		}
+	}
+	if f, ok := extensions[strings.ToLower(path.Ext(m.Name))]; ok {
+		return f
+	}
+	return None
+}
+
+// Sniff returns the compression format identified by the first bytes of the data, or None.
+func Sniff(data []byte) Format {
+	for _, m := range magic {
+		if bytes.HasPrefix(data, m.prefix) {
+			return m.format
+		}
+	}
+	return None
+}
+
+// NewReader returns a reader for the decompressed data of r, or for r itself if it is not compressed. The reader
+// carries the metadata of r, without the encoding and with the compression extension removed from the name. When
+// the content type was the compression format, it is derived from the remaining name instead. The reader must be
+// closed to release the decompressor.
+func NewReader(r io.Reader, cfg Config) (io.ReadCloser, error) {
+	m, _ := meta.Of(r)
+	format := Detect(m)
+	if format == None && !cfg.DisableSniffing {
+		br := bufio.NewReader(r)
+		head, _ := br.Peek(6)
+		format = Sniff(head)
+		r = br
+	}
+	if format == None {
+		return &readCloser{Reader: meta.NewReader(r, m), close: nopClose}, nil
+	}
+
+	var dr io.Reader
+	var closer func() error
+	switch format {
+	case Gzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		dr, closer = gz, gz.Close
+	case Zstd:
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		dr, closer = zr, func() error {
+			zr.Close()
+			return nil
+		}
+	case Bzip2:
+		dr, closer = bzip2.NewReader(r), nopClose
+	case Xz:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		dr, closer = xr, nopClose
+	}
+
+	maxSize := cfg.MaxSize
+	if maxSize == 0 {
+		maxSize = defaultMaxSize
+	}
+	if maxSize > 0 {
+		dr = &limitedReader{r: dr, remaining: maxSize}
+	}
+	return &readCloser{
+		Reader: meta.NewReader(dr, decompressedMetadata(m)),
+		close:  closer,
+	}, nil
+}
+
+func decompressedMetadata(m meta.Metadata) meta.Metadata {
+	compressedType := false
+	if t, _, err := mime.ParseMediaType(m.ContentType); err == nil {
+		_, compressedType = contentTypes[t]
+	}
+	ext := strings.ToLower(path.Ext(m.Name))
+	if _, ok := extensions[ext]; ok {
+		m.Name = m.Name[:len(m.Name)-len(ext)]
+		if ext == ".tgz" {
+			m.Name += ".tar"
+		}
+	}
+	if compressedType {
+		m.ContentType = ""
+		if ext := path.Ext(m.Name); ext == ".tar" {
+			m.ContentType = "application/x-tar"
+		} else if ext != "" {
+			m.ContentType = mime.TypeByExtension(ext)
+		}
+	}
+	m.Encoding = ""
+	m.Size = -1
+	// The checksums are of the compressed data.
+	m.Checksums = nil
+	return m
+}
+
+type readCloser struct {
+	meta.Reader
+	close func() error
+}
+
+func (r *readCloser) Close() error {
+	return r.close()
+}
+
+func nopClose() error {
+	return nil
+}
+
+// limitedReader fails with SizeLimitError once more than the remaining bytes are read, instead of silently
+// stopping like io.LimitedReader.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.remaining {
+		l.remaining = 0
+		return 0, SizeLimitError
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,187 @@
+package decompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"github.com/fire00f1y/go-sprout/resource/mem"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// bzip2Hello is "hello world\n" compressed with bzip2, since the standard library cannot compress it.
+var bzip2Hello = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x4e, 0xec, 0xe8, 0x36, 0x00, 0x00,
+	0x02, 0x51, 0x80, 0x00, 0x10, 0x40, 0x00, 0x06, 0x44, 0x90, 0x80, 0x20, 0x00, 0x31, 0x06, 0x4c,
+	0x41, 0x01, 0xa7, 0xa9, 0xa5, 0x80, 0xbb, 0x94, 0x31, 0xf8, 0xbb, 0x92, 0x29, 0xc2, 0x84, 0x82,
+	0x77, 0x67, 0x41, 0xb0,
+}
+
+func compress(t *testing.T, format Format, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch format {
+	case Gzip:
+		w = gzip.NewWriter(&buf)
+	case Zstd:
+		w, err = zstd.NewWriter(&buf)
+	case Xz:
+		w, err = xz.NewWriter(&buf)
+	case Bzip2:
+		return bzip2Hello
+	default:
+		return data
+	}
+	if err != nil {
+		t.Fatalf("failed to create %s writer: %v\n", format, err)
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestNewReader(t *testing.T) {
+	hello := []byte("hello world\n")
+
+	tests := []struct {
+		format   Format
+		metadata *meta.Metadata
+		cfg      Config
+		expected meta.Metadata
+		plain    bool
+	}{
+		{
+			format:   Gzip,
+			metadata: &meta.Metadata{ContentType: "application/json", Encoding: "gzip", Size: 30},
+			expected: meta.Metadata{ContentType: "application/json", Size: -1},
+		},
+		{
+			format:   Gzip,
+			metadata: &meta.Metadata{Name: "config/app.json.gz", ContentType: "application/gzip"},
+			expected: meta.Metadata{Name: "config/app.json", ContentType: "application/json", Size: -1},
+		},
+		{
+			format:   Zstd,
+			metadata: &meta.Metadata{Name: "app.yaml.zst"},
+			expected: meta.Metadata{Name: "app.yaml", Size: -1},
+		},
+		{
+			format:   Bzip2,
+			metadata: &meta.Metadata{ContentType: "application/x-bzip2"},
+			expected: meta.Metadata{Size: -1},
+		},
+		{
+			format:   Xz,
+			expected: meta.Metadata{Size: -1},
+		},
+		{
+			format:   Zstd,
+			expected: meta.Metadata{Size: -1},
+		},
+		{
+			format:   None,
+			metadata: &meta.Metadata{Name: "app.json", Size: 12},
+			expected: meta.Metadata{Name: "app.json", Size: 12},
+			plain:    true,
+		},
+		{
+			format:   Gzip,
+			cfg:      Config{DisableSniffing: true},
+			expected: meta.Metadata{Size: -1},
+			plain:    true,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			data := compress(t, test.format, hello)
+			var r io.Reader = bytes.NewReader(data)
+			if test.metadata != nil {
+				r = meta.NewReader(r, *test.metadata)
+			}
+
+			dr, err := NewReader(r, test.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+			defer dr.Close()
+			b, err := ioutil.ReadAll(dr)
+			if err != nil {
+				t.Fatalf("unexpected read error: %v\n", err)
+			}
+
+			expected := hello
+			if test.plain {
+				expected = data
+			}
+			if !bytes.Equal(b, expected) {
+				t.Errorf("expected %q, but got %q\n", expected, b)
+			}
+			if m, _ := meta.Of(dr); !reflect.DeepEqual(m, test.expected) {
+				t.Errorf("expected metadata %v, but got %v\n", test.expected, m)
+			}
+		})
+	}
+}
+
+func TestNewReader_MaxSize(t *testing.T) {
+	data := compress(t, Gzip, bytes.Repeat([]byte("a"), 10000))
+
+	tests := []struct {
+		maxSize int64
+		err     error
+	}{
+		{maxSize: 0},
+		{maxSize: -1},
+		{maxSize: 10000},
+		{maxSize: 9999, err: SizeLimitError},
+		{maxSize: 100, err: SizeLimitError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			dr, err := NewReader(bytes.NewReader(data), Config{MaxSize: test.maxSize})
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+			defer dr.Close()
+			_, err = ioutil.ReadAll(dr)
+			if err != test.err {
+				t.Errorf("expected %v, but got %v\n", test.err, err)
+			}
+		})
+	}
+}
+
+func TestResource_Refresh(t *testing.T) {
+	r := NewResource(mem.NewResource(compress(t, Gzip, []byte("hello"))), Config{})
+	var data string
+	r.Refresh(context.Background(), func(reader io.Reader) {
+		b, _ := ioutil.ReadAll(reader)
+		data = string(b)
+	}, func(e error) {
+		t.Errorf("unexpected error: %v\n", e)
+	})
+	if data != "hello" {
+		t.Errorf("expected %q, but got %q\n", "hello", data)
+	}
+
+	var refreshErr error
+	broken := mem.NewResource([]byte{0x1f, 0x8b, 0x00})
+	NewResource(broken, Config{}).Refresh(context.Background(), func(io.Reader) {
+		t.Errorf("unexpected update\n")
+	}, func(e error) {
+		refreshErr = e
+	})
+	if !errors.Is(refreshErr, io.ErrUnexpectedEOF) {
+		t.Errorf("expected a truncated gzip header error, but got %v\n", refreshErr)
+	}
+}
@@ -0,0 +1,36 @@
+package decompress
+
+import (
+	"context"
+	"github.com/fire00f1y/go-sprout/resource"
+	"io"
+)
+
+// Resource wraps another resource and decompresses the data of every Refresh.
+type Resource struct {
+	resource.Resource
+	cfg Config
+}
+
+// NewResource wraps the resource so the update func is given decompressed data.
+func NewResource(r resource.Resource, cfg Config) *Resource {
+	return &Resource{Resource: r, cfg: cfg}
+}
+
+// Refresh refreshes the wrapped resource and provides a reader for the decompressed data. A read which goes over
+// the maximum size fails with SizeLimitError, which the update func sees like any other read error.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	r.Resource.Refresh(ctx, func(reader io.Reader) {
+		dr, err := NewReader(reader, r.cfg)
+		if err != nil {
+			errorHandler(err)
+			return
+		}
+		defer func() {
+			if e := dr.Close(); e != nil {
+				errorHandler(e)
+			}
+		}()
+		updateFunc(dr)
+	}, errorHandler)
+}
@@ -0,0 +1,189 @@
+// The decrypt package decrypts data encrypted at rest, either for a single payload with Open or for every Refresh
+// of a resource with NewResource. The data is encrypted with AES-GCM, either directly with a key, like one read from
+// a local key file with ReadKeyFile, or in an Envelope whose data key is unwrapped by a KeyProvider, like a KeyRing
+// or a cloud KMS.
+//
+// The plaintext is only held in memory. It is overwritten once the update func returns, so update funcs must copy
+// what they keep, as decoders do.
+package decrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// MissingKeyError is returned when neither a key nor a key provider is configured.
+	MissingKeyError = errors.New("[gosprout] no key to decrypt with")
+	// InvalidKeyError is returned for a key which is not a 16, 24 or 32 byte AES key.
+	InvalidKeyError = errors.New("[gosprout] key must be 16, 24 or 32 bytes")
+	// MalformedPayloadError is returned when the encrypted data cannot be parsed.
+	MalformedPayloadError = errors.New("[gosprout] encrypted payload is malformed")
+	// DecryptionError is returned when the data does not decrypt with the key, because it is the wrong key or the
+	// data was modified.
+	DecryptionError = errors.New("[gosprout] payload could not be decrypted")
+	// UnsupportedAlgorithmError is returned for an envelope encrypted with something other than AES-GCM.
+	UnsupportedAlgorithmError = errors.New("[gosprout] unsupported encryption algorithm")
+)
+
+// Algorithm is the only algorithm of an Envelope.
+const Algorithm = "AES-GCM"
+
+// Envelope is envelope encrypted data, stored as JSON. The data is encrypted with AES-GCM under a random data key,
+// and the data key is wrapped by the key provider under KeyID. The byte fields are base64 in the JSON.
+//
+// The algorithm and the key id are authenticated as the additional data of the ciphertext, joined by a zero byte,
+// so an envelope whose header was changed fails to decrypt.
+type Envelope struct {
+	Algorithm  string `json:"alg"`
+	KeyID      string `json:"key_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// KeyProvider unwraps the data keys of envelopes. Implementations wrap a local KeyRing or a cloud KMS, and
+// KeyProviderFunc turns a function into one, e.g. for a stub in tests.
+type KeyProvider interface {
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// KeyWrapper wraps data keys, to create envelopes with SealEnvelope.
+type KeyWrapper interface {
+	WrapKey(ctx context.Context, keyID string, key []byte) ([]byte, error)
+}
+
+// KeyProviderFunc is a function which unwraps data keys.
+type KeyProviderFunc func(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+
+// UnwrapKey calls f.
+func (f KeyProviderFunc) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	return f(ctx, keyID, wrapped)
+}
+
+// Open decrypts data. With a key, the data is the nonce followed by the AES-GCM ciphertext, as written by Seal.
+// Otherwise it is a JSON Envelope whose data key is unwrapped by the key provider.
+func Open(ctx context.Context, data []byte, key []byte, provider KeyProvider) ([]byte, error) {
+	if len(key) > 0 {
+		return open(key, data)
+	}
+	if provider == nil {
+		return nil, MissingKeyError
+	}
+
+	var e Envelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("%w: %v", MalformedPayloadError, err)
+	}
+	if e.Algorithm != Algorithm {
+		return nil, fmt.Errorf("%w: %q", UnsupportedAlgorithmError, e.Algorithm)
+	}
+	if len(e.WrappedKey) == 0 || len(e.Ciphertext) == 0 {
+		return nil, MalformedPayloadError
+	}
+	dataKey, err := provider.UnwrapKey(ctx, e.KeyID, e.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("[gosprout] unwrapping data key with %q: %w", e.KeyID, err)
+	}
+	defer zero(dataKey)
+	return openWithNonce(dataKey, e.Nonce, e.Ciphertext, envelopeAAD(e.Algorithm, e.KeyID))
+}
+
+// Seal encrypts data with the key, as the nonce followed by the AES-GCM ciphertext.
+func Seal(key, plaintext []byte) ([]byte, error) {
+	return seal(key, plaintext, nil)
+}
+
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// SealEnvelope encrypts data under a new 256 bit data key, which is wrapped with the key wrapper under keyID, and
+// returns the JSON Envelope.
+func SealEnvelope(ctx context.Context, wrapper KeyWrapper, keyID string, plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	defer zero(dataKey)
+
+	wrapped, err := wrapper.WrapKey(ctx, keyID, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := seal(dataKey, plaintext, envelopeAAD(Algorithm, keyID))
+	if err != nil {
+		return nil, err
+	}
+	aead, _ := newGCM(dataKey)
+	return json.Marshal(Envelope{
+		Algorithm:  Algorithm,
+		KeyID:      keyID,
+		WrappedKey: wrapped,
+		Nonce:      sealed[:aead.NonceSize()],
+		Ciphertext: sealed[aead.NonceSize():],
+	})
+}
+
+func open(key, data []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize()+aead.Overhead() {
+		return nil, MalformedPayloadError
+	}
+	return openWithNonce(key, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+}
+
+func openWithNonce(key, nonce, ciphertext, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, MalformedPayloadError
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, DecryptionError
+	}
+	return plaintext, nil
+}
+
+// envelopeAAD is the additional data binding the header of an Envelope to its ciphertext.
+func envelopeAAD(algorithm, keyID string) []byte {
+	return []byte(algorithm + "\x00" + keyID)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if !validKeySize(len(key)) {
+		return nil, InvalidKeyError
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// zero overwrites key material or plaintext which is no longer needed.
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
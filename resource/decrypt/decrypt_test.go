@@ -0,0 +1,233 @@
+package decrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/fire00f1y/go-sprout/resource/mem"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+var (
+	plaintext = []byte(`{"password":"hunter2"}`)
+	key       = bytes.Repeat([]byte{7}, 32)
+)
+
+func newKeyRing(t *testing.T) *KeyRing {
+	k := NewKeyRing()
+	if err := k.Add("primary", bytes.Repeat([]byte{1}, 32)); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	return k
+}
+
+func sealEnvelope(t *testing.T, k *KeyRing, keyID string) []byte {
+	sealed, err := SealEnvelope(context.Background(), k, keyID, plaintext)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	return sealed
+}
+
+func TestOpen(t *testing.T) {
+	keyring := newKeyRing(t)
+	sealed, err := Seal(key, plaintext)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+
+	var envelope Envelope
+	json.Unmarshal(sealEnvelope(t, keyring, "primary"), &envelope)
+	envelope.Algorithm = "ROT13"
+	wrongAlgorithm, _ := json.Marshal(envelope)
+	envelope.Algorithm, envelope.KeyID = Algorithm, "other"
+	wrongKeyID, _ := json.Marshal(envelope)
+	// A provider which unwraps with the primary key whatever the key id, so only the header is checked.
+	anyKeyID := KeyProviderFunc(func(ctx context.Context, _ string, wrapped []byte) ([]byte, error) {
+		return keyring.UnwrapKey(ctx, "primary", wrapped)
+	})
+
+	stubErr := errors.New("kms unavailable")
+	tests := []struct {
+		data     []byte
+		key      []byte
+		provider KeyProvider
+		err      error
+	}{
+		{data: sealed, key: key},
+		{data: tampered, key: key, err: DecryptionError},
+		{data: sealed, key: bytes.Repeat([]byte{8}, 32), err: DecryptionError},
+		{data: sealed[:10], key: key, err: MalformedPayloadError},
+		{data: sealed, key: key[:5], err: InvalidKeyError},
+		{data: sealEnvelope(t, keyring, "primary"), provider: keyring},
+		{data: sealEnvelope(t, keyring, "primary"), provider: NewKeyRing(), err: UnknownKeyError},
+		{data: wrongAlgorithm, provider: keyring, err: UnsupportedAlgorithmError},
+		{data: sealEnvelope(t, keyring, "primary"), provider: anyKeyID},
+		{data: wrongKeyID, provider: anyKeyID, err: DecryptionError},
+		{data: []byte("not json"), provider: keyring, err: MalformedPayloadError},
+		{
+			data: sealEnvelope(t, keyring, "primary"),
+			provider: KeyProviderFunc(func(context.Context, string, []byte) ([]byte, error) {
+				return nil, stubErr
+			}),
+			err: stubErr,
+		},
+		{data: sealed, err: MissingKeyError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			got, err := Open(context.Background(), test.data, test.key, test.provider)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v; got %v\n", test.err, err)
+			}
+			if err == nil && !bytes.Equal(got, plaintext) {
+				t.Errorf("expected %s; got %s\n", plaintext, got)
+			}
+		})
+	}
+}
+
+func TestResource_Refresh(t *testing.T) {
+	keyring := newKeyRing(t)
+	sealed, _ := Seal(key, plaintext)
+
+	tests := []struct {
+		data     []byte
+		cfg      Config
+		err      error
+		rejected bool
+	}{
+		{data: sealed, cfg: Config{Key: key}},
+		{data: sealEnvelope(t, keyring, "primary"), cfg: Config{KeyProvider: keyring}},
+		{data: sealEnvelope(t, keyring, "primary"), cfg: Config{KeyProvider: NewKeyRing()}, err: UnknownKeyError},
+		{data: sealed, cfg: Config{Key: key, MaxSize: 8}, rejected: true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			r, err := NewResource(mem.NewResource(test.data), test.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+
+			var got []byte
+			var m meta.Metadata
+			var refreshErr error
+			r.Refresh(context.Background(), func(reader io.Reader) {
+				m, _ = meta.Of(reader)
+				got, _ = ioutil.ReadAll(reader)
+			}, func(e error) {
+				refreshErr = e
+			})
+
+			if test.err != nil || test.rejected {
+				if refreshErr == nil || got != nil {
+					t.Errorf("expected an error and no update; got %q and %v\n", got, refreshErr)
+				}
+				if test.err != nil && !errors.Is(refreshErr, test.err) {
+					t.Errorf("expected error %v; got %v\n", test.err, refreshErr)
+				}
+				return
+			}
+			if refreshErr != nil {
+				t.Fatalf("unexpected error: %v\n", refreshErr)
+			}
+			if !bytes.Equal(got, plaintext) || m.Size != int64(len(plaintext)) {
+				t.Errorf("expected %s of size %d; got %s of size %d\n", plaintext, len(plaintext), got, m.Size)
+			}
+		})
+	}
+}
+
+func TestNewResource(t *testing.T) {
+	tests := []struct {
+		cfg Config
+		err error
+	}{
+		{cfg: Config{Key: key}},
+		{cfg: Config{KeyProvider: NewKeyRing()}},
+		{cfg: Config{Key: key[:20]}, err: InvalidKeyError},
+		{cfg: Config{}, err: MissingKeyError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if _, err := NewResource(mem.NewResource(nil), test.cfg); err != test.err {
+				t.Errorf("expected error %v; got %v\n", test.err, err)
+			}
+		})
+	}
+}
+
+func TestReadKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosprout-decrypt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	short := key[:16]
+	tests := []struct {
+		content  []byte
+		expected []byte
+		err      error
+	}{
+		{content: key, expected: key},
+		{content: []byte(hex.EncodeToString(short) + "\n"), expected: short},
+		{content: []byte(base64.StdEncoding.EncodeToString(key)), expected: key},
+		{content: []byte("too short"), err: InvalidKeyError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			path := filepath.Join(dir, strconv.Itoa(i)+".key")
+			if err := ioutil.WriteFile(path, test.content, 0600); err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+			got, err := ReadKeyFile(path)
+			if err != test.err {
+				t.Fatalf("expected error %v; got %v\n", test.err, err)
+			}
+			if !bytes.Equal(got, test.expected) {
+				t.Errorf("expected %x; got %x\n", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestDecryptedMetadata(t *testing.T) {
+	tests := []struct {
+		input    meta.Metadata
+		expected meta.Metadata
+	}{
+		{
+			input:    meta.Metadata{Name: "secrets.json.enc", ContentType: "application/octet-stream", Size: 100},
+			expected: meta.Metadata{Name: "secrets.json", ContentType: "application/json", Size: 10},
+		},
+		{
+			input:    meta.Metadata{Name: "secrets", ContentType: "application/yaml", Checksums: map[string]string{"md5": "00"}},
+			expected: meta.Metadata{Name: "secrets", ContentType: "application/yaml", Size: 10},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if got := decryptedMetadata(test.input, 10); got.Name != test.expected.Name ||
+				got.ContentType != test.expected.ContentType || got.Size != test.expected.Size || got.Checksums != nil {
+				t.Errorf("expected %v; got %v\n", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package decrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"sync"
+)
+
+var (
+	// UnknownKeyError is returned by a KeyRing for a key id it does not have.
+	UnknownKeyError = errors.New("[gosprout] key id is not in the key ring")
+)
+
+// KeyRing is a local KeyProvider, holding key encryption keys by id. Data keys are wrapped with AES-GCM under the
+// key encryption key, as the nonce followed by the ciphertext.
+type KeyRing struct {
+	mu   *sync.RWMutex
+	keys map[string][]byte
+}
+
+// NewKeyRing creates an empty key ring.
+func NewKeyRing() *KeyRing {
+	return &KeyRing{mu: &sync.RWMutex{}, keys: map[string][]byte{}}
+}
+
+// Add stores a key encryption key under the id, replacing any key with the same id.
+func (k *KeyRing) Add(id string, key []byte) error {
+	if _, err := newGCM(key); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[id] = append([]byte(nil), key...)
+	return nil
+}
+
+// AddFile reads a key encryption key from a key file, see ReadKeyFile, and stores it under the id.
+func (k *KeyRing) AddFile(id, path string) error {
+	key, err := ReadKeyFile(path)
+	if err != nil {
+		return err
+	}
+	defer zero(key)
+	return k.Add(id, key)
+}
+
+// Remove deletes the key with the id.
+func (k *KeyRing) Remove(id string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := k.keys[id]; ok {
+		zero(key)
+		delete(k.keys, id)
+	}
+}
+
+// UnwrapKey decrypts a data key with the key encryption key with the id.
+func (k *KeyRing) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, UnknownKeyError
+	}
+	return open(key, wrapped)
+}
+
+// WrapKey encrypts a data key with the key encryption key with the id.
+func (k *KeyRing) WrapKey(_ context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, UnknownKeyError
+	}
+	return Seal(key, dataKey)
+}
+
+// ReadKeyFile reads an AES key from a file holding the raw 16, 24 or 32 bytes, or the key in hex or base64.
+func ReadKeyFile(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(b)
+
+	// The text forms are tried first, since a 16 byte key in hex is 32 bytes long.
+	text := bytes.TrimSpace(b)
+	if key, err := hex.DecodeString(string(text)); err == nil && validKeySize(len(key)) {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(string(text)); err == nil && validKeySize(len(key)) {
+		return key, nil
+	}
+	if validKeySize(len(b)) {
+		return append([]byte(nil), b...), nil
+	}
+	return nil, InvalidKeyError
+}
+
+func validKeySize(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
@@ -0,0 +1,102 @@
+package decrypt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/fire00f1y/go-sprout/resource"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"io"
+	"io/ioutil"
+	"mime"
+	"path"
+	"strings"
+)
+
+var (
+	defaultMaxSize int64 = 100 << 20
+)
+
+// Config is how the data of a resource is decrypted.
+type Config struct {
+	// Key is the AES key the data is encrypted with directly, see Seal.
+	Key []byte
+	// KeyProvider unwraps the data keys of envelope encrypted data, see SealEnvelope. It is used when Key is empty.
+	KeyProvider KeyProvider
+	// MaxSize is the maximum size of the encrypted data, which is read into memory to be decrypted. It defaults to
+	// 100 MiB, and a negative value means there is no limit.
+	MaxSize int64
+}
+
+// Resource wraps another resource and decrypts the data of every Refresh.
+type Resource struct {
+	resource.Resource
+	cfg Config
+}
+
+// NewResource wraps the resource so the update func is given the decrypted data. The key is copied.
+func NewResource(r resource.Resource, cfg Config) (*Resource, error) {
+	if len(cfg.Key) > 0 {
+		if !validKeySize(len(cfg.Key)) {
+			return nil, InvalidKeyError
+		}
+		cfg.Key = append([]byte(nil), cfg.Key...)
+	} else if cfg.KeyProvider == nil {
+		return nil, MissingKeyError
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = defaultMaxSize
+	}
+	return &Resource{Resource: r, cfg: cfg}, nil
+}
+
+// Refresh decrypts the data and provides it to the update func. The reader carries the metadata of the resource,
+// with an ".enc" extension removed from the name. Data which cannot be decrypted is given to the error handler
+// and the update func is not called. The plaintext is overwritten after the update func returns.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	r.Resource.Refresh(ctx, func(reader io.Reader) {
+		m, _ := meta.Of(reader)
+		data, err := r.read(reader)
+		if err != nil {
+			errorHandler(err)
+			return
+		}
+		plaintext, err := Open(ctx, data, r.cfg.Key, r.cfg.KeyProvider)
+		if err != nil {
+			errorHandler(err)
+			return
+		}
+		defer zero(plaintext)
+		updateFunc(meta.NewReader(bytes.NewReader(plaintext), decryptedMetadata(m, len(plaintext))))
+	}, errorHandler)
+}
+
+func (r *Resource) read(reader io.Reader) ([]byte, error) {
+	if r.cfg.MaxSize < 0 {
+		return ioutil.ReadAll(reader)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(reader, r.cfg.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > r.cfg.MaxSize {
+		return nil, fmt.Errorf("[gosprout] encrypted data exceeds the maximum size of %d bytes", r.cfg.MaxSize)
+	}
+	return data, nil
+}
+
+func decryptedMetadata(m meta.Metadata, size int) meta.Metadata {
+	if strings.EqualFold(path.Ext(m.Name), ".enc") {
+		m.Name = m.Name[:len(m.Name)-len(".enc")]
+		m.ContentType = ""
+	}
+	if t, _, _ := mime.ParseMediaType(m.ContentType); t == "" || t == "application/octet-stream" {
+		if ext := path.Ext(m.Name); ext != "" {
+			m.ContentType = mime.TypeByExtension(ext)
+		}
+	}
+	m.Size = int64(size)
+	// The checksums are of the encrypted data.
+	m.Checksums = nil
+	return m
+}
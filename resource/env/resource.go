@@ -0,0 +1,58 @@
+// The env package implements a resource for an environment variable of the process, or for an env file.
+//
+// Paths are the name of a variable, e.g. "env://DATABASE_URL", or the path of an env file starting with "/" or
+// ".", optionally followed by "#" and the name of a variable in it, e.g. "env:///etc/app.env#DATABASE_URL". An env
+// file without a variable name is delivered as a JSON object of all its variables.
+package env
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+var (
+	missingNameError = errors.New("[gosprout] env path is missing a variable name or file")
+	// VariableNotFoundError is returned when the variable is not set.
+	VariableNotFoundError = errors.New("[gosprout] environment variable not set")
+)
+
+// Config is the variable or env file a Resource watches.
+type Config struct {
+	// Name is the variable. If File is set, it is looked up in the file instead of the process environment.
+	Name string
+	// File is an env file of KEY=value lines.
+	File string
+}
+
+// Resource is an environment variable or an env file. The version is the value itself, so every Poll reads it.
+type Resource struct {
+	cfg Config
+
+	mu     *sync.Mutex
+	loaded bool
+	value  []byte
+}
+
+// NewResource creates a resource from a variable name, or from an env file path with an optional "#NAME".
+func NewResource(path string) (*Resource, error) {
+	cfg := Config{Name: path}
+	if strings.HasPrefix(path, "/") || strings.HasPrefix(path, ".") {
+		cfg = Config{File: path}
+		if i := strings.LastIndex(path, "#"); i >= 0 {
+			cfg = Config{File: path[:i], Name: path[i+1:]}
+		}
+	}
+	return NewResourceWithConfig(cfg)
+}
+
+// NewResourceWithConfig creates a resource for the configured variable or env file.
+func NewResourceWithConfig(cfg Config) (*Resource, error) {
+	if cfg.Name == "" && cfg.File == "" {
+		return nil, missingNameError
+	}
+	return &Resource{
+		cfg: cfg,
+		mu:  &sync.Mutex{},
+	}, nil
+}
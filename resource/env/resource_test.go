@@ -0,0 +1,32 @@
+package env
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestNewResource(t *testing.T) {
+	tests := []struct {
+		path          string
+		expected      Config
+		expectedError error
+	}{
+		{path: "DATABASE_URL", expected: Config{Name: "DATABASE_URL"}},
+		{path: "/etc/app.env", expected: Config{File: "/etc/app.env"}},
+		{path: "./app.env#DATABASE_URL", expected: Config{File: "./app.env", Name: "DATABASE_URL"}},
+		{path: "", expectedError: missingNameError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, err := NewResource(test.path)
+			if err != test.expectedError {
+				t.Errorf("expected error %v; got %v\n", test.expectedError, err)
+				return
+			}
+			if err == nil && res.cfg != test.expected {
+				t.Errorf("expected %+v; got %+v\n", test.expected, res.cfg)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package env
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Poll reads the variable, or the env file, and compares it with the last Poll. The value is kept so the following
+// Refresh delivers exactly what was polled.
+func (r *Resource) Poll(context.Context) (bool, error) {
+	value, err := r.read()
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := !r.loaded || !bytes.Equal(value, r.value)
+	r.loaded = true
+	r.value = value
+	return changed, nil
+}
+
+// Refresh provides a reader for the value from the last Poll, or reads it if there was no Poll yet.
+func (r *Resource) Refresh(_ context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	r.mu.Lock()
+	value, loaded := r.value, r.loaded
+	r.mu.Unlock()
+
+	if !loaded {
+		var err error
+		if value, err = r.read(); err != nil {
+			errorHandler(err)
+			return
+		}
+	}
+	updateFunc(bytes.NewReader(value))
+}
+
+func (r *Resource) read() ([]byte, error) {
+	if r.cfg.File == "" {
+		v, ok := os.LookupEnv(r.cfg.Name)
+		if !ok {
+			return nil, VariableNotFoundError
+		}
+		return []byte(v), nil
+	}
+
+	f, err := os.Open(r.cfg.File)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	vars, err := ParseEnvFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.cfg.Name == "" {
+		return json.Marshal(vars)
+	}
+	v, ok := vars[r.cfg.Name]
+	if !ok {
+		return nil, VariableNotFoundError
+	}
+	return []byte(v), nil
+}
+
+// ParseEnvFile reads KEY=value lines, as used by docker and systemd env files. Blank lines and lines starting
+// with "#" are skipped, an "export " prefix is allowed, and values can be single quoted (taken literally) or double
+// quoted (with Go escape sequences such as "\n").
+func ParseEnvFile(r io.Reader) (map[string]string, error) {
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+
+		i := strings.Index(text, "=")
+		if i <= 0 {
+			return nil, &ParseError{Line: line, Text: text}
+		}
+		key, value := strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:])
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, &ParseError{Line: line, Text: text}
+			}
+			value = unquoted
+		}
+		vars[key] = value
+	}
+	return vars, scanner.Err()
+}
+
+// ParseError is returned for a line of an env file which is not a KEY=value assignment.
+type ParseError struct {
+	Line int
+	Text string
+}
+
+func (e *ParseError) Error() string {
+	return "[gosprout] invalid env file line " + strconv.Itoa(e.Line) + ": " + e.Text
+}
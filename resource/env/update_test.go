@@ -0,0 +1,105 @@
+package env
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, res *Resource) string {
+	value := ""
+	res.Refresh(context.Background(),
+		func(r io.Reader) {
+			b, _ := ioutil.ReadAll(r)
+			value = string(b)
+		}, func(e error) {
+			t.Errorf("error during refresh: %v\n", e)
+		})
+	return value
+}
+
+func TestResource_PollVariable(t *testing.T) {
+	name := "GOSPROUT_ENV_TEST"
+	os.Unsetenv(name)
+	defer os.Unsetenv(name)
+	res, _ := NewResource(name)
+
+	if _, err := res.Poll(context.Background()); err != VariableNotFoundError {
+		t.Errorf("expected VariableNotFoundError; got %v\n", err)
+	}
+
+	tests := []struct {
+		value   string
+		updated bool
+	}{
+		{value: "one", updated: true},
+		{value: "one", updated: false},
+		{value: "", updated: true},
+		{value: "two", updated: true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			os.Setenv(name, test.value)
+			updated, err := res.Poll(context.Background())
+			if err != nil {
+				t.Errorf("error polling: %v\n", err)
+			}
+			if updated != test.updated {
+				t.Errorf("expected updated to be %v; got %v\n", test.updated, updated)
+			}
+			if v := readAll(t, res); v != test.value {
+				t.Errorf("expected %s; got %s\n", test.value, v)
+			}
+		})
+	}
+}
+
+func TestResource_EnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosprout-env-test")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "app.env")
+	ioutil.WriteFile(file, []byte("# comment\nexport A=1\nB='two words'\nC=\"line\\nbreak\"\n"), 0644)
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: file, expected: `{"A":"1","B":"two words","C":"line\nbreak"}`},
+		{path: file + "#B", expected: "two words"},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, _ := NewResource(test.path)
+			if updated, err := res.Poll(context.Background()); !updated || err != nil {
+				t.Errorf("expected first poll to update; got %v, %v\n", updated, err)
+			}
+			if v := readAll(t, res); v != test.expected {
+				t.Errorf("expected %s; got %s\n", test.expected, v)
+			}
+		})
+	}
+
+	res, _ := NewResource(file + "#A")
+	res.Poll(context.Background())
+	ioutil.WriteFile(file, []byte("A=2\n"), 0644)
+	if updated, err := res.Poll(context.Background()); !updated || err != nil {
+		t.Errorf("expected update after the file changed; got %v, %v\n", updated, err)
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	_, err := ParseEnvFile(strings.NewReader("A=1\nnot an assignment\n"))
+	if e, ok := err.(*ParseError); !ok || e.Line != 2 {
+		t.Errorf("expected a ParseError on line 2; got %v\n", err)
+	}
+}
@@ -0,0 +1,168 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// revision is an int64 from the JSON gateway, which encodes them as strings.
+type revision int64
+
+func (r *revision) UnmarshalJSON(b []byte) error {
+	n, err := strconv.ParseInt(strings.Trim(string(b), `"`), 10, 64)
+	*r = revision(n)
+	return err
+}
+
+// The JSON gateway encodes bytes fields as base64, which is how encoding/json handles []byte.
+//
+// See: https://etcd.io/docs/v3.4.0/dev-guide/api_grpc_gateway/
+type keyValue struct {
+	Key         []byte   `json:"key"`
+	Value       []byte   `json:"value"`
+	ModRevision revision `json:"mod_revision"`
+}
+
+type responseHeader struct {
+	Revision revision `json:"revision"`
+}
+
+type rangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+type rangeResponse struct {
+	Header responseHeader `json:"header"`
+	Kvs    []keyValue     `json:"kvs"`
+}
+
+type watchCreateRequest struct {
+	Key           []byte `json:"key"`
+	RangeEnd      []byte `json:"range_end,omitempty"`
+	StartRevision int64  `json:"start_revision,omitempty"`
+}
+
+type watchRequest struct {
+	CreateRequest watchCreateRequest `json:"create_request"`
+}
+
+// watchEvent has no type for a PUT, since it is the zero value of the enum and is not emitted.
+type watchEvent struct {
+	Type string   `json:"type"`
+	Kv   keyValue `json:"kv"`
+}
+
+type watchResponse struct {
+	Header          responseHeader `json:"header"`
+	Created         bool           `json:"created"`
+	Canceled        bool           `json:"canceled"`
+	CompactRevision revision       `json:"compact_revision"`
+	CancelReason    string         `json:"cancel_reason"`
+	Events          []watchEvent   `json:"events"`
+}
+
+type gatewayError struct {
+	Message string `json:"message"`
+}
+
+// watchStreamMessage is one message of the streamed watch response.
+type watchStreamMessage struct {
+	Result *watchResponse `json:"result"`
+	Error  *gatewayError  `json:"error"`
+}
+
+type authRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type authResponse struct {
+	Token string `json:"token"`
+}
+
+// post sends a JSON request to the current endpoint. If the endpoint cannot be reached, the next one will be
+// used by the following request. The caller is responsible for closing the body of the response.
+func (r *Resource) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	if r.cfg.Username != "" && path != "/v3/auth/authenticate" {
+		if err := r.authenticate(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	endpoint, token := r.cfg.Endpoints[r.endpoint], r.token
+	r.mu.Unlock()
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(endpoint, "/")+path, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		r.mu.Lock()
+		r.endpoint = (r.endpoint + 1) % len(r.cfg.Endpoints)
+		r.mu.Unlock()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			r.mu.Lock()
+			r.token = ""
+			r.mu.Unlock()
+		}
+		var e gatewayError
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(msg, &e) == nil && e.Message != "" {
+			return nil, fmt.Errorf("[gosprout] etcd %s failed with %s: %s", path, resp.Status, e.Message)
+		}
+		return nil, fmt.Errorf("[gosprout] etcd %s failed with %s", path, resp.Status)
+	}
+	return resp, nil
+}
+
+func (r *Resource) authenticate(ctx context.Context) error {
+	r.mu.Lock()
+	token := r.token
+	r.mu.Unlock()
+	if token != "" {
+		return nil
+	}
+
+	resp, err := r.post(ctx, "/v3/auth/authenticate", authRequest{Name: r.cfg.Username, Password: r.cfg.Password})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var auth authResponse
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.token = auth.Token
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Resource) rangeRequest() rangeRequest {
+	return rangeRequest{Key: []byte(r.key), RangeEnd: []byte(r.rangeEnd())}
+}
@@ -0,0 +1,117 @@
+// The etcd package implements a resource for a key or prefix in etcd v3. It talks to the JSON gateway of the
+// etcd API, and implements resource.Notifier using the watch API, so changes are pushed rather than polled.
+//
+// Paths have the form "host:port[,host:port...]/<key>" and accept the query parameter "prefix" to watch
+// every key under the path, e.g. "etcd://10.0.0.1:2379,10.0.0.2:2379/config/app/?prefix".
+package etcd
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	missingKeyError      = errors.New("[gosprout] etcd path is missing a key")
+	missingEndpointError = errors.New("[gosprout] etcd path is missing an endpoint")
+	// KeyNotFoundError is returned when a single key does not exist. A missing prefix is not an error; it is
+	// delivered as an empty object.
+	KeyNotFoundError = errors.New("[gosprout] etcd key not found")
+
+	defaultRetryInterval = time.Second
+)
+
+// Config controls how a Resource talks to the etcd cluster.
+type Config struct {
+	// Endpoints are the "scheme://host:port" urls of the cluster members. On a failure, the next one is used.
+	Endpoints []string
+	// Prefix reads and watches every key under the key as a prefix. The update func receives a JSON object
+	// mapping each key, relative to the prefix, to its value.
+	Prefix   bool
+	Username string
+	Password string
+	// RetryInterval is how long to wait before reconnecting a watch which failed.
+	RetryInterval time.Duration
+	HTTPClient    *http.Client
+}
+
+// Resource is a key or a prefix in etcd. The mod_revision of the key (or of every key under the prefix) is its
+// version. As a Notifier it keeps a watch open, resuming from the last seen revision after a reconnect, and does
+// a full read if that revision has been compacted away.
+type Resource struct {
+	key    string
+	cfg    Config
+	client *http.Client
+
+	mu       *sync.Mutex
+	endpoint int
+	token    string
+	loaded   bool
+	version  string
+	kvs      map[string]keyValue
+}
+
+// NewResource creates a resource from a "host:port[,host:port...]/<key>" path with an optional "prefix" query
+// parameter. Endpoints are reached over http.
+func NewResource(path string) (*Resource, error) {
+	u, err := url.Parse("etcd://" + strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, missingEndpointError
+	}
+	var endpoints []string
+	for _, host := range strings.Split(u.Host, ",") {
+		endpoints = append(endpoints, "http://"+host)
+	}
+	_, prefix := u.Query()["prefix"]
+	return NewResourceWithConfig(strings.TrimPrefix(u.Path, "/"), Config{
+		Endpoints: endpoints,
+		Prefix:    prefix,
+	})
+}
+
+// NewResourceWithConfig creates a resource for the key.
+func NewResourceWithConfig(key string, cfg Config) (*Resource, error) {
+	if key == "" && !cfg.Prefix {
+		return nil, missingKeyError
+	}
+	if len(cfg.Endpoints) == 0 {
+		return nil, missingEndpointError
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = defaultRetryInterval
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	return &Resource{
+		key:    key,
+		cfg:    cfg,
+		client: cfg.HTTPClient,
+		mu:     &sync.Mutex{},
+		kvs:    map[string]keyValue{},
+	}, nil
+}
+
+// rangeEnd returns the end of the key range for the resource. For a prefix, this is the prefix with its last
+// byte incremented, the same as clientv3.GetPrefixRangeEnd.
+func (r *Resource) rangeEnd() string {
+	if !r.cfg.Prefix {
+		return ""
+	}
+	end := []byte(r.key)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	// The prefix is all 0xff bytes (or empty), so the range is every key after it.
+	return "\x00"
+}
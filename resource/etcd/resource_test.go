@@ -0,0 +1,73 @@
+package etcd
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestNewResource(t *testing.T) {
+	tests := []struct {
+		path          string
+		key           string
+		endpoints     []string
+		prefix        bool
+		expectedError error
+	}{
+		{path: "localhost:2379/config/app", key: "config/app", endpoints: []string{"http://localhost:2379"}},
+		{
+			path:      "10.0.0.1:2379,10.0.0.2:2379/config/?prefix",
+			key:       "config/",
+			endpoints: []string{"http://10.0.0.1:2379", "http://10.0.0.2:2379"},
+			prefix:    true,
+		},
+		{path: "localhost:2379/", expectedError: missingKeyError},
+		{path: "", expectedError: missingEndpointError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, err := NewResource(test.path)
+			if err != test.expectedError {
+				t.Errorf("expected error %v; got %v\n", test.expectedError, err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if res.key != test.key || res.cfg.Prefix != test.prefix {
+				t.Errorf("expected key %s (prefix %v); got %s (prefix %v)\n", test.key, test.prefix, res.key, res.cfg.Prefix)
+			}
+			if len(res.cfg.Endpoints) != len(test.endpoints) {
+				t.Errorf("expected endpoints %v; got %v\n", test.endpoints, res.cfg.Endpoints)
+				return
+			}
+			for j := range test.endpoints {
+				if res.cfg.Endpoints[j] != test.endpoints[j] {
+					t.Errorf("expected endpoints %v; got %v\n", test.endpoints, res.cfg.Endpoints)
+				}
+			}
+		})
+	}
+}
+
+func TestResource_RangeEnd(t *testing.T) {
+	tests := []struct {
+		key      string
+		prefix   bool
+		expected string
+	}{
+		{key: "config/app", prefix: false, expected: ""},
+		{key: "config/", prefix: true, expected: "config0"},
+		{key: "a\xff", prefix: true, expected: "b"},
+		{key: "", prefix: true, expected: "\x00"},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, _ := NewResourceWithConfig(test.key, Config{Endpoints: []string{"http://localhost:2379"}, Prefix: test.prefix})
+			if end := res.rangeEnd(); end != test.expected {
+				t.Errorf("expected %q; got %q\n", test.expected, end)
+			}
+		})
+	}
+}
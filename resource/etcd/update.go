@@ -0,0 +1,233 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	watchClosedError = errors.New("[gosprout] etcd watch stream closed")
+)
+
+// Poll reads the key (or every key under the prefix) and compares its mod_revision with the last read.
+func (r *Resource) Poll(ctx context.Context) (bool, error) {
+	changed, _, err := r.load(ctx)
+	return changed, err
+}
+
+// Refresh provides a reader for the data from the last Poll or notification. For a single key this is the raw
+// value; for a prefix it is a JSON object of the keys under the prefix. If nothing was read yet, the key is read
+// directly.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	r.mu.Lock()
+	loaded := r.loaded
+	r.mu.Unlock()
+	if !loaded {
+		if _, _, err := r.load(ctx); err != nil {
+			errorHandler(err)
+			return
+		}
+	}
+
+	data, err := r.render()
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+	updateFunc(bytes.NewReader(data))
+}
+
+// Notify reads the key and then keeps a watch open on it, sending on the returned channel whenever the mod_revision
+// changes. A dropped watch is reopened from the revision after the last event seen, so no change is missed. If
+// that revision was compacted, the key is read again in full and the watch starts over from there.
+func (r *Resource) Notify(ctx context.Context, errorHandler func(error)) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+
+	go func() {
+		defer close(ch)
+		// next is the revision to resume the watch from. Zero means a full read is needed first.
+		var next int64
+		first := true
+		for ctx.Err() == nil {
+			if next == 0 {
+				changed, rev, err := r.load(ctx)
+				if err != nil && err != KeyNotFoundError {
+					errorHandler(err)
+					r.wait(ctx)
+					continue
+				}
+				if err == KeyNotFoundError {
+					errorHandler(err)
+				} else if changed || first {
+					notify()
+					first = false
+				}
+				next = rev + 1
+			}
+
+			var err error
+			next, err = r.watch(ctx, next, notify)
+			if err != nil && ctx.Err() == nil {
+				errorHandler(err)
+				r.wait(ctx)
+			}
+		}
+	}()
+	return ch
+}
+
+// watch streams events from the start revision until the stream ends, calling onChange whenever the version
+// changes. It returns the revision to resume from, which is zero if the start revision was compacted.
+func (r *Resource) watch(ctx context.Context, start int64, onChange func()) (int64, error) {
+	rr := r.rangeRequest()
+	resp, err := r.post(ctx, "/v3/watch", watchRequest{CreateRequest: watchCreateRequest{
+		Key:           rr.Key,
+		RangeEnd:      rr.RangeEnd,
+		StartRevision: start,
+	}})
+	if err != nil {
+		return start, err
+	}
+	defer resp.Body.Close()
+
+	next := start
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg watchStreamMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				err = watchClosedError
+			}
+			return next, err
+		}
+		if msg.Error != nil {
+			return next, errors.New("[gosprout] etcd watch failed: " + msg.Error.Message)
+		}
+		if msg.Result == nil {
+			continue
+		}
+
+		w := msg.Result
+		if w.CompactRevision > 0 {
+			return 0, nil
+		}
+		if w.Canceled {
+			return next, errors.New("[gosprout] etcd watch canceled: " + w.CancelReason)
+		}
+		if len(w.Events) == 0 {
+			continue
+		}
+
+		r.mu.Lock()
+		for _, e := range w.Events {
+			if e.Type == "DELETE" {
+				delete(r.kvs, string(e.Kv.Key))
+			} else {
+				r.kvs[string(e.Kv.Key)] = e.Kv
+			}
+			if rev := int64(e.Kv.ModRevision) + 1; rev > next {
+				next = rev
+			}
+		}
+		version := r.currentVersion()
+		changed := version != r.version
+		r.version = version
+		r.mu.Unlock()
+
+		if changed {
+			onChange()
+		}
+	}
+}
+
+// load reads the key range in full, replacing the known state. It returns whether the version changed and the
+// revision of the read.
+func (r *Resource) load(ctx context.Context) (bool, int64, error) {
+	resp, err := r.post(ctx, "/v3/kv/range", r.rangeRequest())
+	if err != nil {
+		return false, 0, err
+	}
+	defer resp.Body.Close()
+	var rr rangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rr); err != nil {
+		return false, 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.kvs = map[string]keyValue{}
+	for _, kv := range rr.Kvs {
+		r.kvs[string(kv.Key)] = kv
+	}
+	version := r.currentVersion()
+	changed := !r.loaded || version != r.version
+	r.loaded = true
+	r.version = version
+
+	if !r.cfg.Prefix && len(rr.Kvs) == 0 {
+		return changed, int64(rr.Header.Revision), KeyNotFoundError
+	}
+	return changed, int64(rr.Header.Revision), nil
+}
+
+// currentVersion is the mod_revision of the key, or a digest of the mod_revisions of every key under the prefix,
+// so a deleted key is noticed too. The lock must be held.
+func (r *Resource) currentVersion() string {
+	if !r.cfg.Prefix {
+		return strconv.FormatInt(int64(r.kvs[r.key].ModRevision), 10)
+	}
+	h := sha256.New()
+	for _, k := range r.sortedKeys() {
+		h.Write([]byte(k + "@" + strconv.FormatInt(int64(r.kvs[k].ModRevision), 10) + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (r *Resource) sortedKeys() []string {
+	keys := make([]string, 0, len(r.kvs))
+	for k := range r.kvs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (r *Resource) render() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.cfg.Prefix {
+		kv, ok := r.kvs[r.key]
+		if !ok {
+			return nil, KeyNotFoundError
+		}
+		return kv.Value, nil
+	}
+
+	values := map[string]string{}
+	for k, kv := range r.kvs {
+		values[strings.TrimPrefix(k, r.key)] = string(kv.Value)
+	}
+	return json.Marshal(values)
+}
+
+func (r *Resource) wait(ctx context.Context) {
+	select {
+	case <-time.After(r.cfg.RetryInterval):
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,270 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEtcd is a minimal stand-in for the etcd v3 JSON gateway. It keeps the history of every write so watches
+// can start from a past revision, and can compact that history or drop open watch streams.
+type fakeEtcd struct {
+	mu        sync.Mutex
+	revision  int64
+	kvs       map[string]keyValue
+	history   []watchEvent
+	compacted int64
+	changed   chan struct{}
+	drop      chan struct{}
+	starts    []int64
+}
+
+func newFakeEtcd() (*fakeEtcd, *httptest.Server) {
+	f := &fakeEtcd{
+		revision: 1,
+		kvs:      map[string]keyValue{},
+		changed:  make(chan struct{}),
+		drop:     make(chan struct{}),
+	}
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeEtcd) put(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.revision++
+	kv := keyValue{Key: []byte(key), Value: []byte(value), ModRevision: revision(f.revision)}
+	f.kvs[key] = kv
+	f.history = append(f.history, watchEvent{Kv: kv})
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeEtcd) dropWatches(compact bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if compact {
+		f.compacted = f.revision
+	}
+	close(f.drop)
+	f.drop = make(chan struct{})
+}
+
+func inRange(key string, start, end []byte) bool {
+	if len(end) == 0 {
+		return key == string(start)
+	}
+	return key >= string(start) && (string(end) == "\x00" || key < string(end))
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/v3/kv/range":
+		var rr rangeRequest
+		json.NewDecoder(req.Body).Decode(&rr)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		resp := rangeResponse{Header: responseHeader{Revision: revision(f.revision)}}
+		for k, kv := range f.kvs {
+			if inRange(k, rr.Key, rr.RangeEnd) {
+				resp.Kvs = append(resp.Kvs, kv)
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	case "/v3/watch":
+		f.watch(w, req)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeEtcd) watch(w http.ResponseWriter, req *http.Request) {
+	var wr watchRequest
+	json.NewDecoder(req.Body).Decode(&wr)
+	c := wr.CreateRequest
+	encoder := json.NewEncoder(w)
+	send := func(resp watchResponse) {
+		encoder.Encode(watchStreamMessage{Result: &resp})
+		w.(http.Flusher).Flush()
+	}
+
+	f.mu.Lock()
+	f.starts = append(f.starts, c.StartRevision)
+	if c.StartRevision > 0 && c.StartRevision <= f.compacted {
+		f.mu.Unlock()
+		send(watchResponse{Canceled: true, CompactRevision: revision(f.compacted)})
+		return
+	}
+	f.mu.Unlock()
+	send(watchResponse{Created: true})
+
+	sent := c.StartRevision
+	for {
+		f.mu.Lock()
+		var events []watchEvent
+		for _, e := range f.history {
+			if int64(e.Kv.ModRevision) >= sent && inRange(string(e.Kv.Key), c.Key, c.RangeEnd) {
+				events = append(events, e)
+			}
+		}
+		sent = f.revision + 1
+		changed, drop := f.changed, f.drop
+		f.mu.Unlock()
+
+		if len(events) > 0 {
+			send(watchResponse{Events: events})
+		}
+		select {
+		case <-changed:
+		case <-drop:
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+func readAll(t *testing.T, res *Resource) string {
+	value := ""
+	res.Refresh(context.Background(),
+		func(r io.Reader) {
+			b, _ := ioutil.ReadAll(r)
+			value = string(b)
+		}, func(e error) {
+			t.Errorf("error during refresh: %v\n", e)
+		})
+	return value
+}
+
+func expectNotification(t *testing.T, ch <-chan struct{}) {
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Errorf("timed out waiting for a notification\n")
+	}
+}
+
+func TestResource_Notify(t *testing.T) {
+	fake, server := newFakeEtcd()
+	defer server.Close()
+	fake.put("config/app", "v1")
+
+	res, _ := NewResourceWithConfig("config/app", Config{
+		Endpoints:     []string{server.URL},
+		RetryInterval: 10 * time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := res.Notify(ctx, func(e error) {})
+
+	expectNotification(t, ch)
+	if v := readAll(t, res); v != "v1" {
+		t.Errorf("expected v1; got %s\n", v)
+	}
+
+	fake.put("config/other", "ignored")
+	fake.put("config/app", "v2")
+	expectNotification(t, ch)
+	if v := readAll(t, res); v != "v2" {
+		t.Errorf("expected v2; got %s\n", v)
+	}
+
+	// A write while the watch is down is delivered once it resumes from the last seen revision.
+	fake.mu.Lock()
+	lastSeen := fake.revision
+	fake.mu.Unlock()
+	fake.dropWatches(false)
+	fake.put("config/app", "v3")
+	expectNotification(t, ch)
+	if v := readAll(t, res); v != "v3" {
+		t.Errorf("expected v3; got %s\n", v)
+	}
+	fake.mu.Lock()
+	resumedFrom := fake.starts[len(fake.starts)-1]
+	fake.mu.Unlock()
+	if resumedFrom != lastSeen+1 {
+		t.Errorf("expected watch to resume from %d; got %d\n", lastSeen+1, resumedFrom)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			for range ch {
+			}
+		}
+	case <-time.After(time.Second):
+		t.Errorf("notification channel not closed after cancel\n")
+	}
+}
+
+func TestResource_NotifyCompacted(t *testing.T) {
+	fake, server := newFakeEtcd()
+	defer server.Close()
+	fake.put("config/a", "1")
+
+	res, _ := NewResourceWithConfig("config/", Config{
+		Endpoints:     []string{server.URL},
+		Prefix:        true,
+		RetryInterval: 10 * time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := res.Notify(ctx, func(e error) {})
+	expectNotification(t, ch)
+
+	// The write happens while the watch is down and its revision is then compacted, so it can only be seen
+	// with a full read.
+	fake.mu.Lock()
+	fake.drop, fake.changed = make(chan struct{}), make(chan struct{})
+	fake.mu.Unlock()
+	fake.put("config/b", "2")
+	fake.dropWatches(true)
+
+	expectNotification(t, ch)
+	expected := `{"a":"1","b":"2"}`
+	if v := readAll(t, res); v != expected {
+		t.Errorf("expected %s; got %s\n", expected, v)
+	}
+}
+
+func TestResource_Poll(t *testing.T) {
+	fake, server := newFakeEtcd()
+	defer server.Close()
+	fake.put("config/app", "v1")
+	res, _ := NewResourceWithConfig("config/app", Config{Endpoints: []string{"http://127.0.0.1:1", server.URL}})
+
+	tests := []struct {
+		put     string
+		updated bool
+		err     bool
+	}{
+		// The first endpoint cannot be reached, so the first poll fails and moves on to the next one.
+		{put: "", updated: false, err: true},
+		{put: "", updated: true},
+		{put: "", updated: false},
+		{put: "v2", updated: true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if test.put != "" {
+				fake.put("config/app", test.put)
+			}
+			updated, err := res.Poll(context.Background())
+			if (err != nil) != test.err {
+				t.Errorf("unexpected error result: %v\n", err)
+			}
+			if updated != test.updated {
+				t.Errorf("expected updated to be %v; got %v\n", test.updated, updated)
+			}
+		})
+	}
+}
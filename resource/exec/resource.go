@@ -0,0 +1,149 @@
+// The exec package implements a resource whose data is the standard output of a command, for data which only
+// comes from a command line tool. The version is a digest of the output, so the command runs on every Poll.
+//
+// Paths are the command line, split on spaces with single and double quotes grouping words, e.g.
+// "exec:///usr/local/bin/vault kv get -field=config secret/app".
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	missingCommandError    = errors.New("[gosprout] exec path is missing a command")
+	unterminatedQuoteError = errors.New("[gosprout] exec command has an unterminated quote")
+
+	defaultTimeout = 30 * time.Second
+)
+
+// Config is the command a Resource runs.
+type Config struct {
+	Command string
+	Args    []string
+	// Env is added to the environment of the current process, in "KEY=value" form.
+	Env []string
+	Dir string
+	// Timeout is how long the command can run before it is killed. It defaults to 30 seconds.
+	Timeout time.Duration
+}
+
+// CommandError is returned when the command fails to run or exits with a non-zero code. It includes what the
+// command wrote to stderr.
+type CommandError struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("[gosprout] command %q failed", e.Command)
+	if e.ExitCode > 0 {
+		msg += fmt.Sprintf(" with exit code %d", e.ExitCode)
+	} else if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Resource is the standard output of a command.
+type Resource struct {
+	cfg Config
+
+	mu      *sync.Mutex
+	loaded  bool
+	version string
+	output  []byte
+	// err is the failure of the last run, which the following Refresh reports.
+	err error
+}
+
+// NewResource creates a resource from a command line.
+func NewResource(path string) (*Resource, error) {
+	words, err := splitCommand(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, missingCommandError
+	}
+	return NewResourceWithConfig(Config{Command: words[0], Args: words[1:]})
+}
+
+// NewResourceWithConfig creates a resource for the configured command.
+func NewResourceWithConfig(cfg Config) (*Resource, error) {
+	if cfg.Command == "" {
+		return nil, missingCommandError
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	return &Resource{
+		cfg: cfg,
+		mu:  &sync.Mutex{},
+	}, nil
+}
+
+// splitCommand splits a command line into words on spaces, the same way a shell would. Single and double quotes
+// group words. Outside of quotes a backslash escapes the next character, and inside double quotes it only
+// escapes a double quote, backslash, dollar sign or backtick.
+func splitCommand(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, c := range s {
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune("\"\\$`", c) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote == 0:
+			escaped = true
+			inWord = true
+		case c == '\\' && quote == '"':
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, unterminatedQuoteError
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
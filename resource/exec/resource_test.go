@@ -0,0 +1,49 @@
+package exec
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		line          string
+		words         []string
+		expectedError error
+	}{
+		{line: "/usr/bin/vault kv get secret/app", words: []string{"/usr/bin/vault", "kv", "get", "secret/app"}},
+		{line: `sh -c 'echo "a b"'`, words: []string{"sh", "-c", `echo "a b"`}},
+		{line: `printf "%s\n" two\ words`, words: []string{"printf", `%s\n`, "two words"}},
+		{line: `echo "\"quoted\" \$HOME" \'`, words: []string{"echo", `"quoted" $HOME`, "'"}},
+		{line: `cat ""`, words: []string{"cat", ""}},
+		{line: "  ", words: nil},
+		{line: `echo 'oops`, expectedError: unterminatedQuoteError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			words, err := splitCommand(test.line)
+			if err != test.expectedError {
+				t.Errorf("expected error %v; got %v\n", test.expectedError, err)
+			}
+			if strings.Join(words, "|") != strings.Join(test.words, "|") || len(words) != len(test.words) {
+				t.Errorf("expected %q; got %q\n", test.words, words)
+			}
+		})
+	}
+}
+
+func TestNewResource(t *testing.T) {
+	if _, err := NewResource(" "); err != missingCommandError {
+		t.Errorf("expected missingCommandError; got %v\n", err)
+	}
+	res, err := NewResource("echo hello")
+	if err != nil {
+		t.Errorf("error creating resource: %v\n", err)
+		return
+	}
+	if res.cfg.Command != "echo" || res.cfg.Timeout != defaultTimeout {
+		t.Errorf("unexpected config: %+v\n", res.cfg)
+	}
+}
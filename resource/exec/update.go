@@ -0,0 +1,84 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Poll runs the command and compares a digest of its output with the last Poll. The output is kept so the
+// following Refresh delivers exactly what was polled. A failed run is returned as a *CommandError, and a Refresh
+// before the next successful Poll gives it to the error handler too.
+func (r *Resource) Poll(ctx context.Context) (bool, error) {
+	output, err := r.run(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256(output)
+	version := hex.EncodeToString(sum[:])
+	changed := !r.loaded || version != r.version
+	r.loaded = true
+	r.version = version
+	r.output = output
+	return changed, nil
+}
+
+// Refresh provides a reader for the output of the command from the last Poll. If there was no Poll yet, the
+// command is run. A failed run, including one from the last Poll, is given to the error handler as a *CommandError.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	r.mu.Lock()
+	output, loaded, err := r.output, r.loaded, r.err
+	r.mu.Unlock()
+
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+	if !loaded {
+		var err error
+		if output, err = r.run(ctx); err != nil {
+			errorHandler(err)
+			return
+		}
+	}
+	updateFunc(bytes.NewReader(output))
+}
+
+func (r *Resource) run(ctx context.Context) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, r.cfg.Command, r.cfg.Args...)
+	cmd.Dir = r.cfg.Dir
+	if len(r.cfg.Env) > 0 {
+		cmd.Env = append(os.Environ(), r.cfg.Env...)
+	}
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	if err := cmd.Run(); err != nil {
+		e := &CommandError{
+			Command: strings.Join(cmd.Args, " "),
+			Stderr:  strings.TrimSpace(stderr.String()),
+			Err:     err,
+		}
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			e.ExitCode = exitErr.ExitCode()
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			e.Err = ctx.Err()
+			e.ExitCode = 0
+		}
+		return nil, e
+	}
+	return stdout.Bytes(), nil
+}
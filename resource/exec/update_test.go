@@ -0,0 +1,172 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestResource_Poll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosprout-exec-test")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	data := filepath.Join(dir, "data")
+	ioutil.WriteFile(data, []byte("v1"), 0644)
+
+	res, _ := NewResourceWithConfig(Config{Command: "cat", Args: []string{"data"}, Dir: dir})
+
+	tests := []struct {
+		write   string
+		updated bool
+	}{
+		{write: "", updated: true},
+		{write: "", updated: false},
+		{write: "v2", updated: true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if test.write != "" {
+				ioutil.WriteFile(data, []byte(test.write), 0644)
+			}
+			updated, err := res.Poll(context.Background())
+			if err != nil {
+				t.Errorf("error polling: %v\n", err)
+			}
+			if updated != test.updated {
+				t.Errorf("expected updated to be %v; got %v\n", test.updated, updated)
+			}
+		})
+	}
+
+	value := ""
+	ioutil.WriteFile(data, []byte("v3"), 0644)
+	res.Refresh(context.Background(),
+		func(r io.Reader) {
+			b, _ := ioutil.ReadAll(r)
+			value = string(b)
+		}, func(e error) {
+			t.Errorf("error during refresh: %v\n", e)
+		})
+	if value != "v2" {
+		t.Errorf("expected the polled output v2; got %s\n", value)
+	}
+}
+
+func TestResource_RefreshError(t *testing.T) {
+	tests := []struct {
+		cfg      Config
+		exitCode int
+		stderr   string
+		err      error
+	}{
+		{
+			cfg:      Config{Command: "sh", Args: []string{"-c", "echo partial; echo denied >&2; exit 3"}},
+			exitCode: 3,
+			stderr:   "denied",
+		},
+		{
+			cfg: Config{Command: "sh", Args: []string{"-c", "exec sleep 5"}, Timeout: 50 * time.Millisecond},
+			err: context.DeadlineExceeded,
+		},
+		{
+			cfg:      Config{Command: "sh", Args: []string{"-c", `echo "$SECRET" >&2; exit 1`}, Env: []string{"SECRET=from-env"}},
+			exitCode: 1,
+			stderr:   "from-env",
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, _ := NewResourceWithConfig(test.cfg)
+			var err error
+			res.Refresh(context.Background(),
+				func(r io.Reader) {
+					t.Errorf("expected no update call, but got one\n")
+				}, func(e error) {
+					err = e
+				})
+
+			var cmdErr *CommandError
+			if !errors.As(err, &cmdErr) {
+				t.Errorf("expected a *CommandError; got %v\n", err)
+				return
+			}
+			if cmdErr.ExitCode != test.exitCode || cmdErr.Stderr != test.stderr {
+				t.Errorf("expected exit code %d and stderr %q; got %d and %q\n", test.exitCode, test.stderr, cmdErr.ExitCode, cmdErr.Stderr)
+			}
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Errorf("expected %v; got %v\n", test.err, err)
+			}
+		})
+	}
+}
+
+func TestResource_PollError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosprout-exec-test")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	data := filepath.Join(dir, "data")
+	ioutil.WriteFile(data, []byte("v1"), 0644)
+
+	res, _ := NewResourceWithConfig(Config{Command: "cat", Args: []string{"data"}, Dir: dir})
+
+	tests := []struct {
+		remove  bool
+		write   string
+		value   string
+		failed  bool
+		updated bool
+	}{
+		{value: "v1", updated: true},
+		{remove: true, failed: true},
+		{write: "v1", updated: false},
+		{write: "v2", value: "v2", updated: true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if test.remove {
+				os.Remove(data)
+			}
+			if test.write != "" {
+				ioutil.WriteFile(data, []byte(test.write), 0644)
+			}
+			updated, err := res.Poll(context.Background())
+			var cmdErr *CommandError
+			if updated != test.updated || test.failed != errors.As(err, &cmdErr) {
+				t.Fatalf("expected updated to be %v and a *CommandError to be %v; got %v and %v\n",
+					test.updated, test.failed, updated, err)
+			}
+			if !updated && !test.failed {
+				return
+			}
+
+			value := ""
+			var refreshErr error
+			res.Refresh(context.Background(),
+				func(r io.Reader) {
+					b, _ := ioutil.ReadAll(r)
+					value = string(b)
+				}, func(e error) {
+					refreshErr = e
+				})
+			if test.failed != errors.As(refreshErr, &cmdErr) {
+				t.Errorf("expected a *CommandError to be %v; got %v\n", test.failed, refreshErr)
+			}
+			if value != test.value {
+				t.Errorf("expected %q; got %q\n", test.value, value)
+			}
+		})
+	}
+}
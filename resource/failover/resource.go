@@ -0,0 +1,124 @@
+// The failover package implements a resource backed by an ordered list of sources, e.g. a GCS object, then a
+// mirror, then a local file. The first source which is available is authoritative, so the data keeps flowing while
+// the primary is unreachable, and the resource switches back once the primary recovers.
+package failover
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fire00f1y/go-sprout/resource"
+	"strings"
+	"sync"
+)
+
+var (
+	noSourcesError = errors.New("[gosprout] failover resource has no sources")
+)
+
+// Source is one of the sources of a failover resource. The name is used in switch events and errors.
+type Source struct {
+	resource.Resource
+	Name string
+}
+
+// SwitchEvent is reported when the authoritative source changes. Err is why the previous source was left, and is
+// nil when switching back to a source which has recovered.
+type SwitchEvent struct {
+	From      string
+	FromIndex int
+	To        string
+	ToIndex   int
+	Err       error
+}
+
+// SourcesError is returned when no source is available. It has the error of each source, in order.
+type SourcesError struct {
+	Names  []string
+	Errors []error
+}
+
+func (e *SourcesError) Error() string {
+	parts := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		parts[i] = e.Names[i] + ": " + err.Error()
+	}
+	return "[gosprout] all failover sources failed: " + strings.Join(parts, "; ")
+}
+
+// Config holds the optional settings of a failover resource.
+type Config struct {
+	// SwitchHandler is called every time the authoritative source changes.
+	SwitchHandler func(SwitchEvent)
+}
+
+// Resource is an ordered list of sources. The primary (the first source) is authoritative until it fails to Poll
+// or Refresh, then the next available one is. Every Poll tries the sources before the authoritative one again, so
+// the resource switches back as soon as a higher priority source recovers. A switch is reported as an update, so
+// the data is always read from the new source.
+type Resource struct {
+	sources []Source
+	cfg     Config
+
+	mu     *sync.Mutex
+	active int
+}
+
+// NewResource creates a failover resource with the sources in order of priority.
+func NewResource(cfg Config, sources ...Source) (*Resource, error) {
+	if len(sources) == 0 {
+		return nil, noSourcesError
+	}
+	for i := range sources {
+		if sources[i].Name == "" {
+			sources[i].Name = fmt.Sprintf("source %d", i)
+		}
+	}
+	return &Resource{
+		sources: sources,
+		cfg:     cfg,
+		mu:      &sync.Mutex{},
+	}, nil
+}
+
+// NewResourceFromPaths creates a failover resource from paths in order of priority, using resource.CreateResource
+// for each of them. The paths are used as the names of the sources.
+func NewResourceFromPaths(cfg Config, paths ...string) (*Resource, error) {
+	sources := make([]Source, len(paths))
+	for i, p := range paths {
+		r, err := resource.CreateResource(p)
+		if err != nil {
+			return nil, fmt.Errorf("[gosprout] failover source %q: %w", p, err)
+		}
+		sources[i] = Source{Resource: r, Name: p}
+	}
+	return NewResource(cfg, sources...)
+}
+
+// Active returns the index and name of the authoritative source.
+func (r *Resource) Active() (int, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.active, r.sources[r.active].Name
+}
+
+// switchTo makes the source at index authoritative, and reports the switch if it is a different source.
+func (r *Resource) switchTo(index int, err error) bool {
+	r.mu.Lock()
+	from := r.active
+	r.active = index
+	r.mu.Unlock()
+
+	if from == index {
+		return false
+	}
+	if r.cfg.SwitchHandler != nil {
+		r.cfg.SwitchHandler(SwitchEvent{
+			From:      r.sources[from].Name,
+			FromIndex: from,
+			To:        r.sources[index].Name,
+			ToIndex:   index,
+			Err:       err,
+		})
+	}
+	return true
+}
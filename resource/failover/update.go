@@ -0,0 +1,85 @@
+package failover
+
+import (
+	"context"
+	"io"
+)
+
+// Poll polls the sources in order until one succeeds, which becomes the authoritative source. Switching source is
+// reported as an update. If every source fails, a *SourcesError is returned and the authoritative source is kept.
+func (r *Resource) Poll(ctx context.Context) (bool, error) {
+	errs := make([]error, 0, len(r.sources))
+	for i, s := range r.sources {
+		changed, err := s.Poll(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// The reason for a switch is the error of the source being left, if it is before this one.
+		r.mu.Lock()
+		from := r.active
+		r.mu.Unlock()
+		var reason error
+		if from < i {
+			reason = errs[from]
+		}
+		if r.switchTo(i, reason) {
+			return true, nil
+		}
+		return changed, nil
+	}
+	return false, r.sourcesError(errs)
+}
+
+// Refresh refreshes the authoritative source. If it fails before providing a reader, the following sources are
+// tried in order and the first one to succeed becomes authoritative. Errors after the reader was provided, e.g.
+// when closing it, are given to the error handler as-is.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	r.mu.Lock()
+	start := r.active
+	r.mu.Unlock()
+
+	var errs []error
+	for i := start; i < len(r.sources); i++ {
+		delivered := false
+		var err error
+		r.sources[i].Refresh(ctx, func(reader io.Reader) {
+			delivered = true
+			updateFunc(reader)
+		}, func(e error) {
+			if e == nil {
+				return
+			}
+			if delivered {
+				errorHandler(e)
+			} else if err == nil {
+				err = e
+			}
+		})
+		if delivered {
+			if i != start {
+				r.switchTo(i, errs[0])
+			}
+			return
+		}
+		if err == nil {
+			// The source neither provided a reader nor failed, so there is nothing to fail over from.
+			return
+		}
+		errs = append(errs, err)
+	}
+
+	names := make([]string, len(errs))
+	for j := range errs {
+		names[j] = r.sources[start+j].Name
+	}
+	errorHandler(&SourcesError{Names: names, Errors: errs})
+}
+
+func (r *Resource) sourcesError(errs []error) error {
+	names := make([]string, len(r.sources))
+	for i, s := range r.sources {
+		names[i] = s.Name
+	}
+	return &SourcesError{Names: names, Errors: errs}
+}
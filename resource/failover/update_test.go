@@ -0,0 +1,150 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"github.com/fire00f1y/go-sprout/resource/mem"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestResource_Poll(t *testing.T) {
+	primary := mem.NewResource([]byte("primary"))
+	mirror := mem.NewResource([]byte("mirror"))
+	local := mem.NewResource([]byte("local"))
+	down := errors.New("unreachable")
+
+	var events []SwitchEvent
+	r, err := NewResource(Config{SwitchHandler: func(e SwitchEvent) { events = append(events, e) }},
+		Source{Resource: primary, Name: "gs"},
+		Source{Resource: mirror, Name: "mirror"},
+		Source{Resource: local, Name: "file"},
+	)
+	if err != nil {
+		t.Fatalf("failed to create resource: %v\n", err)
+	}
+
+	steps := []struct {
+		change   func()
+		changed  bool
+		active   int
+		data     string
+		err      bool
+		switched *SwitchEvent
+	}{
+		{change: func() {}, changed: true, active: 0, data: "primary"},
+		{change: func() {}, changed: false, active: 0, data: "primary"},
+		{
+			change:   func() { primary.SetError(down) },
+			changed:  true,
+			active:   1,
+			data:     "mirror",
+			switched: &SwitchEvent{From: "gs", FromIndex: 0, To: "mirror", ToIndex: 1, Err: down},
+		},
+		{change: func() {}, changed: false, active: 1, data: "mirror"},
+		{
+			change:   func() { mirror.SetError(down) },
+			changed:  true,
+			active:   2,
+			data:     "local",
+			switched: &SwitchEvent{From: "mirror", FromIndex: 1, To: "file", ToIndex: 2, Err: down},
+		},
+		{change: func() { local.SetError(down) }, active: 2, err: true},
+		{
+			change:   func() { primary.Set([]byte("primary v2")) },
+			changed:  true,
+			active:   0,
+			data:     "primary v2",
+			switched: &SwitchEvent{From: "file", FromIndex: 2, To: "gs", ToIndex: 0},
+		},
+	}
+
+	ctx := context.Background()
+	for i, step := range steps {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			events = nil
+			step.change()
+			changed, err := r.Poll(ctx)
+			if step.err {
+				var sourcesErr *SourcesError
+				if !errors.As(err, &sourcesErr) || len(sourcesErr.Errors) != 3 {
+					t.Errorf("expected a SourcesError with 3 errors, but got %v\n", err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+			if changed != step.changed {
+				t.Errorf("expected changed to be %v, but got %v\n", step.changed, changed)
+			}
+			if active, _ := r.Active(); active != step.active {
+				t.Errorf("expected source %d to be active, but got %d\n", step.active, active)
+			}
+
+			if step.switched == nil {
+				if len(events) != 0 {
+					t.Errorf("expected no switch, but got %v\n", events)
+				}
+			} else if len(events) != 1 || !reflect.DeepEqual(events[0], *step.switched) {
+				t.Errorf("expected switch %v, but got %v\n", *step.switched, events)
+			}
+
+			if step.err {
+				return
+			}
+			if data := refresh(t, r); data != step.data {
+				t.Errorf("expected %q, but got %q\n", step.data, data)
+			}
+		})
+	}
+}
+
+func TestResource_Refresh(t *testing.T) {
+	primary := mem.NewResource([]byte("primary"))
+	mirror := mem.NewResource([]byte("mirror"))
+	down := errors.New("unreachable")
+
+	var events []SwitchEvent
+	r, err := NewResource(Config{SwitchHandler: func(e SwitchEvent) { events = append(events, e) }},
+		Source{Resource: primary}, Source{Resource: mirror})
+	if err != nil {
+		t.Fatalf("failed to create resource: %v\n", err)
+	}
+
+	primary.SetError(down)
+	if data := refresh(t, r); data != "mirror" {
+		t.Errorf("expected %q, but got %q\n", "mirror", data)
+	}
+	if len(events) != 1 || events[0].To != "source 1" || events[0].Err != down {
+		t.Errorf("expected a switch to source 1, but got %v\n", events)
+	}
+
+	mirror.SetError(down)
+	var refreshErr error
+	r.Refresh(context.Background(), func(io.Reader) {
+		t.Errorf("unexpected update\n")
+	}, func(e error) {
+		refreshErr = e
+	})
+	var sourcesErr *SourcesError
+	if !errors.As(refreshErr, &sourcesErr) || len(sourcesErr.Errors) != 1 {
+		t.Errorf("expected a SourcesError with 1 error, but got %v\n", refreshErr)
+	}
+
+	if _, err := NewResource(Config{}); err == nil {
+		t.Errorf("expected an error for no sources\n")
+	}
+}
+
+func refresh(t *testing.T, r *Resource) string {
+	var data string
+	r.Refresh(context.Background(), func(reader io.Reader) {
+		b, _ := ioutil.ReadAll(reader)
+		data = string(b)
+	}, func(e error) {
+		t.Errorf("unexpected refresh error: %v\n", e)
+	})
+	return data
+}
@@ -3,12 +3,26 @@ package file
 import (
 	"context"
 	"errors"
+	"github.com/fire00f1y/go-sprout/resource/meta"
 	"io"
+	"mime"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 var (
 	notImplementedError = errors.New("[gosprout] refresh from file not yet implemented")
+
+	// extensionTypes are the content types of common config files, which the mime package may not know.
+	extensionTypes = map[string]string{
+		".yaml": "application/yaml",
+		".yml":  "application/yaml",
+		".toml": "application/toml",
+		".csv":  "text/csv",
+		".ini":  "text/plain",
+	}
 )
 
 func (r Resource) Poll(context.Context) (bool, error) {
@@ -26,6 +40,8 @@ func (r Resource) Poll(context.Context) (bool, error) {
 	return false, nil
 }
 
+// Refresh provides a reader for the file, which carries its size and modification time. The content type is derived
+// from the file extension.
 func (r Resource) Refresh(_ context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
 	f, err := os.Open(r.path)
 	if err != nil {
@@ -39,5 +55,25 @@ func (r Resource) Refresh(_ context.Context, updateFunc func(io.Reader), errorHa
 		}
 	}()
 
-	updateFunc(f)
+	m := meta.Metadata{
+		Name:        r.path,
+		ContentType: contentType(r.path),
+		Size:        -1,
+	}
+	if stat, err := f.Stat(); err == nil {
+		m.Size = stat.Size()
+		m.Version = stat.ModTime().UTC().Format(time.RFC3339Nano)
+	}
+	updateFunc(meta.NewReader(f, m))
+}
+
+func contentType(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == "" {
+		return ""
+	}
+	if t, ok := extensionTypes[ext]; ok {
+		return t
+	}
+	return mime.TypeByExtension(ext)
 }
@@ -1,8 +1,13 @@
 package gcs
 
 import (
+	"cloud.google.com/go/storage"
 	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/fire00f1y/go-sprout/resource/meta"
 	"io"
+	"strconv"
 )
 
 // Poll lazily initializes a storage client and then uses it to pull the attributes using the bucket and blob.
@@ -37,7 +42,8 @@ func (r Resource) Poll(ctx context.Context) (bool, error) {
 
 // Refresh currently does not distinguish between a file or a folder level object in GCS.
 // It provides a reader for getting the data from the GCS object. It also manages the closing of the reader after
-// completion.
+// completion. The reader carries the object's metadata, with the generation as the version and the MD5 and CRC32C
+// from the object attributes as checksums.
 func (r Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
 	if c == nil {
 		if e := initClient(ctx); e != nil {
@@ -45,10 +51,14 @@ func (r Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), error
 			return
 		}
 	}
-	reader, err := c.
-		Bucket(r.bucket).
-		Object(r.prefix).
-		NewReader(ctx)
+	obj := c.Bucket(r.bucket).Object(r.prefix)
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+	// The generation is pinned, so the checksums are those of the data being read.
+	reader, err := obj.Generation(attrs.Generation).NewReader(ctx)
 
 	defer func() {
 		if reader != nil {
@@ -61,6 +71,30 @@ func (r Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), error
 		return
 	}
 
-	updateFunc(reader)
+	md := meta.Metadata{
+		Name:        r.prefix,
+		ContentType: reader.Attrs.ContentType,
+		Encoding:    reader.Attrs.ContentEncoding,
+		Size:        reader.Attrs.Size,
+		Version:     strconv.FormatInt(reader.Attrs.Generation, 10),
+		Attributes:  meta.Attributes(attrs.Metadata),
+	}
+	// The stored checksums are of the stored bytes. When the object is gzip encoded and served decompressed, they
+	// do not describe what is read, so they are left out.
+	if reader.Attrs.ContentEncoding == attrs.ContentEncoding {
+		md.Checksums = checksums(attrs)
+	}
+	updateFunc(meta.NewReader(reader, md))
 	return
 }
+
+func checksums(attrs *storage.ObjectAttrs) map[string]string {
+	checksums := map[string]string{
+		"crc32c": fmt.Sprintf("%08x", attrs.CRC32C),
+	}
+	// Composite objects have no MD5.
+	if len(attrs.MD5) > 0 {
+		checksums["md5"] = hex.EncodeToString(attrs.MD5)
+	}
+	return checksums
+}
@@ -0,0 +1,144 @@
+// The git package implements a resource which tracks a branch or tag of a git repository, using the git command
+// line. The version is the commit SHA the ref points to, which is checked with "git ls-remote" so polling does not
+// fetch anything.
+//
+// Paths have the form "<scheme>://<repository>[//<path in repository>][?ref=<branch or tag>]", where the scheme is
+// "git", "git+https", "git+ssh" or "git+file", e.g. "git+file:///srv/config.git//services/app.json?ref=main". If the
+// path in the repository is a file, its content is delivered. If it is a directory or is omitted, the tree is
+// delivered as a tar archive.
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	missingRepositoryError = errors.New("[gosprout] git path is missing a repository")
+	optionLikeError        = errors.New("[gosprout] git repository and ref must not start with '-'")
+	// RefNotFoundError is returned when the ref does not exist in the repository.
+	RefNotFoundError = errors.New("[gosprout] git ref not found")
+
+	defaultRef = "HEAD"
+)
+
+// Config controls which repository and ref a Resource tracks.
+type Config struct {
+	// Repository is anything git can fetch from: a url or a local path.
+	Repository string
+	// Ref is a branch, tag or full ref name. A full commit SHA pins the resource to that commit.
+	Ref string
+	// Path is the file or directory in the repository to deliver. Empty means the whole tree.
+	Path string
+	// CacheDir is where the bare repository commits are fetched into. It defaults to a private directory under
+	// os.UserCacheDir() named after the repository and ref.
+	CacheDir string
+	// GitBinary is the git executable to use. It defaults to "git" from the PATH.
+	GitBinary string
+}
+
+// Resource is a file or tree at a ref of a git repository.
+type Resource struct {
+	cfg Config
+	// trackingRef is where the tracked ref is fetched to in the cache repository. It is unique to the repository
+	// and ref, so resources sharing a cache directory do not overwrite each other's fetches.
+	trackingRef string
+
+	mu      *sync.Mutex
+	loaded  bool
+	commit  string
+	fetched bool
+}
+
+// NewResource creates a resource from a full "<scheme>://<repository>[//<path>][?ref=<ref>]" path, including
+// the scheme.
+func NewResource(path string) (*Resource, error) {
+	cfg, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewResourceWithConfig(cfg)
+}
+
+// NewResourceWithConfig creates a resource for the configured repository.
+func NewResourceWithConfig(cfg Config) (*Resource, error) {
+	if cfg.Repository == "" {
+		return nil, missingRepositoryError
+	}
+	if cfg.Ref == "" {
+		cfg.Ref = defaultRef
+	}
+	// git would take them for options, like --upload-pack which runs a command.
+	if strings.HasPrefix(cfg.Repository, "-") || strings.HasPrefix(cfg.Ref, "-") {
+		return nil, optionLikeError
+	}
+	cfg.Path = strings.Trim(cfg.Path, "/")
+	if cfg.GitBinary == "" {
+		cfg.GitBinary = "git"
+	}
+	sum := sha256.Sum256([]byte(cfg.Repository + "\x00" + cfg.Ref))
+	key := hex.EncodeToString(sum[:8])
+	if cfg.CacheDir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			base = os.TempDir()
+		}
+		cfg.CacheDir = filepath.Join(base, "gosprout-git", key)
+	}
+
+	return &Resource{
+		cfg:         cfg,
+		trackingRef: trackingRefPrefix + key,
+		mu:          &sync.Mutex{},
+	}, nil
+}
+
+// Commit returns the commit SHA seen by the last Poll.
+func (r *Resource) Commit() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.commit
+}
+
+func parsePath(path string) (Config, error) {
+	var cfg Config
+	if i := strings.LastIndex(path, "?"); i >= 0 {
+		for _, param := range strings.Split(path[i+1:], "&") {
+			if strings.HasPrefix(param, "ref=") {
+				cfg.Ref = strings.TrimPrefix(param, "ref=")
+			}
+		}
+		path = path[:i]
+	}
+
+	scheme := ""
+	if i := strings.Index(path, "://"); i >= 0 {
+		scheme, path = path[:i], path[i+3:]
+	}
+	// The path in the repository is separated with a double slash, which cannot be the start of the
+	// repository path itself for file urls, so it is looked for after the first character.
+	if len(path) > 1 {
+		if i := strings.Index(path[1:], "//"); i >= 0 {
+			cfg.Path = path[i+3:]
+			path = path[:i+1]
+		}
+	}
+
+	switch scheme = strings.TrimPrefix(scheme, "git+"); scheme {
+	case "":
+		cfg.Repository = path
+	case "file":
+		cfg.Repository = "/" + strings.TrimPrefix(path, "/")
+	default:
+		cfg.Repository = scheme + "://" + path
+	}
+	if strings.Trim(path, "/") == "" {
+		return cfg, missingRepositoryError
+	}
+	return cfg, nil
+}
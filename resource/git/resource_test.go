@@ -0,0 +1,71 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNewResource(t *testing.T) {
+	tests := []struct {
+		path          string
+		expected      Config
+		expectedError error
+	}{
+		{
+			path:     "git+file:///srv/config.git//services/app.json?ref=main",
+			expected: Config{Repository: "/srv/config.git", Path: "services/app.json", Ref: "main"},
+		},
+		{
+			path:     "git://example.com/config.git",
+			expected: Config{Repository: "git://example.com/config.git", Ref: defaultRef},
+		},
+		{
+			path:     "git+https://github.com/org/config.git//services/?ref=v1.2.0",
+			expected: Config{Repository: "https://github.com/org/config.git", Path: "services", Ref: "v1.2.0"},
+		},
+		{
+			path:     "git+ssh://git@github.com/org/config.git//app.yaml",
+			expected: Config{Repository: "ssh://git@github.com/org/config.git", Path: "app.yaml", Ref: defaultRef},
+		},
+		{
+			path:          "git+file://",
+			expectedError: missingRepositoryError,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, err := NewResource(test.path)
+			if err != test.expectedError {
+				t.Errorf("expected error %v; got %v\n", test.expectedError, err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			c := res.cfg
+			if c.Repository != test.expected.Repository || c.Path != test.expected.Path || c.Ref != test.expected.Ref {
+				t.Errorf("expected %+v; got %+v\n", test.expected, c)
+			}
+		})
+	}
+}
+
+func TestNewResourceWithConfig_CacheKey(t *testing.T) {
+	main, _ := NewResourceWithConfig(Config{Repository: "/srv/config.git", Ref: "main"})
+	dev, _ := NewResourceWithConfig(Config{Repository: "/srv/config.git", Ref: "dev"})
+	again, _ := NewResourceWithConfig(Config{Repository: "/srv/config.git", Ref: "main"})
+
+	if main.cfg.CacheDir == dev.cfg.CacheDir || main.trackingRef == dev.trackingRef {
+		t.Errorf("expected refs of a repository not to share a cache; got %s and %s\n", main.cfg.CacheDir, main.trackingRef)
+	}
+	if main.cfg.CacheDir != again.cfg.CacheDir || main.trackingRef != again.trackingRef {
+		t.Errorf("expected the same repository and ref to reuse the cache\n")
+	}
+	if base, err := os.UserCacheDir(); err == nil && !strings.HasPrefix(main.cfg.CacheDir, base+string(filepath.Separator)) {
+		t.Errorf("expected the cache under %s; got %s\n", base, main.cfg.CacheDir)
+	}
+}
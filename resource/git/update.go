@@ -0,0 +1,191 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+var (
+	fullSHA = regexp.MustCompile("^[0-9a-f]{40}$")
+
+	// trackingRefPrefix is where tracked refs are fetched to in the cache repository.
+	trackingRefPrefix = "refs/gosprout/tracked/"
+)
+
+// Poll resolves the ref in the repository with "git ls-remote" and compares the commit with the last Poll. A ref
+// which is already a commit SHA never changes.
+func (r *Resource) Poll(ctx context.Context) (bool, error) {
+	commit := r.cfg.Ref
+	if !fullSHA.MatchString(commit) {
+		var err error
+		if commit, err = r.resolve(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := !r.loaded || commit != r.commit
+	r.loaded = true
+	r.commit = commit
+	return changed, nil
+}
+
+// Refresh fetches the commit seen by the last Poll into the cache repository and provides a reader for the file
+// at the path, or for a tar archive of the tree at the path. If the ref moved on and the polled commit cannot be
+// fetched anymore, the new commit is used instead.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	r.mu.Lock()
+	loaded := r.loaded
+	r.mu.Unlock()
+	if !loaded {
+		if _, err := r.Poll(ctx); err != nil {
+			errorHandler(err)
+			return
+		}
+	}
+
+	commit, err := r.fetch(ctx)
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+
+	object := commit
+	if r.cfg.Path != "" {
+		object += ":" + r.cfg.Path
+	}
+	kind, err := r.git(ctx, r.cfg.CacheDir, "cat-file", "-t", object)
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+
+	var cmd *exec.Cmd
+	if strings.TrimSpace(kind) == "blob" {
+		cmd = r.command(ctx, r.cfg.CacheDir, "cat-file", "blob", object)
+	} else {
+		cmd = r.command(ctx, r.cfg.CacheDir, "archive", "--format=tar", object)
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		errorHandler(err)
+		return
+	}
+
+	updateFunc(stdout)
+	// Drain anything the update func did not read so the command can exit.
+	io.Copy(ioutil.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		errorHandler(commandError(cmd, err, stderr))
+	}
+}
+
+// resolve finds the commit of the ref in the remote repository. An annotated tag is resolved to the commit it
+// points to.
+func (r *Resource) resolve(ctx context.Context) (string, error) {
+	out, err := r.git(ctx, "", "ls-remote", "--", r.cfg.Repository, r.cfg.Ref, r.cfg.Ref+"^{}")
+	if err != nil {
+		return "", err
+	}
+
+	refs := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			refs[fields[1]] = fields[0]
+		}
+	}
+
+	ref := r.cfg.Ref
+	for _, name := range []string{
+		ref + "^{}", ref,
+		"refs/heads/" + ref,
+		"refs/tags/" + ref + "^{}", "refs/tags/" + ref,
+	} {
+		if commit, ok := refs[name]; ok {
+			return commit, nil
+		}
+	}
+	return "", RefNotFoundError
+}
+
+// fetch makes sure the polled commit is in the cache repository and returns it.
+func (r *Resource) fetch(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	commit := r.commit
+	r.mu.Unlock()
+
+	if _, err := os.Stat(r.cfg.CacheDir); os.IsNotExist(err) {
+		// The cache decides what is delivered, so no other user may write to it.
+		if err := os.MkdirAll(r.cfg.CacheDir, 0700); err != nil {
+			return "", err
+		}
+		if _, err := r.git(ctx, r.cfg.CacheDir, "init", "--bare", "--quiet"); err != nil {
+			return "", err
+		}
+	}
+	if _, err := r.git(ctx, r.cfg.CacheDir, "cat-file", "-e", commit+"^{commit}"); err == nil {
+		return commit, nil
+	}
+
+	ref := r.cfg.Ref
+	if fullSHA.MatchString(ref) {
+		ref = commit
+	}
+	if _, err := r.git(ctx, r.cfg.CacheDir, "fetch", "--quiet", "--no-tags", "--", r.cfg.Repository, "+"+ref+":"+r.trackingRef); err != nil {
+		return "", err
+	}
+	if _, err := r.git(ctx, r.cfg.CacheDir, "cat-file", "-e", commit+"^{commit}"); err == nil {
+		return commit, nil
+	}
+
+	out, err := r.git(ctx, r.cfg.CacheDir, "rev-parse", r.trackingRef+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	commit = strings.TrimSpace(out)
+	r.mu.Lock()
+	r.commit = commit
+	r.mu.Unlock()
+	return commit, nil
+}
+
+func (r *Resource) command(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	if dir != "" {
+		args = append([]string{"--git-dir", dir}, args...)
+	}
+	cmd := exec.CommandContext(ctx, r.cfg.GitBinary, args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	return cmd
+}
+
+// git runs a command and returns its output.
+func (r *Resource) git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := r.command(ctx, dir, args...)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Run(); err != nil {
+		return "", commandError(cmd, err, stderr)
+	}
+	return stdout.String(), nil
+}
+
+func commandError(cmd *exec.Cmd, err error, stderr *bytes.Buffer) error {
+	return fmt.Errorf("[gosprout] %s failed: %v: %s", strings.Join(cmd.Args, " "), err, strings.TrimSpace(stderr.String()))
+}
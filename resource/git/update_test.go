@@ -0,0 +1,238 @@
+package git
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testRepository is a bare repository in a temporary directory, with a work tree to commit to it from.
+type testRepository struct {
+	t    *testing.T
+	dir  string
+	bare string
+	work string
+}
+
+func newTestRepository(t *testing.T) *testRepository {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "gosprout-git-test")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v\n", err)
+	}
+	repo := &testRepository{t: t, dir: dir, bare: filepath.Join(dir, "remote.git"), work: filepath.Join(dir, "work")}
+	repo.run("", "init", "--bare", "--quiet", repo.bare)
+	repo.run("", "init", "--quiet", repo.work)
+	return repo
+}
+
+func (repo *testRepository) Close() {
+	os.RemoveAll(repo.dir)
+}
+
+func (repo *testRepository) run(dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		repo.t.Fatalf("git %v failed: %v: %s\n", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commit writes the files to the work tree, commits them and pushes to the branch.
+func (repo *testRepository) commit(branch string, files map[string]string) string {
+	for name, content := range files {
+		path := filepath.Join(repo.work, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			repo.t.Fatalf("could not write %s: %v\n", name, err)
+		}
+	}
+	repo.run(repo.work, "add", "-A")
+	repo.run(repo.work, "commit", "--quiet", "-m", "update")
+	repo.run(repo.work, "push", "--quiet", repo.bare, "HEAD:refs/heads/"+branch)
+	return repo.run(repo.work, "rev-parse", "HEAD")
+}
+
+func (repo *testRepository) resource(path string) *Resource {
+	res, err := NewResourceWithConfig(Config{
+		Repository: repo.bare,
+		Ref:        "main",
+		Path:       path,
+		CacheDir:   filepath.Join(repo.dir, "cache"),
+	})
+	if err != nil {
+		repo.t.Fatalf("error creating resource: %v\n", err)
+	}
+	return res
+}
+
+func TestResource_Poll(t *testing.T) {
+	repo := newTestRepository(t)
+	defer repo.Close()
+	first := repo.commit("main", map[string]string{"app.json": "v1"})
+	res := repo.resource("app.json")
+
+	for i, expected := range []bool{true, false} {
+		if updated, err := res.Poll(context.Background()); updated != expected || err != nil {
+			t.Errorf("poll %d: expected %v; got %v, %v\n", i, expected, updated, err)
+		}
+	}
+	if res.Commit() != first {
+		t.Errorf("expected commit %s; got %s\n", first, res.Commit())
+	}
+
+	second := repo.commit("main", map[string]string{"app.json": "v2"})
+	if updated, err := res.Poll(context.Background()); !updated || err != nil {
+		t.Errorf("expected update after a push; got %v, %v\n", updated, err)
+	}
+	if res.Commit() != second {
+		t.Errorf("expected commit %s; got %s\n", second, res.Commit())
+	}
+
+	res.cfg.Ref = "missing"
+	if _, err := res.Poll(context.Background()); err != RefNotFoundError {
+		t.Errorf("expected RefNotFoundError; got %v\n", err)
+	}
+}
+
+func TestResource_RefreshFile(t *testing.T) {
+	repo := newTestRepository(t)
+	defer repo.Close()
+	res := repo.resource("config/app.json")
+
+	for i, v := range []string{"v1", "v2"} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			repo.commit("main", map[string]string{"config/app.json": v, "other.txt": "ignored"})
+			if _, err := res.Poll(context.Background()); err != nil {
+				t.Errorf("error polling: %v\n", err)
+				return
+			}
+
+			value := ""
+			res.Refresh(context.Background(),
+				func(r io.Reader) {
+					b, _ := ioutil.ReadAll(r)
+					value = string(b)
+				}, func(e error) {
+					t.Errorf("error during refresh: %v\n", e)
+				})
+			if value != v {
+				t.Errorf("expected %s; got %s\n", v, value)
+			}
+		})
+	}
+}
+
+func TestResource_RefreshTree(t *testing.T) {
+	repo := newTestRepository(t)
+	defer repo.Close()
+	repo.commit("main", map[string]string{
+		"config/app.json":     "app",
+		"config/db/main.yaml": "db",
+		"README.md":           "ignored",
+	})
+	res := repo.resource("config/")
+
+	files := map[string]string{}
+	res.Refresh(context.Background(),
+		func(r io.Reader) {
+			tr := tar.NewReader(r)
+			for {
+				h, err := tr.Next()
+				if err != nil {
+					return
+				}
+				if h.Typeflag == tar.TypeReg {
+					b, _ := ioutil.ReadAll(tr)
+					files[h.Name] = string(b)
+				}
+			}
+		}, func(e error) {
+			t.Errorf("error during refresh: %v\n", e)
+		})
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "app.json,db/main.yaml" || files["db/main.yaml"] != "db" {
+		t.Errorf("unexpected files in tree: %v\n", files)
+	}
+}
+
+func TestResource_SharedCacheDir(t *testing.T) {
+	repo := newTestRepository(t)
+	defer repo.Close()
+	repo.commit("main", map[string]string{"app.json": "main"})
+	repo.commit("dev", map[string]string{"app.json": "dev"})
+
+	resources := map[string]*Resource{}
+	for _, ref := range []string{"main", "dev"} {
+		res, err := NewResourceWithConfig(Config{
+			Repository: repo.bare,
+			Ref:        ref,
+			Path:       "app.json",
+			CacheDir:   filepath.Join(repo.dir, "cache"),
+		})
+		if err != nil {
+			t.Fatalf("error creating resource: %v\n", err)
+		}
+		if _, err := res.Poll(context.Background()); err != nil {
+			t.Fatalf("error polling: %v\n", err)
+		}
+		resources[ref] = res
+	}
+
+	for ref, res := range resources {
+		value := ""
+		res.Refresh(context.Background(), func(r io.Reader) {
+			b, _ := ioutil.ReadAll(r)
+			value = string(b)
+		}, func(e error) {
+			t.Errorf("error during refresh: %v\n", e)
+		})
+		if value != ref {
+			t.Errorf("expected %s; got %s\n", ref, value)
+		}
+	}
+}
+
+func TestResource_OptionArguments(t *testing.T) {
+	repo := newTestRepository(t)
+	defer repo.Close()
+	repo.commit("main", map[string]string{"app.json": "v1"})
+	marker := filepath.Join(repo.dir, "marker")
+	inject := "--upload-pack=touch " + marker + "; git-upload-pack"
+
+	for i, cfg := range []Config{{Repository: inject}, {Repository: repo.bare, Ref: inject}} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if _, err := NewResourceWithConfig(cfg); err != optionLikeError {
+				t.Errorf("expected %v; got %v\n", optionLikeError, err)
+			}
+
+			// Even if the config is changed afterwards, git must not take the value for an option.
+			res := repo.resource("app.json")
+			res.cfg.Repository, res.cfg.Ref = cfg.Repository, cfg.Ref
+			if res.cfg.Ref == "" {
+				res.cfg.Ref = "main"
+			}
+			res.Poll(context.Background())
+			if _, err := os.Stat(marker); err == nil {
+				t.Errorf("expected the upload pack option not to run a command\n")
+			}
+		})
+	}
+}
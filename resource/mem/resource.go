@@ -0,0 +1,133 @@
+// The mem package implements a resource held in memory, whose content is set programmatically. It is meant for
+// tests, and for data which is produced by the program itself but should be consumed like any other resource.
+package mem
+
+import (
+	"bytes"
+	"context"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Resource is data in memory. Every Set is a new version, even if the data is the same. An error set with
+// SetError is returned by Poll and given to the Refresh error handler until it is cleared.
+type Resource struct {
+	mu          *sync.Mutex
+	data        []byte
+	err         error
+	version     uint64
+	polled      uint64
+	subscribers map[chan struct{}]struct{}
+}
+
+// NotifyingResource is a Resource which also implements resource.Notifier, notifying on every Set.
+type NotifyingResource struct {
+	*Resource
+}
+
+// NewResource creates a resource with the initial data as its first version.
+func NewResource(data []byte) *Resource {
+	r := &Resource{
+		mu:          &sync.Mutex{},
+		subscribers: map[chan struct{}]struct{}{},
+	}
+	r.Set(data)
+	return r
+}
+
+// Notifier returns the resource as a resource.Notifier, which pushes every Set instead of waiting for a Poll.
+func (r *Resource) Notifier() *NotifyingResource {
+	return &NotifyingResource{Resource: r}
+}
+
+// Set replaces the data with a copy of the provided data, as a new version. It also clears any error.
+func (r *Resource) Set(data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data = append([]byte{}, data...)
+	r.err = nil
+	r.version++
+	for ch := range r.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// SetError makes Poll and Refresh fail with the error, until it is cleared with SetError(nil) or a Set.
+func (r *Resource) SetError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+// Version returns the current version, which starts at 1 and is incremented on every Set.
+func (r *Resource) Version() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.version
+}
+
+// Poll reports whether there was a Set since the last Poll.
+func (r *Resource) Poll(context.Context) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return false, r.err
+	}
+	changed := r.version != r.polled
+	r.polled = r.version
+	return changed, nil
+}
+
+// Refresh provides a reader for the current data, which carries its size and version.
+func (r *Resource) Refresh(_ context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	r.mu.Lock()
+	data, err, version := r.data, r.err, r.version
+	r.mu.Unlock()
+
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+	updateFunc(meta.NewReader(bytes.NewReader(data), meta.Metadata{
+		Size:    int64(len(data)),
+		Version: strconv.FormatUint(version, 10),
+	}))
+}
+
+// Notify sends on the returned channel once for the current data and then for every Set, until the context is
+// done.
+func (r *NotifyingResource) Notify(ctx context.Context, _ func(error)) <-chan struct{} {
+	sub := make(chan struct{}, 1)
+	sub <- struct{}{}
+	r.mu.Lock()
+	r.subscribers[sub] = struct{}{}
+	r.mu.Unlock()
+
+	ch := make(chan struct{})
+	go func() {
+		defer close(ch)
+		defer func() {
+			r.mu.Lock()
+			delete(r.subscribers, sub)
+			r.mu.Unlock()
+		}()
+		for {
+			select {
+			case <-sub:
+				select {
+				case ch <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,109 @@
+package mem
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func readAll(t *testing.T, r *Resource) string {
+	value := ""
+	r.Refresh(context.Background(),
+		func(r io.Reader) {
+			b, _ := ioutil.ReadAll(r)
+			value = string(b)
+		}, func(e error) {
+			t.Errorf("error during refresh: %v\n", e)
+		})
+	return value
+}
+
+func TestResource_Poll(t *testing.T) {
+	res := NewResource([]byte("v1"))
+	failure := errors.New("unavailable")
+
+	tests := []struct {
+		set      func()
+		updated  bool
+		err      error
+		expected string
+	}{
+		{set: func() {}, updated: true, expected: "v1"},
+		{set: func() {}, updated: false, expected: "v1"},
+		{set: func() { res.Set([]byte("v1")) }, updated: true, expected: "v1"},
+		{set: func() { res.Set([]byte("v2")) }, updated: true, expected: "v2"},
+		{set: func() { res.SetError(failure) }, updated: false, err: failure},
+		{set: func() { res.SetError(nil) }, updated: false, expected: "v2"},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			test.set()
+			updated, err := res.Poll(context.Background())
+			if err != test.err {
+				t.Errorf("expected error %v; got %v\n", test.err, err)
+			}
+			if updated != test.updated {
+				t.Errorf("expected updated to be %v; got %v\n", test.updated, updated)
+			}
+			if err == nil {
+				if v := readAll(t, res); v != test.expected {
+					t.Errorf("expected %s; got %s\n", test.expected, v)
+				}
+			}
+		})
+	}
+
+	if v := res.Version(); v != 3 {
+		t.Errorf("expected version 3; got %d\n", v)
+	}
+}
+
+func TestResource_RefreshError(t *testing.T) {
+	res := NewResource(nil)
+	res.SetError(io.ErrUnexpectedEOF)
+
+	var err error
+	res.Refresh(context.Background(), func(r io.Reader) {
+		t.Errorf("expected no update call, but got one\n")
+	}, func(e error) {
+		err = e
+	})
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected %v; got %v\n", io.ErrUnexpectedEOF, err)
+	}
+}
+
+func TestNotifyingResource_Notify(t *testing.T) {
+	res := NewResource([]byte("v1"))
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := res.Notifier().Notify(ctx, nil)
+
+	for _, v := range []string{"v1", "v2", "v3"} {
+		if v != "v1" {
+			res.Set([]byte(v))
+		}
+		select {
+		case <-ch:
+			if got := readAll(t, res); got != v {
+				t.Errorf("expected %s; got %s\n", v, got)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("timed out waiting for a notification of %s\n", v)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Errorf("expected the channel to be closed\n")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("channel not closed after cancel\n")
+	}
+}
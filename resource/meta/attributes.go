@@ -0,0 +1,36 @@
+package meta
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AttributesFromHeader returns the headers with the prefix, like "x-amz-meta-", as attributes keyed by the rest of
+// their lowercase name, or nil if there are none.
+func AttributesFromHeader(h http.Header, prefix string) map[string]string {
+	prefix = strings.ToLower(prefix)
+	var attributes map[string]string
+	for name, values := range h {
+		name = strings.ToLower(name)
+		if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) || len(values) == 0 {
+			continue
+		}
+		if attributes == nil {
+			attributes = map[string]string{}
+		}
+		attributes[name[len(prefix):]] = values[0]
+	}
+	return attributes
+}
+
+// Attributes returns a copy of custom object metadata with lowercase keys, or nil if it is empty.
+func Attributes(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[strings.ToLower(k)] = v
+	}
+	return out
+}
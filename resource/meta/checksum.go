@@ -0,0 +1,60 @@
+package meta
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// digestAlgorithms maps the algorithm names used in headers to the names used in Metadata.Checksums.
+var digestAlgorithms = map[string]string{
+	"md5":     "md5",
+	"sha":     "sha1",
+	"sha1":    "sha1",
+	"sha-1":   "sha1",
+	"sha-256": "sha256",
+	"sha256":  "sha256",
+	"sha-512": "sha512",
+	"sha512":  "sha512",
+	"crc32c":  "crc32c",
+	"crc32":   "crc32",
+}
+
+// ChecksumsFromHeader returns the checksums of an HTTP response from the Content-MD5, Digest (RFC 3230),
+// X-Goog-Hash and X-Amz-Checksum-* headers, or nil if there are none.
+func ChecksumsFromHeader(h http.Header) map[string]string {
+	checksums := map[string]string{}
+	add := func(algorithm, b64 string) {
+		a, ok := digestAlgorithms[strings.ToLower(strings.TrimSpace(algorithm))]
+		if !ok {
+			return
+		}
+		if b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64)); err == nil && len(b) > 0 {
+			checksums[a] = hex.EncodeToString(b)
+		}
+	}
+
+	if v := h.Get("Content-MD5"); v != "" {
+		add("md5", v)
+	}
+	for _, name := range []string{"Digest", "X-Goog-Hash"} {
+		for _, v := range h[http.CanonicalHeaderKey(name)] {
+			for _, part := range strings.Split(v, ",") {
+				if i := strings.Index(part, "="); i > 0 {
+					add(part[:i], part[i+1:])
+				}
+			}
+		}
+	}
+	for _, a := range []string{"crc32", "crc32c", "sha1", "sha256"} {
+		if v := h.Get("X-Amz-Checksum-" + a); v != "" {
+			add(a, v)
+		}
+	}
+
+	if len(checksums) == 0 {
+		return nil
+	}
+	return checksums
+}
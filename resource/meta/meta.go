@@ -0,0 +1,57 @@
+// The meta package defines the metadata a resource can attach to the reader it provides on Refresh. It is a
+// separate package so every resource package can use it without depending on the resource package, which imports
+// them all. Users should use the aliases in the resource package.
+package meta
+
+import (
+	"io"
+)
+
+// Metadata describes the data provided by a Refresh, as far as the resource knows it.
+type Metadata struct {
+	// Name is the name of the data in the resource, like a file path or object key. Its extension can hint at the
+	// content type.
+	Name string
+	// ContentType is the MIME type of the data, e.g. "application/json". It may include parameters.
+	ContentType string
+	// Encoding is the content encoding applied on top of the content type, e.g. "gzip".
+	Encoding string
+	// Size is the length of the data in bytes, or -1 if it is not known.
+	Size int64
+	// Version identifies the version of the data in the resource's own terms, e.g. a generation number or an ETag.
+	Version string
+	// Checksums are the digests the resource published for the data, by algorithm ("md5", "crc32c", "sha256"...)
+	// as lowercase hex.
+	Checksums map[string]string
+	// Attributes are the custom metadata set on the object, like GCS object metadata or the x-amz-meta-* headers of
+	// S3, by lowercase key.
+	Attributes map[string]string
+}
+
+// Reader is a reader which carries the metadata of its data.
+type Reader interface {
+	io.Reader
+	Metadata() Metadata
+}
+
+type reader struct {
+	io.Reader
+	metadata Metadata
+}
+
+func (r *reader) Metadata() Metadata {
+	return r.metadata
+}
+
+// NewReader attaches the metadata to a reader.
+func NewReader(r io.Reader, m Metadata) Reader {
+	return &reader{Reader: r, metadata: m}
+}
+
+// Of returns the metadata attached to a reader, or false if it has none.
+func Of(r io.Reader) (Metadata, bool) {
+	if m, ok := r.(Reader); ok {
+		return m.Metadata(), true
+	}
+	return Metadata{Size: -1}, false
+}
@@ -0,0 +1,21 @@
+package resource
+
+import (
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"io"
+)
+
+// Metadata describes the data provided by a Refresh: its content type, encoding, size and version. Resources
+// which know it attach it to the reader given to the update func.
+type Metadata = meta.Metadata
+
+// WithMetadata attaches the metadata to a reader. Custom resources can use it in Refresh.
+func WithMetadata(r io.Reader, m Metadata) io.Reader {
+	return meta.NewReader(r, m)
+}
+
+// MetadataOf returns the metadata attached to the reader provided on Refresh, or false if the resource did not
+// attach any.
+func MetadataOf(r io.Reader) (Metadata, bool) {
+	return meta.Of(r)
+}
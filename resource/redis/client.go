@@ -0,0 +1,175 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// serverError is an error reply from the server.
+type serverError string
+
+func (e serverError) Error() string {
+	return "[gosprout] redis: " + string(e)
+}
+
+var (
+	unexpectedReplyError = errors.New("[gosprout] redis: unexpected reply")
+)
+
+// client is a minimal connection to a Redis server speaking RESP2, enough to read keys and subscribe to
+// keyspace notifications.
+//
+// See: https://redis.io/topics/protocol
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dial connects to the server, authenticates and selects the database.
+func dial(ctx context.Context, cfg Config) (*client, error) {
+	d := &net.Dialer{Timeout: cfg.DialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TLSConfig != nil {
+		conn = tls.Client(conn, cfg.TLSConfig)
+	}
+	c := &client{conn: conn, r: bufio.NewReader(conn)}
+
+	if cfg.Password != "" {
+		args := []string{"AUTH", cfg.Password}
+		if cfg.Username != "" {
+			args = []string{"AUTH", cfg.Username, cfg.Password}
+		}
+		if _, err := c.do(ctx, args...); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if cfg.DB != 0 {
+		if _, err := c.do(ctx, "SELECT", strconv.Itoa(cfg.DB)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *client) Close() error {
+	return c.conn.Close()
+}
+
+// do sends a command and reads its reply. The context deadline, if any, applies to the whole exchange.
+func (c *client) do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.receive()
+}
+
+func (c *client) send(args ...string) error {
+	b := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		b = append(b, "$"+strconv.Itoa(len(a))+"\r\n"+a+"\r\n"...)
+	}
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// receive reads one reply. Bulk strings are returned as []byte, nil bulk strings and arrays as nil, integers as
+// int64, simple strings as string and arrays as []interface{}. Error replies are returned as the error.
+func (c *client) receive() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, unexpectedReplyError
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, serverError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = c.receive(); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", unexpectedReplyError, line)
+	}
+}
+
+// subscribe sends SUBSCRIBE for the channel and waits for its confirmation. Afterwards, the connection
+// can only be used to receive messages.
+func (c *client) subscribe(ctx context.Context, channel string) error {
+	reply, err := c.do(ctx, "SUBSCRIBE", channel)
+	if err != nil {
+		return err
+	}
+	if values, ok := reply.([]interface{}); !ok || len(values) != 3 || toString(values[0]) != "subscribe" {
+		return unexpectedReplyError
+	}
+	// Messages are waited on indefinitely.
+	return c.conn.SetDeadline(time.Time{})
+}
+
+// message waits for the next published message and returns its payload.
+func (c *client) message() (string, error) {
+	for {
+		reply, err := c.receive()
+		if err != nil {
+			return "", err
+		}
+		values, ok := reply.([]interface{})
+		if ok && len(values) == 3 && toString(values[0]) == "message" {
+			return toString(values[2]), nil
+		}
+	}
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case []byte:
+		return string(s)
+	case string:
+		return s
+	case int64:
+		return strconv.FormatInt(s, 10)
+	}
+	return ""
+}
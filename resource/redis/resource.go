@@ -0,0 +1,148 @@
+// The redis package implements a resource for a key in Redis. Strings are delivered as-is and hashes are
+// rendered as a JSON object of their fields.
+//
+// Paths have the form "[user:password@]host:port/<db>/<key>" and accept the query parameters "versionKey",
+// "versionField" and "notify", e.g. "redis://localhost:6379/0/config:app?notify". See Config for what they do.
+package redis
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	missingKeyError = errors.New("[gosprout] redis path is missing a key")
+	// KeyNotFoundError is returned when the key does not exist.
+	KeyNotFoundError = errors.New("[gosprout] redis key not found")
+	// UnsupportedTypeError is returned when the key is not a string or a hash.
+	UnsupportedTypeError = errors.New("[gosprout] redis key is not a string or a hash")
+
+	defaultAddress       = "127.0.0.1:6379"
+	defaultDialTimeout   = 5 * time.Second
+	defaultRetryInterval = time.Second
+)
+
+// Config controls how a Resource connects to Redis and detects changes.
+type Config struct {
+	Address  string
+	Username string
+	Password string
+	DB       int
+	// TLSConfig enables TLS when set, as done for "rediss://" paths.
+	TLSConfig *tls.Config
+
+	// VersionKey is a separate key holding the version of the data, which is read on Poll instead of the data.
+	VersionKey string
+	// VersionField is a field of the hash holding the version of the data, which is read on Poll instead of the
+	// whole hash. It is ignored if VersionKey is set.
+	VersionField string
+	// KeyspaceNotifications subscribes to the keyspace channel of the key, so changes are pushed instead of
+	// polled. The server must have notify-keyspace-events enabled for keyspace events ("K") of the relevant
+	// classes, unless ConfigureNotifications is set.
+	KeyspaceNotifications bool
+	// ConfigureNotifications enables keyspace notifications on the server with CONFIG SET. Managed services
+	// usually do not allow this.
+	ConfigureNotifications bool
+
+	DialTimeout time.Duration
+	// RetryInterval is how long to wait before subscribing again after the subscription failed.
+	RetryInterval time.Duration
+}
+
+// Resource is a string or hash key in Redis. Without a version key or field, the version is a digest of the
+// content, so Poll reads the whole value.
+type Resource struct {
+	key string
+	cfg Config
+
+	mu      *sync.Mutex
+	loaded  bool
+	version string
+}
+
+// NotifyingResource is a Resource which also implements resource.Notifier using keyspace notifications.
+type NotifyingResource struct {
+	*Resource
+}
+
+// NewResource creates a resource from a "[user:password@]host:port/<db>/<key>" path with optional query
+// parameters.
+func NewResource(path string) (*Resource, error) {
+	return newResource("redis", path)
+}
+
+// NewTLSResource is the same as NewResource, but connects with TLS, as for "rediss://" paths.
+func NewTLSResource(path string) (*Resource, error) {
+	return newResource("rediss", path)
+}
+
+func newResource(scheme, path string) (*Resource, error) {
+	u, err := url.Parse(scheme + "://" + strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Config{Address: u.Host}
+	if u.User != nil {
+		cfg.Username = u.User.Username()
+		cfg.Password, _ = u.User.Password()
+	}
+	if scheme == "rediss" {
+		cfg.TLSConfig = &tls.Config{ServerName: u.Hostname()}
+	}
+
+	key := strings.TrimPrefix(u.Path, "/")
+	if i := strings.Index(key, "/"); i >= 0 {
+		if db, err := strconv.Atoi(key[:i]); err == nil {
+			cfg.DB = db
+			key = key[i+1:]
+		}
+	}
+
+	q := u.Query()
+	cfg.VersionKey = q.Get("versionKey")
+	cfg.VersionField = q.Get("versionField")
+	_, cfg.KeyspaceNotifications = q["notify"]
+	return NewResourceWithConfig(key, cfg)
+}
+
+// NewResourceWithConfig creates a resource for the key.
+func NewResourceWithConfig(key string, cfg Config) (*Resource, error) {
+	if key == "" {
+		return nil, missingKeyError
+	}
+	if cfg.Address == "" {
+		cfg.Address = defaultAddress
+	}
+	if !strings.Contains(cfg.Address, ":") {
+		cfg.Address += ":6379"
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = defaultDialTimeout
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = defaultRetryInterval
+	}
+
+	return &Resource{
+		key: key,
+		cfg: cfg,
+		mu:  &sync.Mutex{},
+	}, nil
+}
+
+// UsesKeyspaceNotifications returns whether the resource was configured for keyspace notifications, in which
+// case Notifier should be used to watch it.
+func (r *Resource) UsesKeyspaceNotifications() bool {
+	return r.cfg.KeyspaceNotifications
+}
+
+// Notifier returns the resource as a resource.Notifier, which watches the key with keyspace notifications.
+func (r *Resource) Notifier() *NotifyingResource {
+	return &NotifyingResource{Resource: r}
+}
@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestNewResource(t *testing.T) {
+	tests := []struct {
+		path          string
+		key           string
+		expected      Config
+		expectedError error
+	}{
+		{
+			path:     "localhost:6379/0/config:app",
+			key:      "config:app",
+			expected: Config{Address: "localhost:6379"},
+		},
+		{
+			path:     "user:pass@redis/2/app/settings?versionField=rev&notify",
+			key:      "app/settings",
+			expected: Config{Address: "redis:6379", Username: "user", Password: "pass", DB: 2, VersionField: "rev", KeyspaceNotifications: true},
+		},
+		{
+			path:     ":pass@redis:6380/app?versionKey=app:version",
+			key:      "app",
+			expected: Config{Address: "redis:6380", Password: "pass", VersionKey: "app:version"},
+		},
+		{
+			path:          "localhost:6379/0/",
+			expectedError: missingKeyError,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, err := NewResource(test.path)
+			if err != test.expectedError {
+				t.Errorf("expected error %v; got %v\n", test.expectedError, err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if res.key != test.key {
+				t.Errorf("expected key %s; got %s\n", test.key, res.key)
+			}
+			test.expected.DialTimeout = defaultDialTimeout
+			test.expected.RetryInterval = defaultRetryInterval
+			if res.cfg != test.expected {
+				t.Errorf("expected config %+v; got %+v\n", test.expected, res.cfg)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Poll reads the version of the key and compares it with the last Poll. The version is the value of the version
+// key or field if one is configured, or a digest of the content otherwise.
+func (r *Resource) Poll(ctx context.Context) (bool, error) {
+	c, err := dial(ctx, r.cfg)
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+
+	version, err := r.readVersion(ctx, c)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := !r.loaded || version != r.version
+	r.loaded = true
+	r.version = version
+	return changed, nil
+}
+
+// Refresh provides a reader for the value of the key. A string is provided as-is, and a hash as a JSON object
+// of its fields.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	c, err := dial(ctx, r.cfg)
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+	defer c.Close()
+
+	data, err := r.readData(ctx, c)
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+	updateFunc(bytes.NewReader(data))
+}
+
+// Notify subscribes to the keyspace channel of the key (and of the version key, if there is one) and sends on
+// the returned channel when a notification changes the version. The version is read again every time the
+// subscription is made, so a change while it was down is not missed.
+func (r *NotifyingResource) Notify(ctx context.Context, errorHandler func(error)) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	first := true
+	check := func() {
+		changed, err := r.Poll(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				errorHandler(err)
+			}
+			return
+		}
+		if changed || first {
+			first = false
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+
+	go func() {
+		defer close(ch)
+		for ctx.Err() == nil {
+			if err := r.subscribe(ctx, check); err != nil && ctx.Err() == nil {
+				errorHandler(err)
+				select {
+				case <-time.After(r.cfg.RetryInterval):
+				case <-ctx.Done():
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// subscribe calls check once subscribed, and for every notification after, until the connection fails or the
+// context is done.
+func (r *NotifyingResource) subscribe(ctx context.Context, check func()) error {
+	c, err := dial(ctx, r.cfg)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+
+	if r.cfg.ConfigureNotifications {
+		if _, err := c.do(ctx, "CONFIG", "SET", "notify-keyspace-events", "KA"); err != nil {
+			return err
+		}
+	}
+	keys := []string{r.key}
+	if r.cfg.VersionKey != "" {
+		keys = append(keys, r.cfg.VersionKey)
+	}
+	for _, k := range keys {
+		if err := c.subscribe(ctx, "__keyspace@"+strconv.Itoa(r.cfg.DB)+"__:"+k); err != nil {
+			return err
+		}
+	}
+
+	check()
+	for {
+		if _, err := c.message(); err != nil {
+			return err
+		}
+		check()
+	}
+}
+
+func (r *Resource) readVersion(ctx context.Context, c *client) (string, error) {
+	if r.cfg.VersionKey != "" {
+		reply, err := c.do(ctx, "GET", r.cfg.VersionKey)
+		return toString(reply), err
+	}
+	if r.cfg.VersionField != "" {
+		reply, err := c.do(ctx, "HGET", r.key, r.cfg.VersionField)
+		return toString(reply), err
+	}
+
+	data, err := r.readData(ctx, c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (r *Resource) readData(ctx context.Context, c *client) ([]byte, error) {
+	reply, err := c.do(ctx, "TYPE", r.key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch toString(reply) {
+	case "none":
+		return nil, KeyNotFoundError
+	case "string":
+		reply, err := c.do(ctx, "GET", r.key)
+		if err != nil {
+			return nil, err
+		}
+		if reply == nil {
+			return nil, KeyNotFoundError
+		}
+		return reply.([]byte), nil
+	case "hash":
+		reply, err := c.do(ctx, "HGETALL", r.key)
+		if err != nil {
+			return nil, err
+		}
+		values, _ := reply.([]interface{})
+		if len(values) == 0 {
+			return nil, KeyNotFoundError
+		}
+		fields := map[string]string{}
+		for i := 0; i+1 < len(values); i += 2 {
+			fields[toString(values[i])] = toString(values[i+1])
+		}
+		return json.Marshal(fields)
+	default:
+		return nil, UnsupportedTypeError
+	}
+}
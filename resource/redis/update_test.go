@@ -0,0 +1,332 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a minimal in-process Redis server. It supports the commands used by the resource, and publishes
+// keyspace notifications to subscribers when a key is written through set or hset.
+type fakeRedis struct {
+	listener    net.Listener
+	password    string
+	mu          sync.Mutex
+	strings     map[string]string
+	hashes      map[string]map[string]string
+	subscribers map[string][]net.Conn
+	configured  bool
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v\n", err)
+	}
+	f := &fakeRedis{
+		listener:    l,
+		password:    password,
+		strings:     map[string]string{},
+		hashes:      map[string]map[string]string{},
+		subscribers: map[string][]net.Conn{},
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) Close() {
+	f.listener.Close()
+}
+
+func (f *fakeRedis) set(key, value string) {
+	f.mu.Lock()
+	f.strings[key] = value
+	f.mu.Unlock()
+	f.publish(key, "set")
+}
+
+func (f *fakeRedis) hset(key, field, value string) {
+	f.mu.Lock()
+	if f.hashes[key] == nil {
+		f.hashes[key] = map[string]string{}
+	}
+	f.hashes[key][field] = value
+	f.mu.Unlock()
+	f.publish(key, "hset")
+}
+
+func (f *fakeRedis) publish(key, event string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	channel := "__keyspace@0__:" + key
+	for _, c := range f.subscribers[channel] {
+		writeArray(c, "message", channel, event)
+	}
+}
+
+// dropSubscribers closes every subscribed connection, as if the server restarted.
+func (f *fakeRedis) dropSubscribers() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for channel, conns := range f.subscribers {
+		for _, c := range conns {
+			c.Close()
+		}
+		delete(f.subscribers, channel)
+	}
+}
+
+func writeArray(w io.Writer, values ...string) {
+	s := "*" + strconv.Itoa(len(values)) + "\r\n"
+	for _, v := range values {
+		s += "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+	}
+	io.WriteString(w, s)
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		c := &client{conn: conn, r: r}
+		reply, err := c.receive()
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, a := range reply.([]interface{}) {
+			args = append(args, toString(a))
+		}
+
+		cmd := strings.ToUpper(args[0])
+		if !authed && cmd != "AUTH" {
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+
+		f.mu.Lock()
+		switch cmd {
+		case "AUTH":
+			if args[len(args)-1] != f.password {
+				io.WriteString(conn, "-WRONGPASS invalid username-password pair\r\n")
+			} else {
+				authed = true
+				io.WriteString(conn, "+OK\r\n")
+			}
+		case "SELECT":
+			io.WriteString(conn, "+OK\r\n")
+		case "CONFIG":
+			f.configured = true
+			io.WriteString(conn, "+OK\r\n")
+		case "TYPE":
+			kind := "none"
+			if _, ok := f.strings[args[1]]; ok {
+				kind = "string"
+			} else if _, ok := f.hashes[args[1]]; ok {
+				kind = "hash"
+			}
+			io.WriteString(conn, "+"+kind+"\r\n")
+		case "GET":
+			if v, ok := f.strings[args[1]]; ok {
+				io.WriteString(conn, "$"+strconv.Itoa(len(v))+"\r\n"+v+"\r\n")
+			} else {
+				io.WriteString(conn, "$-1\r\n")
+			}
+		case "HGET":
+			if v, ok := f.hashes[args[1]][args[2]]; ok {
+				io.WriteString(conn, "$"+strconv.Itoa(len(v))+"\r\n"+v+"\r\n")
+			} else {
+				io.WriteString(conn, "$-1\r\n")
+			}
+		case "HGETALL":
+			var fields []string
+			for k := range f.hashes[args[1]] {
+				fields = append(fields, k)
+			}
+			sort.Strings(fields)
+			var values []string
+			for _, k := range fields {
+				values = append(values, k, f.hashes[args[1]][k])
+			}
+			writeArray(conn, values...)
+		case "SUBSCRIBE":
+			f.subscribers[args[1]] = append(f.subscribers[args[1]], conn)
+			io.WriteString(conn, "*3\r\n$9\r\nsubscribe\r\n$"+strconv.Itoa(len(args[1]))+"\r\n"+args[1]+"\r\n:1\r\n")
+		default:
+			io.WriteString(conn, "-ERR unknown command\r\n")
+		}
+		f.mu.Unlock()
+	}
+}
+
+func readAll(t *testing.T, res *Resource) string {
+	value := ""
+	res.Refresh(context.Background(),
+		func(r io.Reader) {
+			b, _ := ioutil.ReadAll(r)
+			value = string(b)
+		}, func(e error) {
+			t.Errorf("error during refresh: %v\n", e)
+		})
+	return value
+}
+
+func TestResource_Poll(t *testing.T) {
+	tests := []struct {
+		cfg    Config
+		writes []func(f *fakeRedis)
+	}{
+		{
+			cfg: Config{},
+			writes: []func(f *fakeRedis){
+				func(f *fakeRedis) { f.set("app", "v2") },
+			},
+		},
+		{
+			cfg: Config{VersionField: "rev"},
+			writes: []func(f *fakeRedis){
+				func(f *fakeRedis) { f.hset("app", "rev", "2") },
+			},
+		},
+		{
+			cfg: Config{VersionKey: "app:version"},
+			writes: []func(f *fakeRedis){
+				func(f *fakeRedis) { f.set("app:version", "2") },
+			},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			f := newFakeRedis(t, "secret")
+			defer f.Close()
+			if test.cfg.VersionField != "" {
+				f.hset("app", "rev", "1")
+			} else {
+				f.set("app", "v1")
+			}
+			f.set("app:version", "1")
+
+			test.cfg.Address = f.listener.Addr().String()
+			test.cfg.Password = "secret"
+			res, _ := NewResourceWithConfig("app", test.cfg)
+
+			for j, expected := range []bool{true, false} {
+				if updated, err := res.Poll(context.Background()); updated != expected || err != nil {
+					t.Errorf("poll %d: expected %v; got %v, %v\n", j, expected, updated, err)
+				}
+			}
+			for _, w := range test.writes {
+				w(f)
+			}
+			if updated, err := res.Poll(context.Background()); !updated || err != nil {
+				t.Errorf("expected update after write; got %v, %v\n", updated, err)
+			}
+		})
+	}
+}
+
+func TestResource_Refresh(t *testing.T) {
+	f := newFakeRedis(t, "")
+	defer f.Close()
+	f.set("plain", "hello")
+	f.hset("hash", "b", "2")
+	f.hset("hash", "a", "1")
+
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{key: "plain", expected: "hello"},
+		{key: "hash", expected: `{"a":"1","b":"2"}`},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, _ := NewResourceWithConfig(test.key, Config{Address: f.listener.Addr().String()})
+			if v := readAll(t, res); v != test.expected {
+				t.Errorf("expected %s; got %s\n", test.expected, v)
+			}
+		})
+	}
+
+	res, _ := NewResourceWithConfig("missing", Config{Address: f.listener.Addr().String()})
+	var err error
+	res.Refresh(context.Background(), func(r io.Reader) {
+		t.Errorf("expected no update call, but got one\n")
+	}, func(e error) {
+		err = e
+	})
+	if err != KeyNotFoundError {
+		t.Errorf("expected KeyNotFoundError; got %v\n", err)
+	}
+}
+
+func expectNotification(t *testing.T, ch <-chan struct{}) {
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Errorf("timed out waiting for a notification\n")
+	}
+}
+
+func TestNotifyingResource_Notify(t *testing.T) {
+	f := newFakeRedis(t, "")
+	defer f.Close()
+	f.set("app", "v1")
+
+	res, _ := NewResource(f.listener.Addr().String() + "/0/app?notify")
+	if !res.UsesKeyspaceNotifications() {
+		t.Errorf("expected keyspace notifications to be configured\n")
+	}
+	res.cfg.RetryInterval = 10 * time.Millisecond
+	res.cfg.ConfigureNotifications = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := res.Notifier().Notify(ctx, func(e error) {})
+
+	expectNotification(t, ch)
+	if v := readAll(t, res); v != "v1" {
+		t.Errorf("expected v1; got %s\n", v)
+	}
+	f.mu.Lock()
+	configured := f.configured
+	f.mu.Unlock()
+	if !configured {
+		t.Errorf("expected keyspace notifications to be configured on the server\n")
+	}
+
+	f.set("app", "v2")
+	expectNotification(t, ch)
+	if v := readAll(t, res); v != "v2" {
+		t.Errorf("expected v2; got %s\n", v)
+	}
+
+	// A write while the subscription is down is noticed once it is made again.
+	f.dropSubscribers()
+	f.mu.Lock()
+	f.strings["app"] = "v3"
+	f.mu.Unlock()
+	expectNotification(t, ch)
+	if v := readAll(t, res); v != "v3" {
+		t.Errorf("expected v3; got %s\n", v)
+	}
+}
@@ -2,18 +2,42 @@
 // and input string. This is done via the scheme (the first part of the path provided).
 // Schemes:
 // - "gs://" will create a GCS resource
+// - "s3://" will create an S3 resource (see the s3 package for S3-compatible stores)
+// - "azblob://" or "https://<account>.blob.core.windows.net/" will create an Azure blob resource
+// - "consul://host:port/kv/<key>" will create a Consul KV resource
+// - "etcd://host:port[,host:port...]/<key>" will create an etcd v3 resource, which is a Notifier
+// - "zk://host:port[,host:port...]/<znode>" will create a ZooKeeper resource, which is a Notifier
+// - "redis://host:port/<db>/<key>" or "rediss://" will create a Redis resource, which is a Notifier with "?notify"
+// - "git://", "git+https://", "git+ssh://" or "git+file://" will create a git resource tracking a ref
+// - "exec://<command line>" will create a resource from the output of a command
+// - "env://NAME" or "env:///path/to/file.env[#NAME]" will create an environment variable resource
 // - "file://" or "." or "/" or "\" (windows) will create a local file resource
 // - "tcp://" or "http://" or "https://" or "ftp://" will create a network resource
 //
+// The mem package provides a resource whose content is set programmatically, for tests. The composite package
+// merges several resources into one layered JSON or YAML document, and the failover package reads from the first
+// available of an ordered list of resources. The cache package keeps the last known good data of any resource on
+// disk, the decompress and decrypt packages decompress and decrypt the data of any resource, and the verify package
+// checks the data of any resource against an expected checksum or signature before it is used.
+//
 // Custom resources can be defined by implementing the Resource interface defined in this package.
-// For example, you could define one for a zookeeper resource which sets a ZK watch.
+// Resources which can push changes, like the etcd and zookeeper ones, should also implement Notifier.
 package resource
 
 import (
 	"context"
 	"errors"
+	"github.com/fire00f1y/go-sprout/resource/azblob"
+	"github.com/fire00f1y/go-sprout/resource/consul"
+	"github.com/fire00f1y/go-sprout/resource/env"
+	"github.com/fire00f1y/go-sprout/resource/etcd"
+	"github.com/fire00f1y/go-sprout/resource/exec"
 	"github.com/fire00f1y/go-sprout/resource/file"
 	"github.com/fire00f1y/go-sprout/resource/gcs"
+	"github.com/fire00f1y/go-sprout/resource/git"
+	"github.com/fire00f1y/go-sprout/resource/redis"
+	"github.com/fire00f1y/go-sprout/resource/s3"
+	"github.com/fire00f1y/go-sprout/resource/zookeeper"
 	"io"
 	"os"
 	"strings"
@@ -43,6 +67,14 @@ type Refresher interface {
 	Refresh(context.Context, func(io.Reader), func(error))
 }
 
+// Notifier is implemented by resources which can push a notification when the data source changes, rather than
+// having to be polled. The returned channel receives a value once the resource is first available and again each
+// time it changes, and is closed when the context is done. Errors encountered while watching are given to the
+// error handler, and the notifier is expected to recover from them on its own.
+type Notifier interface {
+	Notify(context.Context, func(error)) <-chan struct{}
+}
+
 func CreateResource(path string) (Resource, error) {
 	s, p, e := getscheme(path)
 	if e != nil {
@@ -51,9 +83,63 @@ func CreateResource(path string) (Resource, error) {
 	switch s {
 	case "gs":
 		{
-			i := strings.Index(p, "/")
 			return gcs.NewResource(path)
 		}
+	case "s3":
+		{
+			return s3.NewResource(p)
+		}
+	case "azblob":
+		{
+			return azblob.NewResource(p)
+		}
+	case "https":
+		{
+			if isAzureBlobURL(p) {
+				return azblob.NewResourceFromURL(p)
+			}
+			return nil, UnknownTypeError
+		}
+	case "consul":
+		{
+			return consul.NewResource(p)
+		}
+	case "etcd":
+		{
+			return etcd.NewResource(p)
+		}
+	case "zk":
+		{
+			return zookeeper.NewResource(p)
+		}
+	case "redis", "rediss":
+		{
+			newRedis := redis.NewResource
+			if s == "rediss" {
+				newRedis = redis.NewTLSResource
+			}
+			r, err := newRedis(p)
+			if err != nil {
+				return nil, err
+			}
+			if r.UsesKeyspaceNotifications() {
+				return r.Notifier(), nil
+			}
+			return r, nil
+		}
+	case "git", "git+file", "git+http", "git+https", "git+ssh":
+		{
+			return git.NewResource(path)
+		}
+	case "exec":
+		{
+			// The command keeps its leading slash, so an absolute path is not made relative.
+			return exec.NewResource(strings.TrimPrefix(path[len(s)+1:], "//"))
+		}
+	case "env":
+		{
+			return env.NewResource(strings.TrimPrefix(path[len(s)+1:], "//"))
+		}
 	case "file":
 		{
 			return file.NewResource(p)
@@ -93,6 +179,14 @@ func getscheme(rawurl string) (scheme, path string, err error) {
 	return "", stripPrecedingSlashes(rawurl), nil
 }
 
+func isAzureBlobURL(p string) bool {
+	host := p
+	if i := strings.Index(p, "/"); i >= 0 {
+		host = p[:i]
+	}
+	return strings.HasSuffix(host, azblob.BlobHostSuffix)
+}
+
 func stripPrecedingSlashes(s string) string {
 	if strings.HasPrefix(s, "/") {
 		return stripPrecedingSlashes(strings.TrimPrefix(s, "/"))
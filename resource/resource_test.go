@@ -1,10 +1,21 @@
 package resource
 
 import (
+	"github.com/fire00f1y/go-sprout/resource/azblob"
+	"github.com/fire00f1y/go-sprout/resource/consul"
+	"github.com/fire00f1y/go-sprout/resource/env"
+	"github.com/fire00f1y/go-sprout/resource/etcd"
+	"github.com/fire00f1y/go-sprout/resource/exec"
 	"github.com/fire00f1y/go-sprout/resource/file"
 	"github.com/fire00f1y/go-sprout/resource/gcs"
+	"github.com/fire00f1y/go-sprout/resource/git"
+	"github.com/fire00f1y/go-sprout/resource/redis"
+	"github.com/fire00f1y/go-sprout/resource/s3"
+	"github.com/fire00f1y/go-sprout/resource/zookeeper"
+	"io"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -24,6 +35,61 @@ func TestCreateResource(t *testing.T) {
 			typeStruct:    gcs.Resource{},
 			expectedError: nil,
 		},
+		{
+			path:          "s3://aws-bucket/object",
+			typeStruct:    &s3.Resource{},
+			expectedError: nil,
+		},
+		{
+			path:          "https://account.blob.core.windows.net/container/blob",
+			typeStruct:    &azblob.Resource{},
+			expectedError: nil,
+		},
+		{
+			path:          "https://www.google.com/object",
+			typeStruct:    nil,
+			expectedError: UnknownTypeError,
+		},
+		{
+			path:          "consul://localhost:8500/kv/config/app",
+			typeStruct:    &consul.Resource{},
+			expectedError: nil,
+		},
+		{
+			path:          "etcd://localhost:2379/config/app",
+			typeStruct:    &etcd.Resource{},
+			expectedError: nil,
+		},
+		{
+			path:          "zk://localhost:2181/config/app",
+			typeStruct:    &zookeeper.Resource{},
+			expectedError: nil,
+		},
+		{
+			path:          "redis://localhost:6379/0/config",
+			typeStruct:    &redis.Resource{},
+			expectedError: nil,
+		},
+		{
+			path:          "redis://localhost:6379/0/config?notify",
+			typeStruct:    &redis.NotifyingResource{},
+			expectedError: nil,
+		},
+		{
+			path:          "git+file:///srv/config.git//app.json?ref=main",
+			typeStruct:    &git.Resource{},
+			expectedError: nil,
+		},
+		{
+			path:          "exec:///usr/bin/vault kv get secret/app",
+			typeStruct:    &exec.Resource{},
+			expectedError: nil,
+		},
+		{
+			path:          "env:///etc/app.env#DATABASE_URL",
+			typeStruct:    &env.Resource{},
+			expectedError: nil,
+		},
 		{
 			path:          "file://google-bucket/object",
 			typeStruct:    file.Resource{},
@@ -110,3 +176,30 @@ func TestGetScheme(t *testing.T) {
 		})
 	}
 }
+
+func TestMetadataOf(t *testing.T) {
+	tests := []struct {
+		reader   io.Reader
+		expected Metadata
+		ok       bool
+	}{
+		{
+			reader:   WithMetadata(strings.NewReader("{}"), Metadata{ContentType: "application/json", Size: 2, Version: "3"}),
+			expected: Metadata{ContentType: "application/json", Size: 2, Version: "3"},
+			ok:       true,
+		},
+		{
+			reader:   strings.NewReader("{}"),
+			expected: Metadata{Size: -1},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			m, ok := MetadataOf(test.reader)
+			if ok != test.ok || !reflect.DeepEqual(m, test.expected) {
+				t.Errorf("expected %v and %v; got %v and %v\n", test.expected, test.ok, m, ok)
+			}
+		})
+	}
+}
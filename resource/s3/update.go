@@ -0,0 +1,142 @@
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Poll sends a HEAD request for the object and compares the ETag and Last-Modified headers with the values
+// from the last Poll. If versioning is enabled, the x-amz-version-id header is compared as well. The
+// Content-Type is stored so it can be referenced when deciding how to use the reader provided.
+func (r *Resource) Poll(ctx context.Context) (bool, error) {
+	resp, err := r.do(ctx, http.MethodHead, "")
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, responseError(http.MethodHead, resp)
+	}
+
+	etag := resp.Header.Get("ETag")
+	modified := resp.Header.Get("Last-Modified")
+	version := resp.Header.Get("x-amz-version-id")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := etag != r.lastETag || modified != r.lastModified
+	if r.versioned && version != r.lastVersion {
+		changed = true
+	}
+	r.lastETag = etag
+	r.lastModified = modified
+	r.lastVersion = version
+	r.contentType = resp.Header.Get("Content-Type")
+	return changed, nil
+}
+
+// Refresh provides a reader for the object body. If versioning is enabled, the version seen by the last Poll
+// is requested so the data always matches what was detected. The body is closed after the update func returns.
+// The reader carries the object's metadata, with the version id as the version, or the ETag without versioning.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	r.mu.Lock()
+	version := ""
+	if r.versioned {
+		version = r.lastVersion
+	}
+	r.mu.Unlock()
+
+	resp, err := r.do(ctx, http.MethodGet, version)
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+	defer func() {
+		if e := resp.Body.Close(); e != nil {
+			errorHandler(e)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		errorHandler(responseError(http.MethodGet, resp))
+		return
+	}
+
+	v := resp.Header.Get("x-amz-version-id")
+	if v == "" {
+		v = resp.Header.Get("ETag")
+	}
+	md := meta.Metadata{
+		Name:        r.key,
+		ContentType: resp.Header.Get("Content-Type"),
+		Encoding:    resp.Header.Get("Content-Encoding"),
+		Size:        resp.ContentLength,
+		Version:     v,
+		Attributes:  meta.AttributesFromHeader(resp.Header, "x-amz-meta-"),
+	}
+	// The transport gunzips an object stored with the gzip encoding, and the checksums are of the stored bytes.
+	if !resp.Uncompressed {
+		md.Checksums = meta.ChecksumsFromHeader(resp.Header)
+	}
+	updateFunc(meta.NewReader(resp.Body, md))
+}
+
+func (r *Resource) do(ctx context.Context, method, version string) (*http.Response, error) {
+	req, err := http.NewRequest(method, r.objectURL(version).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if method == http.MethodGet {
+		// Ask for the additional checksums of the object, if it was uploaded with any.
+		req.Header.Set("x-amz-checksum-mode", "ENABLED")
+	}
+	if r.creds.AccessKeyID != "" {
+		sign(req, r.creds, r.region, time.Now())
+	}
+	return r.client.Do(req)
+}
+
+func (r *Resource) objectURL(version string) *url.URL {
+	u := *r.endpoint
+	path := "/" + r.key
+	if r.pathStyle {
+		path = "/" + r.bucket + path
+	} else {
+		u.Host = r.bucket + "." + u.Host
+	}
+	u.Path = trimTrailingSlash(u.Path) + path
+	u.RawPath = escape(u.Path, false)
+	if version != "" {
+		u.RawQuery = url.Values{"versionId": {version}}.Encode()
+	}
+	return &u
+}
+
+func trimTrailingSlash(s string) string {
+	for len(s) > 0 && s[len(s)-1] == '/' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// errorResponse is the XML body S3 returns alongside a failed request.
+type errorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func responseError(method string, resp *http.Response) error {
+	var e errorResponse
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if xml.Unmarshal(b, &e) == nil && e.Code != "" {
+		return fmt.Errorf("[gosprout] s3 %s failed with %s: %s: %s", method, resp.Status, e.Code, e.Message)
+	}
+	return fmt.Errorf("[gosprout] s3 %s failed with %s", method, resp.Status)
+}
@@ -0,0 +1,233 @@
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is a minimal in-process S3 server. It serves HEAD and GET for objects addressed path-style and
+// keeps every version of an object, so versioned reads can be checked.
+type fakeS3 struct {
+	mu       sync.Mutex
+	versions map[string][]string
+	requests []*http.Request
+}
+
+func newFakeS3() (*fakeS3, *httptest.Server) {
+	f := &fakeS3{versions: map[string][]string{}}
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeS3) put(path, data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.versions[path] = append(f.versions[path], data)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+
+	versions := f.versions[req.URL.Path]
+	if len(versions) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+		return
+	}
+	v := len(versions) - 1
+	if id := req.URL.Query().Get("versionId"); id != "" {
+		v, _ = strconv.Atoi(id)
+	}
+	data := versions[v]
+	sum := md5.Sum([]byte(data))
+
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.Header().Set("Last-Modified", time.Unix(1580000000, 0).UTC().Format(http.TimeFormat))
+	w.Header().Set("x-amz-version-id", strconv.Itoa(v))
+	w.Header().Set("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		io.WriteString(w, data)
+	}
+}
+
+func TestResource_Poll(t *testing.T) {
+	fake, server := newFakeS3()
+	defer server.Close()
+	fake.put("/bucket/config.json", `{"a":1}`)
+
+	res, err := NewResourceWithConfig("bucket/config.json", Config{Endpoint: server.URL})
+	if err != nil {
+		t.Errorf("error creating new resource: %v\n", err)
+		return
+	}
+
+	tests := []struct {
+		put     string
+		updated bool
+	}{
+		{put: "", updated: true},
+		{put: "", updated: false},
+		{put: `{"a":2}`, updated: true},
+		{put: "", updated: false},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if test.put != "" {
+				fake.put("/bucket/config.json", test.put)
+			}
+			updated, err := res.Poll(context.Background())
+			if err != nil {
+				t.Errorf("error polling: %v\n", err)
+			}
+			if updated != test.updated {
+				t.Errorf("expected updated to be %v; got %v\n", test.updated, updated)
+			}
+		})
+	}
+
+	if ct := res.ContentType(); ct != "application/json" {
+		t.Errorf("expected content type application/json; got %s\n", ct)
+	}
+}
+
+func TestResource_PollMissing(t *testing.T) {
+	_, server := newFakeS3()
+	defer server.Close()
+
+	res, _ := NewResourceWithConfig("bucket/missing", Config{Endpoint: server.URL})
+	updated, err := res.Poll(context.Background())
+	if updated || err == nil {
+		t.Errorf("expected error and no update; got %v, %v\n", updated, err)
+	}
+}
+
+func TestResource_Refresh(t *testing.T) {
+	tests := []struct {
+		versioned bool
+		expected  string
+	}{
+		{versioned: false, expected: "second"},
+		{versioned: true, expected: "first"},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			fake, server := newFakeS3()
+			defer server.Close()
+			fake.put("/bucket/key", "first")
+
+			res, _ := NewResourceWithConfig("bucket/key", Config{
+				Endpoint:      server.URL,
+				UseVersioning: test.versioned,
+				Credentials:   Credentials{AccessKeyID: "minio", SecretAccessKey: "minio123"},
+			})
+			if _, err := res.Poll(context.Background()); err != nil {
+				t.Errorf("error polling: %v\n", err)
+				return
+			}
+			// A write after the poll should only be visible when versioning is not in use.
+			fake.put("/bucket/key", "second")
+
+			value := ""
+			res.Refresh(context.Background(),
+				func(r io.Reader) {
+					b, _ := ioutil.ReadAll(r)
+					value = string(b)
+				}, func(e error) {
+					t.Errorf("error during refresh: %v\n", e)
+				})
+			if value != test.expected {
+				t.Errorf("expected %s; got %s\n", test.expected, value)
+			}
+
+			last := fake.requests[len(fake.requests)-1]
+			if !strings.HasPrefix(last.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+				t.Errorf("request was not signed: %s\n", last.Header.Get("Authorization"))
+			}
+		})
+	}
+}
+
+func TestResource_RefreshError(t *testing.T) {
+	_, server := newFakeS3()
+	defer server.Close()
+
+	res, _ := NewResourceWithConfig("bucket/missing", Config{Endpoint: server.URL})
+	var err error
+	res.Refresh(context.Background(),
+		func(r io.Reader) {
+			t.Errorf("expected no update call, but got one\n")
+		}, func(e error) {
+			err = e
+		})
+	if err == nil || !strings.Contains(err.Error(), "NoSuchKey") {
+		t.Errorf("expected NoSuchKey error; got %v\n", err)
+	}
+}
+
+func TestResource_RefreshGzip(t *testing.T) {
+	const data = `{"a":1}`
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	io.WriteString(w, data)
+	w.Close()
+
+	tests := []struct {
+		body      []byte
+		encoding  string
+		checksums bool
+	}{
+		{body: []byte(data), checksums: true},
+		{body: gz.Bytes(), encoding: "gzip", checksums: false},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			sum := md5.Sum(test.body)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("ETag", `"1"`)
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+				if test.encoding != "" {
+					w.Header().Set("Content-Encoding", test.encoding)
+				}
+				w.Write(test.body)
+			}))
+			defer server.Close()
+			res, _ := NewResourceWithConfig("bucket/key", Config{Endpoint: server.URL})
+
+			value := ""
+			var m meta.Metadata
+			res.Refresh(context.Background(),
+				func(r io.Reader) {
+					m, _ = meta.Of(r)
+					b, _ := ioutil.ReadAll(r)
+					value = string(b)
+				}, func(e error) {
+					t.Errorf("error during refresh: %v\n", e)
+				})
+			if value != data {
+				t.Errorf("expected %s; got %s\n", data, value)
+			}
+			if _, ok := m.Checksums["md5"]; ok != test.checksums {
+				t.Errorf("expected checksums %v; got %v\n", test.checksums, m.Checksums)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// The sql package implements a resource backed by a database/sql database. A cheap version query, such as
+// "SELECT max(updated_at) FROM prices", is used to detect a change, and the rows of a data query are streamed to
+// the update func as JSON lines or CSV.
+//
+// There is no scheme for this resource, since the DSN format depends on the driver. Create it with NewResource
+// or NewResourceFromDB, with the driver of your database imported.
+package sql
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+var (
+	missingVersionQueryError = errors.New("[gosprout] sql resource is missing a version query")
+	missingDataQueryError    = errors.New("[gosprout] sql resource is missing a data query")
+)
+
+// Format is how the rows of the data query are written for the update func.
+type Format int
+
+const (
+	// JSONLines writes one JSON object per row, mapping each column name to its value.
+	JSONLines Format = iota
+	// CSV writes a header with the column names followed by one record per row.
+	CSV
+)
+
+// Config holds the queries of a Resource.
+type Config struct {
+	// VersionQuery must return a single row with a single column. Its value is compared between polls.
+	VersionQuery string
+	VersionArgs  []interface{}
+	DataQuery    string
+	DataArgs     []interface{}
+	Format       Format
+}
+
+// Resource is the result of a query on a database. Poll runs the version query and Refresh runs the data query.
+type Resource struct {
+	db  *sql.DB
+	cfg Config
+
+	mu      *sync.Mutex
+	loaded  bool
+	version string
+}
+
+// NewResource opens the database with the driver and DSN, and creates a resource for the configured queries.
+func NewResource(driverName, dsn string, cfg Config) (*Resource, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewResourceFromDB(db, cfg)
+	if err != nil {
+		db.Close()
+	}
+	return r, err
+}
+
+// NewResourceFromDB creates a resource for the configured queries on an already opened database.
+func NewResourceFromDB(db *sql.DB, cfg Config) (*Resource, error) {
+	if cfg.VersionQuery == "" {
+		return nil, missingVersionQueryError
+	}
+	if cfg.DataQuery == "" {
+		return nil, missingDataQueryError
+	}
+
+	return &Resource{
+		db:  db,
+		cfg: cfg,
+		mu:  &sync.Mutex{},
+	}, nil
+}
+
+// DB returns the database of the resource, so it can be closed when no longer needed.
+func (r *Resource) DB() *sql.DB {
+	return r.db
+}
@@ -0,0 +1,129 @@
+package sql
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Poll runs the version query and compares its result with the last Poll.
+func (r *Resource) Poll(ctx context.Context) (bool, error) {
+	var v interface{}
+	if err := r.db.QueryRowContext(ctx, r.cfg.VersionQuery, r.cfg.VersionArgs...).Scan(&v); err != nil {
+		return false, err
+	}
+	version := formatValue(v)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := !r.loaded || version != r.version
+	r.loaded = true
+	r.version = version
+	return changed, nil
+}
+
+// Refresh runs the data query and provides a reader which streams the rows in the configured format as they
+// are read from the database. If the query fails part way, the reader returns the error and it is also given
+// to the error handler.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	rows, err := r.db.QueryContext(ctx, r.cfg.DataQuery, r.cfg.DataArgs...)
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := r.writeRows(pw, rows, columns)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	updateFunc(pr)
+	// Unblock the writer if the update func did not read everything.
+	pr.CloseWithError(io.ErrClosedPipe)
+	if err := <-done; err != nil && err != io.ErrClosedPipe {
+		errorHandler(err)
+	}
+}
+
+type rowScanner interface {
+	Next() bool
+	Scan(...interface{}) error
+	Err() error
+}
+
+func (r *Resource) writeRows(w io.Writer, rows rowScanner, columns []string) error {
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	var write func() error
+	switch r.cfg.Format {
+	case CSV:
+		cw := csv.NewWriter(w)
+		cw.Write(columns)
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+		record := make([]string, len(columns))
+		write = func() error {
+			for i, v := range values {
+				record[i] = formatValue(v)
+			}
+			cw.Write(record)
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		encoder := json.NewEncoder(w)
+		write = func() error {
+			row := make(map[string]interface{}, len(columns))
+			for i, c := range columns {
+				if b, ok := values[i].([]byte); ok {
+					row[c] = string(b)
+				} else {
+					row[c] = values[i]
+				}
+			}
+			return encoder.Encode(row)
+		}
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		if err := write(); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// formatValue renders a scanned value as text, for versions and CSV records.
+func formatValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(t)
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(t)
+	}
+}
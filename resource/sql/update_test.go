@@ -0,0 +1,232 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDriver is a database/sql driver which answers queries from a table of canned results. The results can be
+// changed between queries to simulate writes.
+type fakeDriver struct {
+	mu      sync.Mutex
+	results map[string]fakeResult
+}
+
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+	err     error
+}
+
+var testDriver = &fakeDriver{results: map[string]fakeResult{}}
+
+func init() {
+	sql.Register("gosprout-fake", testDriver)
+}
+
+func (d *fakeDriver) set(query string, result fakeResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.results[query] = result
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d: d}, nil
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("exec is not supported")
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.d.mu.Lock()
+	defer s.c.d.mu.Unlock()
+	result, ok := s.c.d.results[s.query]
+	if !ok {
+		return nil, errors.New("unknown query: " + s.query)
+	}
+	return &fakeRows{result: result}, nil
+}
+
+type fakeRows struct {
+	result fakeResult
+	i      int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.result.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.result.rows) {
+		if r.result.err != nil {
+			return r.result.err
+		}
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.i])
+	r.i++
+	return nil
+}
+
+const (
+	versionQuery = "SELECT max(updated_at) FROM prices"
+	dataQuery    = "SELECT sku, price, note FROM prices"
+)
+
+func TestResource_Poll(t *testing.T) {
+	res, err := NewResource("gosprout-fake", "", Config{VersionQuery: versionQuery, DataQuery: dataQuery})
+	if err != nil {
+		t.Errorf("error creating resource: %v\n", err)
+		return
+	}
+	defer res.DB().Close()
+
+	tests := []struct {
+		version interface{}
+		updated bool
+	}{
+		{version: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), updated: true},
+		{version: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), updated: false},
+		{version: time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC), updated: true},
+		{version: []byte("2020-03-02"), updated: true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			testDriver.set(versionQuery, fakeResult{columns: []string{"max"}, rows: [][]driver.Value{{test.version}}})
+			updated, err := res.Poll(context.Background())
+			if err != nil {
+				t.Errorf("error polling: %v\n", err)
+			}
+			if updated != test.updated {
+				t.Errorf("expected updated to be %v; got %v\n", test.updated, updated)
+			}
+		})
+	}
+}
+
+func TestResource_Refresh(t *testing.T) {
+	testDriver.set(dataQuery, fakeResult{
+		columns: []string{"sku", "price", "note"},
+		rows: [][]driver.Value{
+			{[]byte("a-1"), 1.5, nil},
+			{[]byte("b,2"), int64(3), "two words"},
+		},
+	})
+
+	tests := []struct {
+		format   Format
+		expected string
+	}{
+		{
+			format:   JSONLines,
+			expected: "{\"note\":null,\"price\":1.5,\"sku\":\"a-1\"}\n{\"note\":\"two words\",\"price\":3,\"sku\":\"b,2\"}\n",
+		},
+		{
+			format:   CSV,
+			expected: "sku,price,note\na-1,1.5,\n\"b,2\",3,two words\n",
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, _ := NewResource("gosprout-fake", "", Config{VersionQuery: versionQuery, DataQuery: dataQuery, Format: test.format})
+			defer res.DB().Close()
+
+			value := ""
+			res.Refresh(context.Background(),
+				func(r io.Reader) {
+					b, _ := ioutil.ReadAll(r)
+					value = string(b)
+				}, func(e error) {
+					t.Errorf("error during refresh: %v\n", e)
+				})
+			if value != test.expected {
+				t.Errorf("expected %q; got %q\n", test.expected, value)
+			}
+		})
+	}
+}
+
+func TestResource_RefreshError(t *testing.T) {
+	rowsError := errors.New("connection reset")
+	testDriver.set(dataQuery, fakeResult{
+		columns: []string{"sku"},
+		rows:    [][]driver.Value{{"a"}},
+		err:     rowsError,
+	})
+	res, _ := NewResource("gosprout-fake", "", Config{VersionQuery: versionQuery, DataQuery: dataQuery})
+	defer res.DB().Close()
+
+	var readErr, handled error
+	res.Refresh(context.Background(),
+		func(r io.Reader) {
+			_, readErr = ioutil.ReadAll(r)
+		}, func(e error) {
+			handled = e
+		})
+	if readErr != rowsError || handled != rowsError {
+		t.Errorf("expected the rows error from both the reader and the handler; got %v and %v\n", readErr, handled)
+	}
+}
+
+func TestNewResourceFromDB(t *testing.T) {
+	tests := []struct {
+		cfg           Config
+		expectedError error
+	}{
+		{cfg: Config{VersionQuery: versionQuery, DataQuery: dataQuery}, expectedError: nil},
+		{cfg: Config{DataQuery: dataQuery}, expectedError: missingVersionQueryError},
+		{cfg: Config{VersionQuery: versionQuery}, expectedError: missingDataQueryError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if _, err := NewResourceFromDB(nil, test.cfg); err != test.expectedError {
+				t.Errorf("expected error %v; got %v\n", test.expectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/fire00f1y/go-sprout/resource"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+var (
+	defaultMaxSize int64 = 100 << 20
+)
+
+// Config is where the expected checksum comes from. The first which is set is used: Expected, then Sidecar, then
+// the checksums in the metadata of the reader.
+type Config struct {
+	// Expected is a fixed checksum, see ParseChecksum.
+	Expected string
+	// Sidecar is a resource holding the checksum, e.g. the "config.json.sha256" next to "config.json". It is read
+	// on every Refresh, and a change to it is reported by Poll too.
+	Sidecar resource.Resource
+	// AllowUnverified lets data without any expected checksum through, instead of failing with
+	// MissingChecksumError.
+	AllowUnverified bool
+	// MaxSize is the maximum size of the data, which is held in memory to be verified before the update func
+	// runs. It defaults to 100 MiB, and a negative value means there is no limit.
+	MaxSize int64
+}
+
+// Resource wraps another resource and verifies the data of every Refresh.
+type Resource struct {
+	resource.Resource
+	cfg   Config
+	polls sidecarPoll
+}
+
+// NewResource wraps the resource so the update func only runs for data which matches its checksum. A malformed
+// Expected checksum is returned as an error.
+func NewResource(r resource.Resource, cfg Config) (*Resource, error) {
+	if cfg.Expected != "" {
+		if _, err := ParseChecksum(cfg.Expected, "config"); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = defaultMaxSize
+	}
+	return &Resource{Resource: r, cfg: cfg}, nil
+}
+
+// Poll polls the resource, and the sidecar if there is one, and reports an update if either changed.
+func (r *Resource) Poll(ctx context.Context) (bool, error) {
+	return r.polls.poll(ctx, r.Resource, r.cfg.Sidecar, "checksum")
+}
+
+// Refresh reads the data, verifies it and only then provides it to the update func. A mismatch is given to the
+// error handler as an *IntegrityError, and the update func is not called.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	data, m, ok := refreshBuffered(ctx, r.Resource, r.cfg.MaxSize, errorHandler)
+	if !ok {
+		return
+	}
+
+	checksums, err := r.expected(ctx, m)
+	if err != nil {
+		errorHandler(err)
+		return
+	}
+	if len(checksums) == 0 && !r.cfg.AllowUnverified {
+		errorHandler(MissingChecksumError)
+		return
+	}
+	if err := Check(data, checksums); err != nil {
+		errorHandler(err)
+		return
+	}
+	updateFunc(meta.NewReader(bytes.NewReader(data), m))
+}
+
+// expected returns the checksums to verify the data with.
+func (r *Resource) expected(ctx context.Context, m meta.Metadata) ([]Checksum, error) {
+	if r.cfg.Expected != "" {
+		c, err := ParseChecksum(r.cfg.Expected, "config")
+		return []Checksum{c}, err
+	}
+	if r.cfg.Sidecar != nil {
+		content, err := readSidecar(ctx, r.cfg.Sidecar, "checksum")
+		if err != nil {
+			return nil, err
+		}
+		c, err := ParseChecksum(string(content), "sidecar")
+		return []Checksum{c}, err
+	}
+	return metadataChecksums(m.Checksums), nil
+}
+
+// sidecarPoll polls a resource and its sidecar. A change to the resource is remembered when the sidecar fails to
+// poll, so the next successful poll still reports it rather than losing it.
+type sidecarPoll struct {
+	mu      sync.Mutex
+	pending bool
+}
+
+// poll polls the resource and the sidecar, if there is one, and reports an update if either changed.
+func (p *sidecarPoll) poll(ctx context.Context, r, sidecar resource.Resource, kind string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	changed, err := r.Poll(ctx)
+	if err != nil || sidecar == nil {
+		return changed, err
+	}
+	sidecarChanged, err := sidecar.Poll(ctx)
+	if err != nil {
+		p.pending = p.pending || changed
+		return false, fmt.Errorf("[gosprout] %s sidecar: %w", kind, err)
+	}
+	changed = changed || sidecarChanged || p.pending
+	p.pending = false
+	return changed, nil
+}
+
+// refreshBuffered refreshes the resource and reads all of its data, so it can be verified before it is used. It
+// returns false if there is nothing to verify, after giving any error to the error handler.
+func refreshBuffered(ctx context.Context, r resource.Resource, maxSize int64,
+	errorHandler func(error)) ([]byte, meta.Metadata, bool) {
+	var data []byte
+	var m meta.Metadata
+	var readErr, sourceErr error
+	delivered := false
+	r.Refresh(ctx, func(reader io.Reader) {
+		m, _ = meta.Of(reader)
+		data, readErr = readLimited(reader, maxSize)
+		delivered = readErr == nil
+	}, func(e error) {
+		if e != nil && sourceErr == nil {
+			sourceErr = e
+		}
+	})
+	if readErr != nil {
+		errorHandler(readErr)
+		return nil, m, false
+	}
+	if sourceErr != nil {
+		errorHandler(sourceErr)
+		return nil, m, false
+	}
+	return data, m, delivered
+}
+
+// readLimited reads all of the reader, failing if it is longer than maxSize. A negative maxSize means no limit.
+func readLimited(reader io.Reader, maxSize int64) ([]byte, error) {
+	if maxSize < 0 {
+		return ioutil.ReadAll(reader)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("[gosprout] data to verify exceeds the maximum size of %d bytes", maxSize)
+	}
+	return data, nil
+}
+
+// readSidecar returns the content of a sidecar, which is expected to be small.
+func readSidecar(ctx context.Context, sidecar resource.Resource, kind string) ([]byte, error) {
+	var content []byte
+	var err error
+	sidecar.Refresh(ctx, func(reader io.Reader) {
+		content, err = ioutil.ReadAll(io.LimitReader(reader, 4096))
+	}, func(e error) {
+		if e != nil && err == nil {
+			err = e
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[gosprout] %s sidecar: %w", kind, err)
+	}
+	return content, nil
+}
@@ -0,0 +1,216 @@
+package verify
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// MissingSignatureError is returned when the data has no signature.
+	MissingSignatureError = errors.New("[gosprout] data is not signed")
+	// MalformedSignatureError is returned when a signature cannot be parsed.
+	MalformedSignatureError = errors.New("[gosprout] signature is malformed")
+	// BadSignatureError is returned when no signature of the data verifies with a trusted key.
+	BadSignatureError = errors.New("[gosprout] signature does not verify with a trusted key")
+	// UntrustedKeyError is returned when the data is only signed by keys which are not trusted.
+	UntrustedKeyError = errors.New("[gosprout] data is not signed by a trusted key")
+	// NoTrustedKeysError is returned when there are no trusted keys to verify a signature with.
+	NoTrustedKeysError = errors.New("[gosprout] no trusted keys to verify signatures with")
+	// MalformedKeyError is returned when a public key cannot be parsed or has the wrong size.
+	MalformedKeyError = errors.New("[gosprout] public key is malformed")
+
+	base64Encodings = []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+	}
+)
+
+// SignatureError is returned when the signature of the data cannot be verified.
+type SignatureError struct {
+	// Source is where the signature came from: "sidecar" or "attribute".
+	Source string
+	Err    error
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("[gosprout] signature from %s: %v", e.Source, e.Err)
+}
+
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
+
+// KeyID identifies a public key, as the first 8 bytes of its SHA-256 digest in hex.
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ParsePublicKey parses an Ed25519 public key in PEM ("PUBLIC KEY"), base64 or hex form.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", MalformedKeyError, err)
+		}
+		if k, ok := key.(ed25519.PublicKey); ok {
+			return k, nil
+		}
+		return nil, fmt.Errorf("%w: %T is not an Ed25519 key", MalformedKeyError, key)
+	}
+
+	s = strings.TrimSpace(s)
+	if b, err := hex.DecodeString(s); err == nil && len(b) == ed25519.PublicKeySize {
+		return ed25519.PublicKey(b), nil
+	}
+	if b := decodeBase64(s); len(b) == ed25519.PublicKeySize {
+		return ed25519.PublicKey(b), nil
+	}
+	return nil, MalformedKeyError
+}
+
+// Sign signs the data with the private key, in the "<key id>:<base64 signature>" form read from sidecars and
+// attributes.
+func Sign(key ed25519.PrivateKey, data []byte) string {
+	id := KeyID(key.Public().(ed25519.PublicKey))
+	return id + ":" + base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+}
+
+// KeyRing is the set of trusted public keys. It is safe to change while in use, so keys can be rotated without
+// restarting: trust the new key, sign new versions with both keys, and then remove the old key.
+type KeyRing struct {
+	mu   *sync.RWMutex
+	keys map[string]ed25519.PublicKey
+}
+
+// NewKeyRing creates a key ring which trusts the keys.
+func NewKeyRing(keys ...ed25519.PublicKey) (*KeyRing, error) {
+	k := &KeyRing{mu: &sync.RWMutex{}, keys: map[string]ed25519.PublicKey{}}
+	for _, key := range keys {
+		if _, err := k.Add(key); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// Add trusts the key, and returns its id.
+func (k *KeyRing) Add(key ed25519.PublicKey) (string, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return "", MalformedKeyError
+	}
+	id := KeyID(key)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[id] = key
+	return id, nil
+}
+
+// Remove stops trusting the key with the id.
+func (k *KeyRing) Remove(id string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.keys, id)
+}
+
+// IDs returns the ids of the trusted keys, sorted.
+func (k *KeyRing) IDs() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Verify checks the signatures of the data, and returns the id of the trusted key which verified one of them. The
+// signatures are separated by whitespace or commas, each as "<key id>:<base64 signature>" or just the base64
+// signature, which is tried with every trusted key. A raw 64 byte signature is accepted too.
+func (k *KeyRing) Verify(data []byte, signatures []byte) (string, error) {
+	sigs, err := parseSignatures(signatures)
+	if err != nil {
+		return "", err
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if len(k.keys) == 0 {
+		return "", NoTrustedKeysError
+	}
+	tried := false
+	for _, s := range sigs {
+		if s.keyID != "" {
+			key, ok := k.keys[s.keyID]
+			if !ok {
+				continue
+			}
+			tried = true
+			if ed25519.Verify(key, data, s.sig) {
+				return s.keyID, nil
+			}
+			continue
+		}
+		tried = true
+		for id, key := range k.keys {
+			if ed25519.Verify(key, data, s.sig) {
+				return id, nil
+			}
+		}
+	}
+	if !tried {
+		return "", UntrustedKeyError
+	}
+	return "", BadSignatureError
+}
+
+type signature struct {
+	keyID string
+	sig   []byte
+}
+
+func parseSignatures(content []byte) ([]signature, error) {
+	text := strings.TrimSpace(string(content))
+	if text == "" {
+		return nil, MissingSignatureError
+	}
+
+	var sigs []signature
+	for _, field := range strings.Fields(strings.Replace(text, ",", " ", -1)) {
+		s := signature{}
+		if i := strings.Index(field, ":"); i >= 0 {
+			s.keyID, field = strings.ToLower(field[:i]), field[i+1:]
+		}
+		if s.sig = decodeBase64(field); len(s.sig) != ed25519.SignatureSize {
+			sigs = nil
+			break
+		}
+		sigs = append(sigs, s)
+	}
+	if sigs != nil {
+		return sigs, nil
+	}
+	// A binary signature, as written by tools like "openssl pkeyutl -sign".
+	if len(content) == ed25519.SignatureSize {
+		return []signature{{sig: content}}, nil
+	}
+	return nil, MalformedSignatureError
+}
+
+func decodeBase64(s string) []byte {
+	for _, encoding := range base64Encodings {
+		if b, err := encoding.DecodeString(s); err == nil {
+			return b
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"github.com/fire00f1y/go-sprout/resource/mem"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func generateKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	return public, private
+}
+
+// attributed is a resource whose reader carries the given attributes, like custom object metadata.
+type attributed struct {
+	*mem.Resource
+	attributes map[string]string
+}
+
+func (r attributed) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	r.Resource.Refresh(ctx, func(reader io.Reader) {
+		m, _ := meta.Of(reader)
+		m.Attributes = r.attributes
+		updateFunc(meta.NewReader(reader, m))
+	}, errorHandler)
+}
+
+func TestParsePublicKey(t *testing.T) {
+	public, _ := generateKey(t)
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{input: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))},
+		{input: base64.StdEncoding.EncodeToString(public) + "\n"},
+		{input: hex.EncodeToString(public)},
+		{input: "not a key", err: MalformedKeyError},
+		{input: base64.StdEncoding.EncodeToString(public[:16]), err: MalformedKeyError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			key, err := ParsePublicKey(test.input)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v; got %v\n", test.err, err)
+			}
+			if err == nil && !bytes.Equal(key, public) {
+				t.Errorf("expected %x; got %x\n", public, key)
+			}
+		})
+	}
+}
+
+func TestSignedResource_Refresh(t *testing.T) {
+	oldPublic, oldPrivate := generateKey(t)
+	newPublic, newPrivate := generateKey(t)
+	_, untrusted := generateKey(t)
+	other := []byte(`{"port":1}`)
+	bareSig := base64.StdEncoding.EncodeToString(ed25519.Sign(newPrivate, payload))
+
+	tests := []struct {
+		sidecar    string
+		attributes map[string]string
+		removeOld  bool
+		err        error
+	}{
+		{sidecar: Sign(oldPrivate, payload)},
+		{sidecar: bareSig + "\n"},
+		{sidecar: string(ed25519.Sign(newPrivate, payload))},
+		{sidecar: Sign(oldPrivate, other), err: BadSignatureError},
+		{sidecar: bareSig[:40], err: MalformedSignatureError},
+		{sidecar: Sign(untrusted, payload), err: UntrustedKeyError},
+		{sidecar: " \n", err: MissingSignatureError},
+		// During a rotation the data is signed with both keys, and either is enough.
+		{sidecar: Sign(untrusted, payload) + "\n" + Sign(newPrivate, payload)},
+		{sidecar: Sign(oldPrivate, payload) + "," + Sign(newPrivate, payload), removeOld: true},
+		{sidecar: Sign(oldPrivate, payload), removeOld: true, err: UntrustedKeyError},
+		{attributes: map[string]string{"signature": Sign(newPrivate, payload)}},
+		{attributes: map[string]string{"signature": Sign(newPrivate, other)}, err: BadSignatureError},
+		{err: MissingSignatureError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			keys, err := NewKeyRing(oldPublic, newPublic)
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+			if test.removeOld {
+				keys.Remove(KeyID(oldPublic))
+			}
+			cfg := SignatureConfig{Keys: keys}
+			if test.sidecar != "" {
+				cfg.Sidecar = mem.NewResource([]byte(test.sidecar))
+			}
+			r, err := NewSignedResource(attributed{mem.NewResource(payload), test.attributes}, cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+
+			var got []byte
+			var refreshErr error
+			r.Refresh(context.Background(), func(reader io.Reader) {
+				got, _ = ioutil.ReadAll(reader)
+			}, func(e error) {
+				refreshErr = e
+			})
+
+			if !errors.Is(refreshErr, test.err) {
+				t.Fatalf("expected error %v; got %v\n", test.err, refreshErr)
+			}
+			if test.err != nil {
+				var signatureErr *SignatureError
+				if !errors.As(refreshErr, &signatureErr) {
+					t.Errorf("expected a *SignatureError; got %T\n", refreshErr)
+				}
+				if got != nil {
+					t.Errorf("update func ran for data which failed verification\n")
+				}
+				return
+			}
+			if string(got) != string(payload) {
+				t.Errorf("expected %s; got %s\n", payload, got)
+			}
+		})
+	}
+}
+
+func TestAttributesFromHeader(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Amz-Meta-Signature", "abc")
+	header.Set("X-Amz-Meta-", "ignored")
+	header.Set("Content-Type", "application/json")
+
+	expected := map[string]string{"signature": "abc"}
+	if got := meta.AttributesFromHeader(header, "x-amz-meta-"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v; got %v\n", expected, got)
+	}
+	if got := meta.AttributesFromHeader(header, "x-ms-meta-"); got != nil {
+		t.Errorf("expected no attributes; got %v\n", got)
+	}
+}
+
+func TestKeyRing(t *testing.T) {
+	public, _ := generateKey(t)
+	keys, err := NewKeyRing()
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if _, err := keys.Verify(payload, []byte(base64.StdEncoding.EncodeToString(make([]byte, 64)))); err != NoTrustedKeysError {
+		t.Errorf("expected %v; got %v\n", NoTrustedKeysError, err)
+	}
+	if _, err := keys.Add(public[:8]); err != MalformedKeyError {
+		t.Errorf("expected %v; got %v\n", MalformedKeyError, err)
+	}
+	id, _ := keys.Add(public)
+	if ids := keys.IDs(); len(ids) != 1 || ids[0] != id || len(id) != 16 {
+		t.Errorf("expected [%s]; got %v\n", id, ids)
+	}
+	if _, err := NewSignedResource(mem.NewResource(payload), SignatureConfig{}); err != NoTrustedKeysError {
+		t.Errorf("expected %v; got %v\n", NoTrustedKeysError, err)
+	}
+}
@@ -0,0 +1,82 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"github.com/fire00f1y/go-sprout/resource"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"io"
+)
+
+// SignatureConfig is where the signature of the data comes from and which keys are trusted to sign it.
+type SignatureConfig struct {
+	// Keys are the trusted public keys. It is required.
+	Keys *KeyRing
+	// Sidecar is a resource holding the detached signature, e.g. the "config.json.sig" next to "config.json". It
+	// is read on every Refresh, and a change to it is reported by Poll too.
+	Sidecar resource.Resource
+	// Attribute is the object metadata attribute holding the signature, which is used when there is no Sidecar.
+	// It defaults to "signature", i.e. the "x-amz-meta-signature" header on S3.
+	Attribute string
+	// MaxSize is the maximum size of the data, which is held in memory to be verified before the update func
+	// runs. It defaults to 100 MiB, and a negative value means there is no limit.
+	MaxSize int64
+}
+
+// SignedResource wraps another resource and only lets through data with a valid signature from a trusted key.
+//
+// A signature covers the data but not its version, so anyone who can write to the source can replace the current
+// data with an older version which was also signed, and roll the config back. Rotate the keys, or put a version in
+// the data and check it in the update func, when that matters.
+type SignedResource struct {
+	resource.Resource
+	cfg   SignatureConfig
+	polls sidecarPoll
+}
+
+// NewSignedResource wraps the resource so the update func only runs for data which is signed by a trusted key.
+// Unsigned or badly signed versions are reported to the error handler and skipped, so the previous config stays
+// active until a properly signed version is published.
+func NewSignedResource(r resource.Resource, cfg SignatureConfig) (*SignedResource, error) {
+	if cfg.Keys == nil {
+		return nil, NoTrustedKeysError
+	}
+	if cfg.Attribute == "" {
+		cfg.Attribute = "signature"
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = defaultMaxSize
+	}
+	return &SignedResource{Resource: r, cfg: cfg}, nil
+}
+
+// Poll polls the resource, and the sidecar if there is one, and reports an update if either changed. When the
+// data is published before its signature, the first Refresh fails and the new signature triggers another.
+func (r *SignedResource) Poll(ctx context.Context) (bool, error) {
+	return r.polls.poll(ctx, r.Resource, r.cfg.Sidecar, "signature")
+}
+
+// Refresh reads the data, verifies its signature and only then provides it to the update func. A failure is given
+// to the error handler as a *SignatureError, and the update func is not called.
+func (r *SignedResource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	data, m, ok := refreshBuffered(ctx, r.Resource, r.cfg.MaxSize, errorHandler)
+	if !ok {
+		return
+	}
+
+	source := "attribute"
+	signatures := []byte(m.Attributes[r.cfg.Attribute])
+	if r.cfg.Sidecar != nil {
+		source = "sidecar"
+		var err error
+		if signatures, err = readSidecar(ctx, r.cfg.Sidecar, "signature"); err != nil {
+			errorHandler(err)
+			return
+		}
+	}
+	if _, err := r.cfg.Keys.Verify(data, signatures); err != nil {
+		errorHandler(&SignatureError{Source: source, Err: err})
+		return
+	}
+	updateFunc(meta.NewReader(bytes.NewReader(data), m))
+}
@@ -0,0 +1,137 @@
+// The verify package checks the data of a resource before it reaches the update func, so only the bytes which were
+// published are ever loaded. A Resource checks the data against an expected checksum, which comes from the
+// configuration, a sidecar resource (like "config.json.sha256"), or the checksums the resource attached to its
+// reader, which are the object metadata for GCS and the response headers for the HTTP based resources. A
+// SignedResource requires a detached Ed25519 signature from a trusted key, from a sidecar or an object attribute.
+package verify
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"sort"
+	"strings"
+)
+
+var (
+	// ChecksumMismatchError is wrapped by an IntegrityError when the data does not match the expected checksum.
+	ChecksumMismatchError = errors.New("[gosprout] checksum mismatch")
+	// MissingChecksumError is returned when there is no expected checksum to verify the data with.
+	MissingChecksumError = errors.New("[gosprout] no expected checksum for the data")
+	// MalformedChecksumError is returned when the expected checksum cannot be parsed.
+	MalformedChecksumError = errors.New("[gosprout] expected checksum is malformed")
+	// UnsupportedAlgorithmError is returned when the expected checksum uses an unknown algorithm.
+	UnsupportedAlgorithmError = errors.New("[gosprout] unsupported checksum algorithm")
+
+	crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+	// algorithms are the supported algorithms, by their name in resource.Metadata checksums.
+	algorithms = map[string]func() hash.Hash{
+		"md5":    md5.New,
+		"sha1":   sha1.New,
+		"sha256": sha256.New,
+		"sha512": sha512.New,
+		"crc32":  func() hash.Hash { return crc32.NewIEEE() },
+		"crc32c": func() hash.Hash { return crc32.New(crc32cTable) },
+	}
+	// hexLengths are the algorithms a bare digest can be recognized as by its length.
+	hexLengths = map[int]string{
+		32:  "md5",
+		40:  "sha1",
+		64:  "sha256",
+		128: "sha512",
+	}
+)
+
+// IntegrityError is returned when the data does not match an expected checksum.
+type IntegrityError struct {
+	Algorithm string
+	// Source is where the expected checksum came from: "config", "sidecar" or "metadata".
+	Source   string
+	Expected string
+	Actual   string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("[gosprout] %s checksum from %s does not match: expected %s, got %s",
+		e.Algorithm, e.Source, e.Expected, e.Actual)
+}
+
+func (e *IntegrityError) Unwrap() error {
+	return ChecksumMismatchError
+}
+
+// Checksum is an expected digest of the data.
+type Checksum struct {
+	Algorithm string
+	// Hex is the lowercase hex digest.
+	Hex    string
+	Source string
+}
+
+// ParseChecksum parses a checksum in "algorithm:hex" or "algorithm=hex" form, or a bare hex digest whose
+// algorithm is recognized by its length (md5, sha1, sha256 or sha512). The first word of the line is used, so the
+// output of tools like sha256sum ("<hex>  <file>") can be parsed as-is.
+func ParseChecksum(s, source string) (Checksum, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return Checksum{}, MalformedChecksumError
+	}
+	s = fields[0]
+
+	algorithm := ""
+	if i := strings.IndexAny(s, ":="); i > 0 {
+		algorithm, s = strings.ToLower(s[:i]), s[i+1:]
+	}
+	s = strings.ToLower(s)
+	if _, err := hex.DecodeString(s); err != nil {
+		return Checksum{}, fmt.Errorf("%w: %v", MalformedChecksumError, err)
+	}
+	if algorithm == "" {
+		var ok bool
+		if algorithm, ok = hexLengths[len(s)]; !ok {
+			return Checksum{}, fmt.Errorf("%w: cannot tell the algorithm of a %d character digest",
+				MalformedChecksumError, len(s))
+		}
+	}
+	if _, ok := algorithms[algorithm]; !ok {
+		return Checksum{}, fmt.Errorf("%w: %q", UnsupportedAlgorithmError, algorithm)
+	}
+	return Checksum{Algorithm: algorithm, Hex: s, Source: source}, nil
+}
+
+// Check verifies the data against every checksum, and returns an *IntegrityError for the first mismatch.
+func Check(data []byte, checksums []Checksum) error {
+	for _, c := range checksums {
+		newHash, ok := algorithms[c.Algorithm]
+		if !ok {
+			return fmt.Errorf("%w: %q", UnsupportedAlgorithmError, c.Algorithm)
+		}
+		h := newHash()
+		h.Write(data)
+		if actual := hex.EncodeToString(h.Sum(nil)); actual != c.Hex {
+			return &IntegrityError{Algorithm: c.Algorithm, Source: c.Source, Expected: c.Hex, Actual: actual}
+		}
+	}
+	return nil
+}
+
+// metadataChecksums returns the checksums with a supported algorithm, in a stable order.
+func metadataChecksums(checksums map[string]string) []Checksum {
+	var out []Checksum
+	for algorithm, digest := range checksums {
+		if _, ok := algorithms[algorithm]; ok {
+			out = append(out, Checksum{Algorithm: algorithm, Hex: strings.ToLower(digest), Source: "metadata"})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Algorithm < out[j].Algorithm
+	})
+	return out
+}
@@ -0,0 +1,232 @@
+package verify
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/fire00f1y/go-sprout/resource/mem"
+	"github.com/fire00f1y/go-sprout/resource/meta"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var (
+	payload = []byte(`{"port":8080}`)
+	sha     = sha256Hex(payload)
+)
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func md5Hex(data []byte) string {
+	h := md5.Sum(data)
+	return hex.EncodeToString(h[:])
+}
+
+// checksummed is a resource whose reader carries the given checksums, like a gcs object or an s3 response.
+type checksummed struct {
+	*mem.Resource
+	checksums map[string]string
+}
+
+func (r checksummed) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	r.Resource.Refresh(ctx, func(reader io.Reader) {
+		m, _ := meta.Of(reader)
+		m.Checksums = r.checksums
+		updateFunc(meta.NewReader(reader, m))
+	}, errorHandler)
+}
+
+func TestParseChecksum(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Checksum
+		err      error
+	}{
+		{input: "sha256:" + sha, expected: Checksum{Algorithm: "sha256", Hex: sha, Source: "config"}},
+		{input: "SHA256=" + strings.ToUpper(sha), expected: Checksum{Algorithm: "sha256", Hex: sha, Source: "config"}},
+		{input: sha + "  config.json\n", expected: Checksum{Algorithm: "sha256", Hex: sha, Source: "config"}},
+		{input: md5Hex(payload), expected: Checksum{Algorithm: "md5", Hex: md5Hex(payload), Source: "config"}},
+		{input: "crc32c:0a1b2c3d", expected: Checksum{Algorithm: "crc32c", Hex: "0a1b2c3d", Source: "config"}},
+		{input: "", err: MalformedChecksumError},
+		{input: "sha256:xyz", err: MalformedChecksumError},
+		{input: "abcd", err: MalformedChecksumError},
+		{input: "blake3:abcd", err: UnsupportedAlgorithmError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			c, err := ParseChecksum(test.input, "config")
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v; got %v\n", test.err, err)
+			}
+			if err == nil && c != test.expected {
+				t.Errorf("expected %v; got %v\n", test.expected, c)
+			}
+		})
+	}
+}
+
+func TestResource_Refresh(t *testing.T) {
+	tests := []struct {
+		cfg       Config
+		checksums map[string]string
+		sidecar   string
+		err       error
+		source    string
+	}{
+		{cfg: Config{Expected: "sha256:" + sha}},
+		{cfg: Config{Expected: "sha256:" + md5Hex(nil) + md5Hex(nil)}, err: ChecksumMismatchError, source: "config"},
+		{sidecar: sha + "  config.json\n"},
+		{sidecar: md5Hex(nil), err: ChecksumMismatchError, source: "sidecar"},
+		{sidecar: "not a checksum", err: MalformedChecksumError},
+		{checksums: map[string]string{"md5": md5Hex(payload), "crc32c": "f65da327"}},
+		{checksums: map[string]string{"md5": md5Hex(nil)}, err: ChecksumMismatchError, source: "metadata"},
+		{checksums: map[string]string{"unknown": "00"}, err: MissingChecksumError},
+		{err: MissingChecksumError},
+		{cfg: Config{AllowUnverified: true}},
+		{cfg: Config{Expected: "sha256:" + sha, MaxSize: 4}},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			cfg := test.cfg
+			if test.sidecar != "" {
+				cfg.Sidecar = mem.NewResource([]byte(test.sidecar))
+			}
+			r, err := NewResource(checksummed{mem.NewResource(payload), test.checksums}, cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+
+			var got []byte
+			var refreshErr error
+			r.Refresh(context.Background(), func(reader io.Reader) {
+				got, _ = ioutil.ReadAll(reader)
+			}, func(e error) {
+				refreshErr = e
+			})
+
+			if cfg.MaxSize > 0 {
+				if refreshErr == nil || got != nil {
+					t.Errorf("expected the size limit to be enforced; got %q and %v\n", got, refreshErr)
+				}
+				return
+			}
+			if !errors.Is(refreshErr, test.err) {
+				t.Fatalf("expected error %v; got %v\n", test.err, refreshErr)
+			}
+			if test.err != nil {
+				if got != nil {
+					t.Errorf("update func ran for data which failed verification\n")
+				}
+				var integrityErr *IntegrityError
+				if test.source != "" && (!errors.As(refreshErr, &integrityErr) || integrityErr.Source != test.source) {
+					t.Errorf("expected an integrity error from %s; got %v\n", test.source, refreshErr)
+				}
+				return
+			}
+			if string(got) != string(payload) {
+				t.Errorf("expected %s; got %s\n", payload, got)
+			}
+		})
+	}
+}
+
+func TestResource_Poll(t *testing.T) {
+	data := mem.NewResource(payload)
+	sidecar := mem.NewResource([]byte(sha))
+	r, _ := NewResource(data, Config{Sidecar: sidecar})
+	ctx := context.Background()
+
+	tests := []struct {
+		change   func()
+		expected bool
+	}{
+		{change: func() {}, expected: true},
+		{change: func() {}, expected: false},
+		{change: func() { sidecar.Set([]byte(md5Hex(payload))) }, expected: true},
+		{change: func() { data.Set(payload) }, expected: true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			test.change()
+			changed, err := r.Poll(ctx)
+			if err != nil || changed != test.expected {
+				t.Errorf("expected %v; got %v and %v\n", test.expected, changed, err)
+			}
+		})
+	}
+}
+
+// failing is a resource whose Poll fails while err is set.
+type failing struct {
+	*mem.Resource
+	err error
+}
+
+func (r *failing) Poll(ctx context.Context) (bool, error) {
+	if r.err != nil {
+		return false, r.err
+	}
+	return r.Resource.Poll(ctx)
+}
+
+func TestResource_PollSidecarError(t *testing.T) {
+	data := mem.NewResource(payload)
+	sidecar := &failing{Resource: mem.NewResource([]byte(sha))}
+	r, _ := NewResource(data, Config{Sidecar: sidecar})
+	ctx := context.Background()
+	sidecarErr := errors.New("unavailable")
+
+	tests := []struct {
+		change   func()
+		expected bool
+		err      bool
+	}{
+		{change: func() {}, expected: true},
+		{change: func() { data.Set(payload); sidecar.err = sidecarErr }, err: true},
+		{change: func() {}, err: true},
+		{change: func() { sidecar.err = nil }, expected: true},
+		{change: func() {}, expected: false},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			test.change()
+			changed, err := r.Poll(ctx)
+			if (err != nil) != test.err || (err != nil && !errors.Is(err, sidecarErr)) {
+				t.Errorf("expected error %v; got %v\n", test.err, err)
+			}
+			if changed != test.expected {
+				t.Errorf("expected %v; got %v\n", test.expected, changed)
+			}
+		})
+	}
+}
+
+func TestChecksumsFromHeader(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-MD5", "mZFLkyvTelC5g8XnyQrpOw==")
+	header.Set("X-Goog-Hash", "crc32c=n03x6A==,md5=mZFLkyvTelC5g8XnyQrpOw==")
+	header.Set("X-Amz-Checksum-Sha256", "hPuZfZ5w0ArOGq4vsUyyKZgUKDOAhnPrvr7vD7Ff4Ro=")
+
+	expected := map[string]string{
+		"md5":    "99914b932bd37a50b983c5e7c90ae93b",
+		"crc32c": "9f4df1e8",
+		"sha256": "84fb997d9e70d00ace1aae2fb14cb22998142833808673ebbebeef0fb15fe11a",
+	}
+	if got := meta.ChecksumsFromHeader(header); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v; got %v\n", expected, got)
+	}
+}
@@ -0,0 +1,136 @@
+// The zookeeper package implements a resource for a znode in ZooKeeper. It implements resource.Notifier by setting
+// a data watch on the znode, which is re-armed every time it fires.
+//
+// Paths have the form "host:port[,host:port...]/<znode path>", e.g. "zk://zk1:2181,zk2:2181/config/app".
+package zookeeper
+
+import (
+	"errors"
+	"github.com/go-zookeeper/zk"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	missingServerError = errors.New("[gosprout] zookeeper path is missing a server")
+	missingNodeError   = errors.New("[gosprout] zookeeper path is missing a znode")
+	// NodeNotFoundError is returned when the znode does not exist. When notifying, a watch is still set so the
+	// znode is picked up once it is created.
+	NodeNotFoundError = errors.New("[gosprout] zookeeper znode not found")
+
+	defaultSessionTimeout = 10 * time.Second
+	defaultRetryInterval  = time.Second
+)
+
+// Config controls how a Resource connects to the ensemble.
+type Config struct {
+	Servers        []string
+	SessionTimeout time.Duration
+	// RetryInterval is how long to wait before trying again after a failed read or watch.
+	RetryInterval time.Duration
+}
+
+// conn is the part of *zk.Conn used by the resource.
+type conn interface {
+	Get(path string) ([]byte, *zk.Stat, error)
+	GetW(path string) ([]byte, *zk.Stat, <-chan zk.Event, error)
+	ExistsW(path string) (bool, *zk.Stat, <-chan zk.Event, error)
+	Close()
+}
+
+type dialer func(servers []string, sessionTimeout time.Duration) (conn, <-chan zk.Event, error)
+
+func dialZK(servers []string, sessionTimeout time.Duration) (conn, <-chan zk.Event, error) {
+	return zk.Connect(servers, sessionTimeout)
+}
+
+// Resource is a znode in ZooKeeper. Its version is the mzxid (the zxid of the last modification) and data
+// version from the znode stat. The connection is made lazily on the first Poll, Refresh or Notify, and is
+// replaced with a new one if the session expires.
+type Resource struct {
+	path string
+	cfg  Config
+	dial dialer
+
+	mu      *sync.Mutex
+	conn    conn
+	session <-chan zk.Event
+	loaded  bool
+	mzxid   int64
+	version int32
+	data    []byte
+}
+
+// NewResource creates a resource from a "host:port[,host:port...]/<znode path>" path.
+func NewResource(path string) (*Resource, error) {
+	u, err := url.Parse("zk://" + strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, missingServerError
+	}
+	return NewResourceWithConfig(u.Path, Config{Servers: strings.Split(u.Host, ",")})
+}
+
+// NewResourceWithConfig creates a resource for the znode path.
+func NewResourceWithConfig(path string, cfg Config) (*Resource, error) {
+	if path == "" || path == "/" {
+		return nil, missingNodeError
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if len(cfg.Servers) == 0 {
+		return nil, missingServerError
+	}
+	if cfg.SessionTimeout <= 0 {
+		cfg.SessionTimeout = defaultSessionTimeout
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = defaultRetryInterval
+	}
+
+	return &Resource{
+		path: path,
+		cfg:  cfg,
+		dial: dialZK,
+		mu:   &sync.Mutex{},
+	}, nil
+}
+
+// Close closes the connection to the ensemble, if there is one. It will be opened again if the resource is used.
+func (r *Resource) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closeConn()
+}
+
+func (r *Resource) connect() (conn, <-chan zk.Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		c, session, err := r.dial(r.cfg.Servers, r.cfg.SessionTimeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.conn, r.session = c, session
+	}
+	return r.conn, r.session, nil
+}
+
+// closeConn drops the connection so the next use dials a new session. The lock must be held.
+func (r *Resource) closeConn() {
+	if r.conn != nil {
+		r.conn.Close()
+	}
+	r.conn, r.session = nil, nil
+}
+
+func (r *Resource) reconnect() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closeConn()
+}
@@ -0,0 +1,45 @@
+package zookeeper
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestNewResource(t *testing.T) {
+	tests := []struct {
+		path          string
+		node          string
+		servers       []string
+		expectedError error
+	}{
+		{path: "localhost:2181/config/app", node: "/config/app", servers: []string{"localhost:2181"}},
+		{path: "zk1:2181,zk2:2181/app", node: "/app", servers: []string{"zk1:2181", "zk2:2181"}},
+		{path: "zk1:2181/", expectedError: missingNodeError},
+		{path: "", expectedError: missingServerError},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, err := NewResource(test.path)
+			if err != test.expectedError {
+				t.Errorf("expected error %v; got %v\n", test.expectedError, err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if res.path != test.node {
+				t.Errorf("expected znode %s; got %s\n", test.node, res.path)
+			}
+			if len(res.cfg.Servers) != len(test.servers) {
+				t.Errorf("expected servers %v; got %v\n", test.servers, res.cfg.Servers)
+				return
+			}
+			for j := range test.servers {
+				if res.cfg.Servers[j] != test.servers[j] {
+					t.Errorf("expected servers %v; got %v\n", test.servers, res.cfg.Servers)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package zookeeper
+
+import (
+	"bytes"
+	"context"
+	"github.com/go-zookeeper/zk"
+	"io"
+	"time"
+)
+
+// Poll reads the znode and compares its mzxid and data version with the last read.
+func (r *Resource) Poll(context.Context) (bool, error) {
+	c, _, err := r.connect()
+	if err != nil {
+		return false, err
+	}
+	data, stat, err := c.Get(r.path)
+	if err != nil {
+		return false, r.readError(err)
+	}
+	return r.store(data, stat), nil
+}
+
+// Refresh provides a reader for the data from the last Poll or notification. If nothing was read yet, the znode
+// is read directly.
+func (r *Resource) Refresh(ctx context.Context, updateFunc func(io.Reader), errorHandler func(error)) {
+	r.mu.Lock()
+	data, loaded := r.data, r.loaded
+	r.mu.Unlock()
+
+	if !loaded {
+		if _, err := r.Poll(ctx); err != nil {
+			errorHandler(err)
+			return
+		}
+		r.mu.Lock()
+		data = r.data
+		r.mu.Unlock()
+	}
+	updateFunc(bytes.NewReader(data))
+}
+
+// Notify reads the znode with a data watch and sends on the returned channel when the mzxid or version changes.
+// The watch is re-armed with a new read every time it fires. If the znode does not exist, an exists watch is set
+// so its creation is noticed. If the session expires, a new connection is made and the znode is read again.
+func (r *Resource) Notify(ctx context.Context, errorHandler func(error)) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	go func() {
+		defer close(ch)
+		defer r.Close()
+		first := true
+		for ctx.Err() == nil {
+			changed, watch, session, err := r.readWatch()
+			if err == NodeNotFoundError {
+				errorHandler(err)
+			} else if err != nil {
+				errorHandler(err)
+				r.wait(ctx)
+				continue
+			} else if changed || first {
+				first = false
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+
+			r.waitForEvent(ctx, watch, session, errorHandler)
+		}
+	}()
+	return ch
+}
+
+// readWatch reads the znode and sets a watch on it, or an exists watch if it does not exist. It returns whether
+// the version changed along with the watch and session event channels.
+func (r *Resource) readWatch() (bool, <-chan zk.Event, <-chan zk.Event, error) {
+	c, session, err := r.connect()
+	if err != nil {
+		return false, nil, nil, err
+	}
+
+	data, stat, watch, err := c.GetW(r.path)
+	if err == zk.ErrNoNode {
+		var exists bool
+		exists, _, watch, err = c.ExistsW(r.path)
+		if err == nil && !exists {
+			return false, watch, session, NodeNotFoundError
+		}
+		if err == nil {
+			// It was created in between the two calls. The exists watch will fire on the next change, but
+			// reading it again now is simpler.
+			data, stat, watch, err = c.GetW(r.path)
+		}
+	}
+	if err != nil {
+		return false, nil, nil, r.readError(err)
+	}
+	return r.store(data, stat), watch, session, nil
+}
+
+// waitForEvent blocks until the watch fires, the session expires or the context is done.
+func (r *Resource) waitForEvent(ctx context.Context, watch, session <-chan zk.Event, errorHandler func(error)) {
+	for {
+		select {
+		case e := <-watch:
+			if e.Type == zk.EventNotWatching {
+				if e.Err == zk.ErrSessionExpired {
+					r.reconnect()
+				} else if e.Err != nil {
+					errorHandler(e.Err)
+					r.wait(ctx)
+				}
+			}
+			return
+		case e, ok := <-session:
+			if !ok || e.State == zk.StateExpired {
+				r.reconnect()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Resource) store(data []byte, stat *zk.Stat) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := !r.loaded || stat.Mzxid != r.mzxid || stat.Version != r.version
+	r.loaded = true
+	r.mzxid = stat.Mzxid
+	r.version = stat.Version
+	r.data = data
+	return changed
+}
+
+// readError translates errors from a read. A session which expired or a connection which was closed is dropped,
+// so the next read makes a new one.
+func (r *Resource) readError(err error) error {
+	switch err {
+	case zk.ErrNoNode:
+		return NodeNotFoundError
+	case zk.ErrSessionExpired, zk.ErrClosing, zk.ErrConnectionClosed:
+		r.reconnect()
+	}
+	return err
+}
+
+func (r *Resource) wait(ctx context.Context) {
+	select {
+	case <-time.After(r.cfg.RetryInterval):
+	case <-ctx.Done():
+	}
+}
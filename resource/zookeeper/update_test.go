@@ -0,0 +1,226 @@
+package zookeeper
+
+import (
+	"context"
+	"github.com/go-zookeeper/zk"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEnsemble stands in for a ZooKeeper ensemble. Each dial creates a new session, and watches fire
+// the same way they do on a real server: once, for the next change only.
+type fakeEnsemble struct {
+	mu    sync.Mutex
+	zxid  int64
+	nodes map[string]*fakeNode
+	conn  *fakeConn
+	dials int
+}
+
+type fakeNode struct {
+	data []byte
+	stat zk.Stat
+}
+
+type fakeConn struct {
+	e       *fakeEnsemble
+	session chan zk.Event
+	watches map[string][]chan zk.Event
+}
+
+func newFakeEnsemble() *fakeEnsemble {
+	return &fakeEnsemble{nodes: map[string]*fakeNode{}}
+}
+
+func (e *fakeEnsemble) dial(servers []string, sessionTimeout time.Duration) (conn, <-chan zk.Event, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.dials++
+	e.conn = &fakeConn{e: e, session: make(chan zk.Event, 8), watches: map[string][]chan zk.Event{}}
+	return e.conn, e.conn.session, nil
+}
+
+func (e *fakeEnsemble) set(path, data string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.zxid++
+	event := zk.EventNodeDataChanged
+	n, ok := e.nodes[path]
+	if !ok {
+		n = &fakeNode{stat: zk.Stat{Czxid: e.zxid, Version: -1}}
+		e.nodes[path] = n
+		event = zk.EventNodeCreated
+	}
+	n.data = []byte(data)
+	n.stat.Mzxid = e.zxid
+	n.stat.Version++
+	e.fire(path, zk.Event{Type: event, Path: path})
+}
+
+// expire ends the current session, which invalidates all of its watches.
+func (e *fakeEnsemble) expire() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.fire("", zk.Event{Type: zk.EventNotWatching, State: zk.StateDisconnected, Err: zk.ErrSessionExpired})
+	e.conn.session <- zk.Event{Type: zk.EventSession, State: zk.StateExpired}
+}
+
+// fire sends the event to the watches on the path, or every watch for an empty path. The lock must be held.
+func (e *fakeEnsemble) fire(path string, event zk.Event) {
+	if e.conn == nil {
+		return
+	}
+	for p, watches := range e.conn.watches {
+		if path != "" && p != path {
+			continue
+		}
+		for _, w := range watches {
+			w <- event
+		}
+		delete(e.conn.watches, p)
+	}
+}
+
+func (c *fakeConn) Get(path string) ([]byte, *zk.Stat, error) {
+	c.e.mu.Lock()
+	defer c.e.mu.Unlock()
+	n, ok := c.e.nodes[path]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	stat := n.stat
+	return n.data, &stat, nil
+}
+
+func (c *fakeConn) watch(path string) <-chan zk.Event {
+	c.e.mu.Lock()
+	defer c.e.mu.Unlock()
+	w := make(chan zk.Event, 1)
+	c.watches[path] = append(c.watches[path], w)
+	return w
+}
+
+func (c *fakeConn) GetW(path string) ([]byte, *zk.Stat, <-chan zk.Event, error) {
+	data, stat, err := c.Get(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return data, stat, c.watch(path), nil
+}
+
+func (c *fakeConn) ExistsW(path string) (bool, *zk.Stat, <-chan zk.Event, error) {
+	_, stat, err := c.Get(path)
+	return err == nil, stat, c.watch(path), nil
+}
+
+func (c *fakeConn) Close() {}
+
+func newTestResource(e *fakeEnsemble, path string) *Resource {
+	res, _ := NewResourceWithConfig(path, Config{Servers: []string{"localhost:2181"}, RetryInterval: 10 * time.Millisecond})
+	res.dial = e.dial
+	return res
+}
+
+func readAll(t *testing.T, res *Resource) string {
+	value := ""
+	res.Refresh(context.Background(),
+		func(r io.Reader) {
+			b, _ := ioutil.ReadAll(r)
+			value = string(b)
+		}, func(e error) {
+			t.Errorf("error during refresh: %v\n", e)
+		})
+	return value
+}
+
+func expectNotification(t *testing.T, ch <-chan struct{}) {
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Errorf("timed out waiting for a notification\n")
+	}
+}
+
+func TestResource_Poll(t *testing.T) {
+	e := newFakeEnsemble()
+	res := newTestResource(e, "/config/app")
+
+	tests := []struct {
+		set     string
+		updated bool
+		err     error
+	}{
+		{set: "", updated: false, err: NodeNotFoundError},
+		{set: "v1", updated: true},
+		{set: "", updated: false},
+		{set: "v2", updated: true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if test.set != "" {
+				e.set("/config/app", test.set)
+			}
+			updated, err := res.Poll(context.Background())
+			if err != test.err {
+				t.Errorf("expected error %v; got %v\n", test.err, err)
+			}
+			if updated != test.updated {
+				t.Errorf("expected updated to be %v; got %v\n", test.updated, updated)
+			}
+		})
+	}
+}
+
+func TestResource_Notify(t *testing.T) {
+	e := newFakeEnsemble()
+	res := newTestResource(e, "/config/app")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error, 8)
+	ch := res.Notify(ctx, func(err error) { errs <- err })
+
+	// The znode does not exist yet, so an exists watch is set and its creation is notified.
+	select {
+	case err := <-errs:
+		if err != NodeNotFoundError {
+			t.Errorf("expected NodeNotFoundError; got %v\n", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("timed out waiting for NodeNotFoundError\n")
+	}
+	e.set("/config/app", "v1")
+	expectNotification(t, ch)
+	if v := readAll(t, res); v != "v1" {
+		t.Errorf("expected v1; got %s\n", v)
+	}
+
+	// The watch is re-armed after it fires.
+	for _, v := range []string{"v2", "v3"} {
+		e.set("/config/app", v)
+		expectNotification(t, ch)
+		if got := readAll(t, res); got != v {
+			t.Errorf("expected %s; got %s\n", v, got)
+		}
+	}
+
+	// After the session expires, a new one is made and the znode is read again with a new watch.
+	e.expire()
+	time.Sleep(50 * time.Millisecond)
+	e.set("/config/app", "v4")
+	expectNotification(t, ch)
+	if v := readAll(t, res); v != "v4" {
+		t.Errorf("expected v4; got %s\n", v)
+	}
+	e.mu.Lock()
+	dials := e.dials
+	e.mu.Unlock()
+	if dials != 2 {
+		t.Errorf("expected a second session after expiration; got %d dials\n", dials)
+	}
+}
@@ -0,0 +1,64 @@
+package schema
+
+import (
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	hostnamePattern = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*\.?$`)
+	uuidPattern     = regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+	// formats are the checks of the format keyword. Other formats are not checked.
+	formats = map[string]func(string) bool{
+		"date-time": func(s string) bool {
+			_, err := time.Parse(time.RFC3339Nano, s)
+			return err == nil
+		},
+		"date": func(s string) bool {
+			_, err := time.Parse("2006-01-02", s)
+			return err == nil
+		},
+		"time": func(s string) bool {
+			_, err := time.Parse("15:04:05.999999999Z07:00", s)
+			return err == nil
+		},
+		"email": func(s string) bool {
+			a, err := mail.ParseAddress(s)
+			return err == nil && a.Address == s
+		},
+		"hostname": func(s string) bool {
+			return len(s) <= 253 && hostnamePattern.MatchString(s)
+		},
+		"ipv4": func(s string) bool {
+			ip := net.ParseIP(s)
+			return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+		},
+		"ipv6": func(s string) bool {
+			return net.ParseIP(s) != nil && strings.Contains(s, ":")
+		},
+		"uri": func(s string) bool {
+			u, err := url.Parse(s)
+			return err == nil && u.IsAbs()
+		},
+		"uri-reference": func(s string) bool {
+			_, err := url.Parse(s)
+			return err == nil
+		},
+		"uuid": uuidPattern.MatchString,
+		"regex": func(s string) bool {
+			_, err := regexp.Compile(s)
+			return err == nil
+		},
+		"duration": func(s string) bool {
+			_, err := time.ParseDuration(s)
+			return err == nil || isoDuration.MatchString(s)
+		},
+	}
+
+	isoDuration = regexp.MustCompile(`^P(\d+W|(\d+Y)?(\d+M)?(\d+D)?(T(\d+H)?(\d+M)?(\d+(\.\d+)?S)?)?)$`)
+)
@@ -0,0 +1,601 @@
+// The schema package validates JSON and YAML documents against a JSON Schema, so a config can be checked for its
+// structure before it is applied. The common keywords of drafts 4 to 2020-12 are supported, with references to
+// other parts of the same schema. Every violation is reported, each with the JSON pointer of the invalid value.
+//
+// A Validator holds the current schema, so the schema can be watched like any other resource and replaced when it
+// changes. See gosprout.UpdateValidated to validate documents before they are applied.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// InvalidSchemaError is returned when a schema cannot be compiled.
+	InvalidSchemaError = errors.New("[gosprout] invalid schema")
+	// InvalidDocumentError is returned when a document is not valid JSON or YAML.
+	InvalidDocumentError = errors.New("[gosprout] document is not valid JSON or YAML")
+)
+
+// Violation is one way in which a document does not match the schema.
+type Violation struct {
+	// Pointer is the JSON pointer to the invalid value in the document, which is "" for the whole document.
+	Pointer string
+	// Keyword is the schema keyword which failed, e.g. "required" or "maximum". It is empty where the schema is
+	// false, which allows no value at all.
+	Keyword string
+	// SchemaPointer locates the failed keyword in the schema, e.g. "#/properties/port/maximum".
+	SchemaPointer string
+	Message       string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%q: %s", v.Pointer, v.Message)
+}
+
+// ValidationError is returned for a document which does not match the schema, with every violation.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.String()
+	}
+	return fmt.Sprintf("[gosprout] document does not match the schema: %s", strings.Join(messages, "; "))
+}
+
+// Schema is a compiled JSON Schema. It is safe for concurrent use.
+type Schema struct {
+	root *node
+}
+
+// Compile compiles a JSON Schema, which may be written in JSON or YAML.
+func Compile(data []byte) (*Schema, error) {
+	doc, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidSchemaError, err)
+	}
+	c := &compiler{doc: doc, nodes: map[string]*node{}}
+	root, err := c.compile(doc, "#")
+	if err != nil {
+		return nil, err
+	}
+	// Compiling the target of a reference can find more references.
+	for len(c.refs) > 0 {
+		n := c.refs[0]
+		c.refs = c.refs[1:]
+		if n.refNode, err = c.resolve(n.ref); err != nil {
+			return nil, fmt.Errorf("%w: %s/$ref: %v", InvalidSchemaError, n.ptr, err)
+		}
+	}
+	if err := c.checkCycles(); err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// CompileFile compiles the JSON Schema in a file.
+func CompileFile(path string) (*Schema, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(data)
+}
+
+// MustCompile compiles a JSON Schema, like one embedded in the program, and panics if it is invalid.
+func MustCompile(data []byte) *Schema {
+	s, err := Compile(data)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Decode decodes a JSON or YAML document into the values encoding/json produces, with json.Number for numbers, so
+// it can be validated.
+func Decode(data []byte) (interface{}, error) {
+	if json.Valid(data) {
+		return decodeJSON(data)
+	}
+	return decodeYAML(data)
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidDocumentError, err)
+	}
+	return v, nil
+}
+
+// node is a compiled schema.
+type node struct {
+	ptr string
+	// always is set for the true and false schemas.
+	always *bool
+
+	ref     string
+	refNode *node
+
+	types  []string
+	enum   []interface{}
+	consts []interface{}
+
+	properties           map[string]*node
+	patternProperties    []patternNode
+	additionalProperties *node
+	required             []string
+	propertyNames        *node
+	minProperties        *int
+	maxProperties        *int
+	dependentRequired    map[string][]string
+	dependentSchemas     map[string]*node
+
+	prefixItems []*node
+	items       *node
+	contains    *node
+	minContains *int
+	maxContains *int
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+	format    string
+
+	minimum          *big.Rat
+	maximum          *big.Rat
+	exclusiveMinimum *big.Rat
+	exclusiveMaximum *big.Rat
+	multipleOf       *big.Rat
+
+	allOf                []*node
+	anyOf                []*node
+	oneOf                []*node
+	not                  *node
+	ifNode, then, orElse *node
+}
+
+type patternNode struct {
+	re *regexp.Regexp
+	n  *node
+}
+
+type compiler struct {
+	doc   interface{}
+	nodes map[string]*node
+	refs  []*node
+}
+
+// checkCycles rejects schemas which apply themselves to the same value again, like two definitions which only
+// refer to each other. Validating with them would never end.
+func (c *compiler) checkCycles() error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[*node]int{}
+	var visit func(n *node) *node
+	visit = func(n *node) *node {
+		switch state[n] {
+		case visiting:
+			return n
+		case done:
+			return nil
+		}
+		state[n] = visiting
+		for _, next := range n.inPlace() {
+			if cycle := visit(next); cycle != nil {
+				return cycle
+			}
+		}
+		state[n] = done
+		return nil
+	}
+
+	ptrs := make([]string, 0, len(c.nodes))
+	for ptr := range c.nodes {
+		ptrs = append(ptrs, ptr)
+	}
+	sort.Strings(ptrs)
+	for _, ptr := range ptrs {
+		if cycle := visit(c.nodes[ptr]); cycle != nil {
+			return fmt.Errorf("%w: %s refers back to itself for the same value", InvalidSchemaError, cycle.ptr)
+		}
+	}
+	return nil
+}
+
+// inPlace returns the subschemas which validate the same value as the node, rather than a part of it.
+func (n *node) inPlace() []*node {
+	var nodes []*node
+	if n.refNode != nil {
+		nodes = append(nodes, n.refNode)
+	}
+	nodes = append(nodes, n.allOf...)
+	nodes = append(nodes, n.anyOf...)
+	nodes = append(nodes, n.oneOf...)
+	for _, s := range n.dependentSchemas {
+		nodes = append(nodes, s)
+	}
+	for _, s := range []*node{n.not, n.ifNode, n.then, n.orElse} {
+		if s != nil {
+			nodes = append(nodes, s)
+		}
+	}
+	return nodes
+}
+
+func (c *compiler) compile(v interface{}, ptr string) (*node, error) {
+	if n, ok := c.nodes[ptr]; ok {
+		return n, nil
+	}
+	n := &node{ptr: ptr}
+	c.nodes[ptr] = n
+
+	if b, ok := v.(bool); ok {
+		n.always = &b
+		return n, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not an object or a boolean", InvalidSchemaError, ptr)
+	}
+	k := keywords{c: c, m: m, ptr: ptr}
+
+	if ref, ok := m["$ref"].(string); ok {
+		n.ref = ref
+		c.refs = append(c.refs, n)
+	}
+	switch t := m["type"].(type) {
+	case nil:
+	case string:
+		n.types = []string{t}
+	case []interface{}:
+		for _, s := range t {
+			if s, ok := s.(string); ok {
+				n.types = append(n.types, s)
+			} else {
+				k.fail("type", "must be a string or an array of strings")
+			}
+		}
+	default:
+		k.fail("type", "must be a string or an array of strings")
+	}
+	if e, ok := m["enum"]; ok {
+		if n.enum, ok = e.([]interface{}); !ok {
+			k.fail("enum", "must be an array")
+		}
+	}
+	if v, ok := m["const"]; ok {
+		n.consts = []interface{}{v}
+	}
+
+	// Objects.
+	n.properties = k.schemaMap("properties")
+	for _, name := range sortedKeys(k.object("patternProperties")) {
+		re, err := regexp.Compile(name)
+		if err != nil {
+			k.fail("patternProperties", err.Error())
+			continue
+		}
+		n.patternProperties = append(n.patternProperties, patternNode{
+			re: re,
+			n:  k.schema("patternProperties/" + escape(name)),
+		})
+	}
+	n.additionalProperties = k.schema("additionalProperties")
+	n.required = k.strings("required")
+	n.propertyNames = k.schema("propertyNames")
+	n.minProperties = k.count("minProperties")
+	n.maxProperties = k.count("maxProperties")
+	n.dependentRequired = map[string][]string{}
+	n.dependentSchemas = k.schemaMap("dependentSchemas")
+	for name := range k.object("dependentRequired") {
+		n.dependentRequired[name] = k.strings("dependentRequired/" + escape(name))
+	}
+	// Draft 7 and earlier combine both in dependencies.
+	for name, d := range k.object("dependencies") {
+		key := "dependencies/" + escape(name)
+		if _, ok := d.([]interface{}); ok {
+			n.dependentRequired[name] = k.strings(key)
+		} else {
+			n.dependentSchemas[name] = k.schema(key)
+		}
+	}
+
+	// Arrays. Before 2020-12, an array of items were the prefix items and additionalItems applied to the rest.
+	if _, ok := m["prefixItems"]; ok {
+		n.prefixItems = k.schemas("prefixItems")
+		n.items = k.schema("items")
+	} else if _, ok := m["items"].([]interface{}); ok {
+		n.prefixItems = k.schemas("items")
+		n.items = k.schema("additionalItems")
+	} else {
+		n.items = k.schema("items")
+	}
+	n.contains = k.schema("contains")
+	n.minContains = k.count("minContains")
+	n.maxContains = k.count("maxContains")
+	n.minItems = k.count("minItems")
+	n.maxItems = k.count("maxItems")
+	if u, ok := m["uniqueItems"]; ok {
+		if n.uniqueItems, ok = u.(bool); !ok {
+			k.fail("uniqueItems", "must be a boolean")
+		}
+	}
+
+	// Strings.
+	n.minLength = k.count("minLength")
+	n.maxLength = k.count("maxLength")
+	if p, ok := m["pattern"]; ok {
+		s, _ := p.(string)
+		re, err := regexp.Compile(s)
+		if err != nil {
+			k.fail("pattern", err.Error())
+		}
+		n.pattern = re
+	}
+	n.format, _ = m["format"].(string)
+
+	// Numbers. In draft 4 the exclusive keywords were booleans modifying minimum and maximum.
+	n.minimum = k.number("minimum")
+	n.maximum = k.number("maximum")
+	if b, ok := m["exclusiveMinimum"].(bool); ok {
+		if b {
+			n.exclusiveMinimum, n.minimum = n.minimum, nil
+		}
+	} else {
+		n.exclusiveMinimum = k.number("exclusiveMinimum")
+	}
+	if b, ok := m["exclusiveMaximum"].(bool); ok {
+		if b {
+			n.exclusiveMaximum, n.maximum = n.maximum, nil
+		}
+	} else {
+		n.exclusiveMaximum = k.number("exclusiveMaximum")
+	}
+	n.multipleOf = k.number("multipleOf")
+	if n.multipleOf != nil && n.multipleOf.Sign() <= 0 {
+		k.fail("multipleOf", "must be greater than 0")
+	}
+
+	// Applicators.
+	n.allOf = k.schemas("allOf")
+	n.anyOf = k.schemas("anyOf")
+	n.oneOf = k.schemas("oneOf")
+	n.not = k.schema("not")
+	n.ifNode = k.schema("if")
+	n.then = k.schema("then")
+	n.orElse = k.schema("else")
+
+	// Definitions are compiled, even if unused, so errors in them are found.
+	k.schemaMap("definitions")
+	k.schemaMap("$defs")
+
+	return n, k.err
+}
+
+// resolve returns the schema a local reference points to.
+func (c *compiler) resolve(ref string) (*node, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("only references within the schema are supported, not %q", ref)
+	}
+	fragment, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, err
+	}
+	if fragment != "" && !strings.HasPrefix(fragment, "/") {
+		return nil, fmt.Errorf("anchors are not supported: %q", ref)
+	}
+
+	v := c.doc
+	ptr := "#"
+	if fragment != "" {
+		for _, token := range strings.Split(fragment[1:], "/") {
+			token = unescape(token)
+			switch t := v.(type) {
+			case map[string]interface{}:
+				var ok bool
+				if v, ok = t[token]; !ok {
+					return nil, fmt.Errorf("%q does not exist", ref)
+				}
+			case []interface{}:
+				i, ok := index(token, len(t))
+				if !ok {
+					return nil, fmt.Errorf("%q does not exist", ref)
+				}
+				v = t[i]
+			default:
+				return nil, fmt.Errorf("%q does not exist", ref)
+			}
+			ptr += "/" + escape(token)
+		}
+	}
+	return c.compile(v, ptr)
+}
+
+// keywords reads the keywords of a schema object, keeping the first error.
+type keywords struct {
+	c   *compiler
+	m   map[string]interface{}
+	ptr string
+	err error
+}
+
+func (k *keywords) fail(keyword, message string) {
+	if k.err == nil {
+		k.err = fmt.Errorf("%w: %s/%s %s", InvalidSchemaError, k.ptr, keyword, message)
+	}
+}
+
+// lookup returns the value at a path of keys below the schema, like "properties/port".
+func (k *keywords) lookup(path string) (interface{}, bool) {
+	var v interface{} = k.m
+	for _, token := range strings.Split(path, "/") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[unescape(token)]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func (k *keywords) schema(path string) *node {
+	v, ok := k.lookup(path)
+	if !ok {
+		return nil
+	}
+	n, err := k.c.compile(v, k.ptr+"/"+path)
+	if err != nil && k.err == nil {
+		k.err = err
+	}
+	return n
+}
+
+func (k *keywords) schemas(keyword string) []*node {
+	v, ok := k.m[keyword]
+	if !ok {
+		return nil
+	}
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		k.fail(keyword, "must be a non-empty array")
+		return nil
+	}
+	nodes := make([]*node, len(list))
+	for i, s := range list {
+		n, err := k.c.compile(s, fmt.Sprintf("%s/%s/%d", k.ptr, keyword, i))
+		if err != nil && k.err == nil {
+			k.err = err
+		}
+		nodes[i] = n
+	}
+	return nodes
+}
+
+func (k *keywords) object(keyword string) map[string]interface{} {
+	v, ok := k.m[keyword]
+	if !ok {
+		return nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		k.fail(keyword, "must be an object")
+	}
+	return m
+}
+
+func (k *keywords) schemaMap(keyword string) map[string]*node {
+	nodes := map[string]*node{}
+	for name := range k.object(keyword) {
+		nodes[name] = k.schema(keyword + "/" + escape(name))
+	}
+	return nodes
+}
+
+func (k *keywords) strings(path string) []string {
+	v, ok := k.lookup(path)
+	if !ok {
+		return nil
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		k.fail(path, "must be an array of strings")
+		return nil
+	}
+	out := make([]string, len(list))
+	for i, s := range list {
+		if out[i], ok = s.(string); !ok {
+			k.fail(path, "must be an array of strings")
+		}
+	}
+	return out
+}
+
+func (k *keywords) number(keyword string) *big.Rat {
+	v, ok := k.m[keyword]
+	if !ok {
+		return nil
+	}
+	r, ok := rat(v)
+	if !ok {
+		k.fail(keyword, "must be a number")
+	}
+	return r
+}
+
+func (k *keywords) count(keyword string) *int {
+	r := k.number(keyword)
+	if r == nil {
+		return nil
+	}
+	if !r.IsInt() || r.Sign() < 0 || !r.Num().IsInt64() {
+		k.fail(keyword, "must be a non-negative integer")
+		return nil
+	}
+	i := int(r.Num().Int64())
+	return &i
+}
+
+func rat(v interface{}) (*big.Rat, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Rat).SetString(string(n))
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// escape escapes a JSON pointer token, as in RFC 6901.
+func escape(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+func unescape(token string) string {
+	return strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+}
+
+func index(token string, length int) (int, bool) {
+	i := 0
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, false
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		i = i*10 + int(c-'0')
+		if i >= length {
+			return 0, false
+		}
+	}
+	return i, true
+}
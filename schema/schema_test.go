@@ -0,0 +1,261 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+var configSchema = []byte(`{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["name", "port"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "pattern": "^[a-z-]+$"},
+		"port": {"type": "integer", "minimum": 1, "maximum": 65535},
+		"ratio": {"type": "number", "exclusiveMinimum": 0, "multipleOf": 0.25},
+		"mode": {"enum": ["fast", "safe"]},
+		"tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true, "maxItems": 3},
+		"upstreams": {"type": "array", "items": {"$ref": "#/$defs/upstream"}},
+		"owner": {"type": "string", "format": "email"},
+		"a/b": {"const": true}
+	},
+	"$defs": {
+		"upstream": {
+			"type": "object",
+			"required": ["url"],
+			"properties": {"url": {"type": "string", "format": "uri"}, "weight": {"type": "integer"}}
+		}
+	}
+}`)
+
+func pointers(err error) []string {
+	var v *ValidationError
+	if !errors.As(err, &v) {
+		return nil
+	}
+	var out []string
+	for _, violation := range v.Violations {
+		out = append(out, violation.Pointer+" "+violation.Keyword)
+	}
+	return out
+}
+
+func TestSchema_Validate(t *testing.T) {
+	s, err := Compile(configSchema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	tests := []struct {
+		doc      string
+		expected []string
+	}{
+		{doc: `{"name": "api", "port": 8080, "ratio": 0.75, "mode": "fast", "tags": ["a", "b"]}`},
+		{doc: "name: api\nport: 443\nupstreams:\n  - url: https://a.example\n    weight: 2\n"},
+		{doc: `{"name": "api", "port": 8080.0}`},
+		{
+			doc:      `{"name": "API", "port": 70000}`,
+			expected: []string{"/name pattern", "/port maximum"},
+		},
+		{
+			doc:      `{"port": "80", "extra": 1, "a/b": false}`,
+			expected: []string{` required`, "/a~1b const", "/extra additionalProperties", "/port type"},
+		},
+		{
+			doc:      `{"name": "api", "port": 1, "ratio": 0.3, "mode": "slow", "tags": ["a", "a", "b", "c"]}`,
+			expected: []string{"/mode enum", "/ratio multipleOf", "/tags maxItems", "/tags uniqueItems"},
+		},
+		{
+			doc:      `{"name": "api", "port": 1, "upstreams": [{"url": "https://a.example"}, {"weight": 1.5}, {"url": "nope"}]}`,
+			expected: []string{"/upstreams/1 required", "/upstreams/1/weight type", "/upstreams/2/url format"},
+		},
+		{
+			doc:      `{"name": "api", "port": 1, "owner": "not an address"}`,
+			expected: []string{"/owner format"},
+		},
+		{
+			doc:      `[1, 2]`,
+			expected: []string{" type"},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			got := pointers(s.ValidateBytes([]byte(test.doc)))
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected violations %q; got %q\n", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestSchema_ValidateYAML(t *testing.T) {
+	s := MustCompile([]byte(`{
+		"properties": {
+			"date": {"type": "string", "format": "date"},
+			"time": {"type": "string", "format": "date-time"},
+			"version": {"type": "string"},
+			"count": {"type": "integer"}
+		}
+	}`))
+
+	tests := []struct {
+		doc      string
+		expected []string
+	}{
+		{doc: "date: 2020-01-02\ntime: 2020-01-02T15:04:05Z\nversion: \"1.10\"\ncount: 0x10\n"},
+		{doc: "date: 2020-13-02\n", expected: []string{"/date format"}},
+		{doc: "version: 1.10\n", expected: []string{"/version type"}},
+		{doc: "count: 1.5\n", expected: []string{"/count type"}},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			got := pointers(s.ValidateBytes([]byte(test.doc)))
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected violations %q; got %q\n", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		doc      string
+		expected interface{}
+		err      bool
+	}{
+		{doc: "", expected: nil},
+		{doc: "d: 2020-01-02\n", expected: map[string]interface{}{"d": "2020-01-02"}},
+		{
+			doc:      "a: 0x1F\nb: 1.50\nc: 1e3\nd: true\ne: ~\nf: [1, two]\n",
+			expected: map[string]interface{}{"a": json.Number("31"), "b": json.Number("1.50"), "c": json.Number("1e3"), "d": true, "e": nil, "f": []interface{}{json.Number("1"), "two"}},
+		},
+		{doc: "1: one\n", expected: map[string]interface{}{"1": "one"}},
+		{
+			doc: "base: &base {a: 1, b: 1}\nother: &other {b: 2, c: 2}\nmerged:\n  <<: [*base, *other]\n  c: 3\ncopy: *base\n",
+			expected: map[string]interface{}{
+				"base":   map[string]interface{}{"a": json.Number("1"), "b": json.Number("1")},
+				"other":  map[string]interface{}{"b": json.Number("2"), "c": json.Number("2")},
+				"merged": map[string]interface{}{"a": json.Number("1"), "b": json.Number("1"), "c": json.Number("3")},
+				"copy":   map[string]interface{}{"a": json.Number("1"), "b": json.Number("1")},
+			},
+		},
+		{doc: "a: .inf\n", err: true},
+		{doc: "a: .nan\n", err: true},
+		{doc: "[a]: b\n", err: true},
+		{doc: "a: &a [*a]\n", err: true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			v, err := Decode([]byte(test.doc))
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %v; got %v\n", test.err, err)
+			}
+			if err != nil {
+				if !errors.Is(err, InvalidDocumentError) {
+					t.Errorf("expected an InvalidDocumentError; got %v\n", err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(v, test.expected) {
+				t.Errorf("expected %#v; got %#v\n", test.expected, v)
+			}
+		})
+	}
+}
+
+func TestSchema_Applicators(t *testing.T) {
+	tests := []struct {
+		schema   string
+		doc      string
+		expected []string
+	}{
+		{schema: `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, doc: `1`},
+		{schema: `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, doc: `1.5`, expected: []string{" anyOf"}},
+		{schema: `{"oneOf": [{"minimum": 1}, {"maximum": 5}]}`, doc: `3`, expected: []string{" oneOf"}},
+		{schema: `{"oneOf": [{"minimum": 1}, {"maximum": 5}]}`, doc: `7`},
+		{schema: `{"not": {"type": "null"}}`, doc: `null`, expected: []string{" not"}},
+		{schema: `{"allOf": [{"minLength": 2}, {"maxLength": 3}]}`, doc: `"abcd"`, expected: []string{" maxLength"}},
+		{
+			schema:   `{"if": {"properties": {"tls": {"const": true}}}, "then": {"required": ["cert"]}, "else": {"maxProperties": 1}}`,
+			doc:      `{"tls": true}`,
+			expected: []string{" required"},
+		},
+		{
+			schema:   `{"if": {"properties": {"tls": {"const": true}}}, "then": {"required": ["cert"]}, "else": {"maxProperties": 1}}`,
+			doc:      `{"tls": false, "port": 1}`,
+			expected: []string{" maxProperties"},
+		},
+		{
+			schema:   `{"patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": {"type": "integer"}}`,
+			doc:      `{"x-a": 1, "b": "c"}`,
+			expected: []string{"/b type", "/x-a type"},
+		},
+		{schema: `{"propertyNames": {"maxLength": 2}}`, doc: `{"abc": 1}`, expected: []string{"/abc maxLength"}},
+		{schema: `{"dependencies": {"a": ["b"]}}`, doc: `{"a": 1}`, expected: []string{" dependentRequired"}},
+		{schema: `{"dependentSchemas": {"a": {"required": ["c"]}}}`, doc: `{"a": 1}`, expected: []string{" required"}},
+		{schema: `{"prefixItems": [{"type": "string"}], "items": false}`, doc: `["a", 1]`, expected: []string{"/1 "}},
+		{schema: `{"items": [{"type": "string"}], "additionalItems": {"type": "integer"}}`, doc: `["a", 1]`},
+		{schema: `{"contains": {"const": 2}, "maxContains": 1}`, doc: `[2, 2]`, expected: []string{" maxContains"}},
+		{schema: `{"contains": {"const": 2}}`, doc: `[1]`, expected: []string{" contains"}},
+		{schema: `{"maximum": 5, "exclusiveMaximum": true}`, doc: `5`, expected: []string{" exclusiveMaximum"}},
+		{schema: `{"enum": [1, {"a": [true]}]}`, doc: `{"a": [true]}`},
+		{schema: `{"$ref": "#/definitions/node", "definitions": {"node": {"properties": {"next": {"$ref": "#/definitions/node"}}, "required": ["v"]}}}`,
+			doc: `{"v": 1, "next": {"next": {"v": 3}}}`, expected: []string{"/next required"}},
+		{schema: `false`, doc: `{}`, expected: []string{" "}},
+		{schema: `true`, doc: `{}`},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			s, err := Compile([]byte(test.schema))
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+			got := pointers(s.ValidateBytes([]byte(test.doc)))
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected violations %q; got %q\n", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		schema string
+		err    bool
+	}{
+		{schema: "type: object\nproperties:\n  port:\n    type: integer\n"},
+		{schema: `{"properties": {"a": 1}}`, err: true},
+		{schema: `{"pattern": "("}`, err: true},
+		{schema: `{"minLength": -1}`, err: true},
+		{schema: `{"required": "a"}`, err: true},
+		{schema: `{"$ref": "#/definitions/missing"}`, err: true},
+		{schema: `{"$ref": "https://example.com/schema.json"}`, err: true},
+		{schema: `{"$defs": {"bad": {"type": 5}}}`, err: true},
+		{schema: `[`, err: true},
+		{schema: `{"$defs": {"a": {"$ref": "#/$defs/b"}, "b": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`, err: true},
+		{schema: `{"$ref": "#"}`, err: true},
+		{schema: `{"allOf": [{"not": {"$ref": "#"}}]}`, err: true},
+		{schema: `{"properties": {"child": {"$ref": "#"}}}`},
+		{schema: `{"$defs": {"a": {"type": "string"}}, "allOf": [{"$ref": "#/$defs/a"}, {"$ref": "#/$defs/a"}]}`},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			_, err := Compile([]byte(test.schema))
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %v; got %v\n", test.err, err)
+			}
+			if err != nil && !errors.Is(err, InvalidSchemaError) {
+				t.Errorf("expected an InvalidSchemaError; got %v\n", err)
+			}
+		})
+	}
+}
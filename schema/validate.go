@@ -0,0 +1,362 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validate checks a document, as decoded by Decode or encoding/json, and returns a *ValidationError with every
+// violation, or nil if it matches the schema.
+func (s *Schema) Validate(doc interface{}) error {
+	var violations []Violation
+	s.root.validate(doc, "", &violations)
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// ValidateBytes decodes a JSON or YAML document and validates it.
+func (s *Schema) ValidateBytes(data []byte) error {
+	doc, err := Decode(data)
+	if err != nil {
+		return err
+	}
+	return s.Validate(doc)
+}
+
+func (n *node) valid(v interface{}, ptr string) bool {
+	var violations []Violation
+	n.validate(v, ptr, &violations)
+	return len(violations) == 0
+}
+
+func (n *node) validate(v interface{}, ptr string, out *[]Violation) {
+	fail := func(keyword, format string, args ...interface{}) {
+		*out = append(*out, Violation{
+			Pointer:       ptr,
+			Keyword:       keyword,
+			SchemaPointer: n.ptr + "/" + keyword,
+			Message:       fmt.Sprintf(format, args...),
+		})
+	}
+
+	if n.always != nil {
+		if !*n.always {
+			*out = append(*out, Violation{Pointer: ptr, SchemaPointer: n.ptr, Message: "no value is allowed here"})
+		}
+		return
+	}
+	if n.refNode != nil {
+		n.refNode.validate(v, ptr, out)
+	}
+
+	if len(n.types) > 0 && !matchesType(v, n.types) {
+		fail("type", "must be of type %s; got %s", strings.Join(n.types, " or "), typeOf(v))
+		// The other keywords would only repeat that the type is wrong.
+		return
+	}
+	if n.enum != nil && !contains(n.enum, v) {
+		fail("enum", "must be one of %s", marshal(n.enum))
+	}
+	if len(n.consts) > 0 && !equal(n.consts[0], v) {
+		fail("const", "must be %s", marshal(n.consts[0]))
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		n.validateObject(t, ptr, out, fail)
+	case []interface{}:
+		n.validateArray(t, ptr, out, fail)
+	case string:
+		n.validateString(t, fail)
+	case json.Number:
+		n.validateNumber(t, fail)
+	}
+
+	for _, s := range n.allOf {
+		s.validate(v, ptr, out)
+	}
+	if n.anyOf != nil {
+		matched := false
+		for _, s := range n.anyOf {
+			if s.valid(v, ptr) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("anyOf", "must match at least one of the schemas in anyOf")
+		}
+	}
+	if n.oneOf != nil {
+		matched := 0
+		for _, s := range n.oneOf {
+			if s.valid(v, ptr) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail("oneOf", "must match exactly one of the schemas in oneOf; matched %d", matched)
+		}
+	}
+	if n.not != nil && n.not.valid(v, ptr) {
+		fail("not", "must not match the schema in not")
+	}
+	if n.ifNode != nil {
+		if n.ifNode.valid(v, ptr) {
+			if n.then != nil {
+				n.then.validate(v, ptr, out)
+			}
+		} else if n.orElse != nil {
+			n.orElse.validate(v, ptr, out)
+		}
+	}
+}
+
+func (n *node) validateObject(m map[string]interface{}, ptr string, out *[]Violation,
+	fail func(string, string, ...interface{})) {
+	for _, name := range n.required {
+		if _, ok := m[name]; !ok {
+			fail("required", "missing required property %q", name)
+		}
+	}
+	if n.minProperties != nil && len(m) < *n.minProperties {
+		fail("minProperties", "must have at least %d properties", *n.minProperties)
+	}
+	if n.maxProperties != nil && len(m) > *n.maxProperties {
+		fail("maxProperties", "must have at most %d properties", *n.maxProperties)
+	}
+
+	for _, name := range sortedKeys(m) {
+		value := m[name]
+		valuePtr := ptr + "/" + escape(name)
+
+		if n.propertyNames != nil {
+			var violations []Violation
+			n.propertyNames.validate(name, valuePtr, &violations)
+			for _, v := range violations {
+				v.Message = "property name " + v.Message
+				*out = append(*out, v)
+			}
+		}
+		if required, ok := n.dependentRequired[name]; ok {
+			for _, r := range required {
+				if _, ok := m[r]; !ok {
+					fail("dependentRequired", "property %q is required when %q is present", r, name)
+				}
+			}
+		}
+		if s, ok := n.dependentSchemas[name]; ok {
+			s.validate(m, ptr, out)
+		}
+
+		matched := false
+		if s, ok := n.properties[name]; ok {
+			s.validate(value, valuePtr, out)
+			matched = true
+		}
+		for _, p := range n.patternProperties {
+			if p.re.MatchString(name) {
+				p.n.validate(value, valuePtr, out)
+				matched = true
+			}
+		}
+		if !matched && n.additionalProperties != nil {
+			if a := n.additionalProperties; a.always != nil && !*a.always {
+				*out = append(*out, Violation{
+					Pointer:       valuePtr,
+					Keyword:       "additionalProperties",
+					SchemaPointer: a.ptr,
+					Message:       "property is not allowed",
+				})
+			} else {
+				a.validate(value, valuePtr, out)
+			}
+		}
+	}
+}
+
+func (n *node) validateArray(a []interface{}, ptr string, out *[]Violation,
+	fail func(string, string, ...interface{})) {
+	if n.minItems != nil && len(a) < *n.minItems {
+		fail("minItems", "must have at least %d items", *n.minItems)
+	}
+	if n.maxItems != nil && len(a) > *n.maxItems {
+		fail("maxItems", "must have at most %d items", *n.maxItems)
+	}
+	if n.uniqueItems {
+	unique:
+		for i := range a {
+			for j := i + 1; j < len(a); j++ {
+				if equal(a[i], a[j]) {
+					fail("uniqueItems", "items %d and %d must not be equal", i, j)
+					break unique
+				}
+			}
+		}
+	}
+
+	for i, item := range a {
+		itemPtr := fmt.Sprintf("%s/%d", ptr, i)
+		if i < len(n.prefixItems) {
+			n.prefixItems[i].validate(item, itemPtr, out)
+		} else if n.items != nil {
+			n.items.validate(item, itemPtr, out)
+		}
+	}
+
+	if n.contains != nil {
+		matched := 0
+		for i, item := range a {
+			if n.contains.valid(item, fmt.Sprintf("%s/%d", ptr, i)) {
+				matched++
+			}
+		}
+		minContains := 1
+		if n.minContains != nil {
+			minContains = *n.minContains
+		}
+		if matched < minContains {
+			fail("contains", "must contain at least %d matching items; found %d", minContains, matched)
+		}
+		if n.maxContains != nil && matched > *n.maxContains {
+			fail("maxContains", "must contain at most %d matching items; found %d", *n.maxContains, matched)
+		}
+	}
+}
+
+func (n *node) validateString(s string, fail func(string, string, ...interface{})) {
+	length := utf8.RuneCountInString(s)
+	if n.minLength != nil && length < *n.minLength {
+		fail("minLength", "must be at least %d characters long", *n.minLength)
+	}
+	if n.maxLength != nil && length > *n.maxLength {
+		fail("maxLength", "must be at most %d characters long", *n.maxLength)
+	}
+	if n.pattern != nil && !n.pattern.MatchString(s) {
+		fail("pattern", "must match the pattern %q", n.pattern.String())
+	}
+	if check, ok := formats[n.format]; ok && !check(s) {
+		fail("format", "must be a valid %s", n.format)
+	}
+}
+
+func (n *node) validateNumber(num json.Number, fail func(string, string, ...interface{})) {
+	r, ok := rat(num)
+	if !ok {
+		return
+	}
+	if n.minimum != nil && r.Cmp(n.minimum) < 0 {
+		fail("minimum", "must be at least %s", decimal(n.minimum))
+	}
+	if n.maximum != nil && r.Cmp(n.maximum) > 0 {
+		fail("maximum", "must be at most %s", decimal(n.maximum))
+	}
+	if n.exclusiveMinimum != nil && r.Cmp(n.exclusiveMinimum) <= 0 {
+		fail("exclusiveMinimum", "must be greater than %s", decimal(n.exclusiveMinimum))
+	}
+	if n.exclusiveMaximum != nil && r.Cmp(n.exclusiveMaximum) >= 0 {
+		fail("exclusiveMaximum", "must be less than %s", decimal(n.exclusiveMaximum))
+	}
+	if n.multipleOf != nil && !new(big.Rat).Quo(r, n.multipleOf).IsInt() {
+		fail("multipleOf", "must be a multiple of %s", decimal(n.multipleOf))
+	}
+}
+
+func typeOf(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if r, ok := rat(t); ok && r.IsInt() {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func matchesType(v interface{}, types []string) bool {
+	actual := typeOf(v)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []interface{}, v interface{}) bool {
+	for _, e := range values {
+		if equal(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares two decoded values, with numbers compared by value so 1 and 1.0 are equal.
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		rx, okx := rat(x)
+		ry, oky := rat(y)
+		return okx && oky && rx.Cmp(ry) == 0
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func marshal(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// decimal formats a number for a message, like 0.5 rather than 1/2.
+func decimal(r *big.Rat) string {
+	if r.IsInt() {
+		return r.RatString()
+	}
+	return strings.TrimRight(strings.TrimRight(r.FloatString(10), "0"), ".")
+}
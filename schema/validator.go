@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// NoSchemaError is returned by a Validator which has no schema yet, so nothing is accepted before the schema is
+	// loaded.
+	NoSchemaError = errors.New("[gosprout] no schema has been loaded to validate with")
+)
+
+// Validator holds the schema documents are validated with. The schema can be replaced while in use, e.g. by
+// watching the resource holding it with gosprout.UpdateSchema.
+type Validator struct {
+	mu     *sync.RWMutex
+	schema *Schema
+}
+
+// NewValidator creates a validator with the schema, which may be nil if it will be loaded by gosprout.UpdateSchema.
+func NewValidator(s *Schema) *Validator {
+	return &Validator{mu: &sync.RWMutex{}, schema: s}
+}
+
+// Schema returns the current schema, or nil.
+func (v *Validator) Schema() *Schema {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.schema
+}
+
+// SetSchema replaces the schema.
+func (v *Validator) SetSchema(s *Schema) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.schema = s
+}
+
+// Validate validates a decoded document with the current schema.
+func (v *Validator) Validate(doc interface{}) error {
+	s := v.Schema()
+	if s == nil {
+		return NoSchemaError
+	}
+	return s.Validate(doc)
+}
+
+// ValidateBytes decodes a JSON or YAML document and validates it with the current schema.
+func (v *Validator) ValidateBytes(data []byte) error {
+	s := v.Schema()
+	if s == nil {
+		return NoSchemaError
+	}
+	return s.ValidateBytes(data)
+}
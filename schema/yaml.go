@@ -0,0 +1,149 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"math"
+	"strconv"
+)
+
+// decodeYAML decodes a YAML document into the same types as decodeJSON. It works from the nodes rather than the
+// Go values yaml.v3 produces, so a scalar keeps the text it was written with where JSON has no equivalent type,
+// e.g. a date stays the string "2020-01-02" instead of becoming a time.Time.
+func decodeYAML(data []byte) (interface{}, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidDocumentError, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	// Aliases may expand a document far beyond its size, so that is limited.
+	d := &yamlDecoder{budget: 1000 + 10*len(data)}
+	v, err := d.decode(doc.Content[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidDocumentError, err)
+	}
+	return v, nil
+}
+
+type yamlDecoder struct {
+	budget int
+}
+
+func (d *yamlDecoder) decode(n *yaml.Node) (interface{}, error) {
+	if d.budget--; d.budget < 0 {
+		return nil, fmt.Errorf("line %d: the aliases expand to too many values", n.Line)
+	}
+	switch n.Kind {
+	case yaml.AliasNode:
+		return d.decode(n.Alias)
+	case yaml.DocumentNode:
+		return d.decode(n.Content[0])
+	case yaml.SequenceNode:
+		a := make([]interface{}, 0, len(n.Content))
+		for _, item := range n.Content {
+			v, err := d.decode(item)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		return a, nil
+	case yaml.MappingNode:
+		m := map[string]interface{}{}
+		return m, d.decodeMapping(n, m)
+	}
+	return decodeScalar(n)
+}
+
+// decodeMapping adds the pairs of a mapping to m. Keys merged in with "<<" are added first, so the mapping's own
+// keys take precedence over them.
+func (d *yamlDecoder) decodeMapping(n *yaml.Node, m map[string]interface{}) error {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Kind != yaml.ScalarNode || key.ShortTag() != "!!merge" {
+			continue
+		}
+		merges := []*yaml.Node{value}
+		if resolveAlias(value).Kind == yaml.SequenceNode {
+			merges = resolveAlias(value).Content
+		}
+		// The first of several merged mappings takes precedence, so it is added last.
+		for j := len(merges) - 1; j >= 0; j-- {
+			merge := merges[j]
+			if merge = resolveAlias(merge); merge.Kind != yaml.MappingNode {
+				return fmt.Errorf("line %d: only mappings can be merged", merge.Line)
+			}
+			if err := d.decodeMapping(merge, m); err != nil {
+				return err
+			}
+		}
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := resolveAlias(n.Content[i]), n.Content[i+1]
+		if key.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: a key must be a scalar", key.Line)
+		}
+		if key.ShortTag() == "!!merge" {
+			continue
+		}
+		v, err := d.decode(value)
+		if err != nil {
+			return err
+		}
+		m[key.Value] = v
+	}
+	return nil
+}
+
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
+
+// decodeScalar converts a scalar by its tag. Numbers become json.Number, and types JSON does not have, like
+// timestamps and binary data, keep their text.
+func decodeScalar(n *yaml.Node) (interface{}, error) {
+	switch n.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		if err := n.Decode(&b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	case "!!int":
+		if isJSONNumber(n.Value) {
+			return json.Number(n.Value), nil
+		}
+		var i interface{}
+		if err := n.Decode(&i); err != nil {
+			return nil, err
+		}
+		return json.Number(fmt.Sprint(i)), nil
+	case "!!float":
+		if isJSONNumber(n.Value) {
+			return json.Number(n.Value), nil
+		}
+		var f float64
+		if err := n.Decode(&f); err != nil {
+			return nil, err
+		}
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, fmt.Errorf("line %d: %s cannot be represented in JSON", n.Line, n.Value)
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	}
+	return n.Value, nil
+}
+
+// isJSONNumber reports whether s is written the way JSON writes numbers.
+func isJSONNumber(s string) bool {
+	var n json.Number
+	return json.Unmarshal([]byte(s), &n) == nil && s != "" && s[0] != '"'
+}
@@ -2,8 +2,15 @@ package gosprout
 
 import (
 	"encoding/json"
+	"fmt"
+	"github.com/pelletier/go-toml"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
 	"io"
+	"io/ioutil"
 	"log"
+	"reflect"
+	"strings"
 	"sync"
 )
 
@@ -27,6 +34,16 @@ func SetErrorHandler(errorHandler ErrorHandler) {
 	DefaultErrorHandler = errorHandler
 }
 
+// reportError gives the error to the error handler, or to the DefaultErrorHandler if there is none. The default is
+// looked up when the error is reported, so it can be set after an update func is created.
+func reportError(errorHandler ErrorHandler, e error) {
+	if errorHandler != nil {
+		errorHandler(e)
+	} else if DefaultErrorHandler != nil {
+		DefaultErrorHandler(e)
+	}
+}
+
 // WriteUpdate is a simple UpdateFunction. This will take in a provided writer which will be written to with the data
 // from the resource specified. Closing will be handleded by the caller.
 func WriteUpdate(w io.Writer) UpdateFunction {
@@ -38,15 +55,158 @@ func WriteUpdate(w io.Writer) UpdateFunction {
 	}
 }
 
+// DecodeOption changes how the UpdateFrom functions decode and report errors.
+type DecodeOption func(*decodeOptions)
+
+type decodeOptions struct {
+	strict       bool
+	replace      bool
+	errorHandler ErrorHandler
+}
+
+// Strict makes decoding fail on fields which do not exist in the target, instead of ignoring them.
+func Strict() DecodeOption {
+	return func(o *decodeOptions) {
+		o.strict = true
+	}
+}
+
+// Replace decodes the data into a new value, which replaces the current one only if decoding succeeds. Without it,
+// the data is decoded into the current value, so defaults and fields missing from the data are kept, but a failed
+// decode can leave the value partly updated.
+func Replace() DecodeOption {
+	return func(o *decodeOptions) {
+		o.replace = true
+	}
+}
+
+// WithErrorHandler reports decoding errors to the error handler instead of the DefaultErrorHandler.
+func WithErrorHandler(errorHandler ErrorHandler) DecodeOption {
+	return func(o *decodeOptions) {
+		o.errorHandler = errorHandler
+	}
+}
+
 // Provided a container object which is able to be locked, this function
 // will lock, update the data in the underlying pointer, and unlock. The
 // locking is necessary so this can be done without worry about concurrent access panics.
-func UpdateFromJson(s Serializer) UpdateFunction {
+//
+// The data is decoded into the current value, unless the Replace option is given. Errors are given to the
+// DefaultErrorHandler, unless another one is provided with WithErrorHandler.
+func UpdateFromJson(s Serializer, opts ...DecodeOption) UpdateFunction {
+	return updateFrom(s, "json", func(r io.Reader, v interface{}, strict bool) error {
+		d := json.NewDecoder(r)
+		if strict {
+			d.DisallowUnknownFields()
+		}
+		return d.Decode(v)
+	}, opts)
+}
+
+// UpdateFromYaml is the same as UpdateFromJson, for YAML data.
+func UpdateFromYaml(s Serializer, opts ...DecodeOption) UpdateFunction {
+	return updateFrom(s, "yaml", func(r io.Reader, v interface{}, strict bool) error {
+		d := yaml.NewDecoder(r)
+		d.KnownFields(strict)
+		return d.Decode(v)
+	}, opts)
+}
+
+// UpdateFromToml is the same as UpdateFromJson, for TOML data.
+func UpdateFromToml(s Serializer, opts ...DecodeOption) UpdateFunction {
+	return updateFrom(s, "toml", func(r io.Reader, v interface{}, strict bool) error {
+		return toml.NewDecoder(r).Strict(strict).Decode(v)
+	}, opts)
+}
+
+// UpdateFromIni is the same as UpdateFromJson, for INI data. The pointer must be to a struct, where sections map
+// to struct fields as described by gopkg.in/ini.v1. In strict mode, values which cannot be parsed are errors too.
+func UpdateFromIni(s Serializer, opts ...DecodeOption) UpdateFunction {
+	return updateFrom(s, "ini", func(r io.Reader, v interface{}, strict bool) error {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		f, err := ini.Load(b)
+		if err != nil {
+			return err
+		}
+		if !strict {
+			return f.MapTo(v)
+		}
+		if err := checkIniFields(f, v); err != nil {
+			return err
+		}
+		return f.StrictMapTo(v)
+	}, opts)
+}
+
+func updateFrom(s Serializer, format string, decode func(io.Reader, interface{}, bool) error, opts []DecodeOption) UpdateFunction {
+	o := decodeOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
 	return func(r io.Reader) {
-		p := s.Pointer()
-		e := json.NewDecoder(r).Decode(p)
-		if e != nil {
-			log.Printf("[gosprout] failed to decode json object: %v\n", e)
+		err := decodeValue(s.Pointer(), s, o.replace, func(v interface{}) error {
+			if e := decode(r, v, o.strict); e != nil {
+				return fmt.Errorf("[gosprout] failed to decode %s object: %w", format, e)
+			}
+			return nil
+		})
+		if err != nil {
+			reportError(o.errorHandler, err)
+		}
+	}
+}
+
+// checkIniFields returns an error for the first section or key which has no field in the struct v points to.
+func checkIniFields(f *ini.File, v interface{}) error {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot map ini to %s", t)
+	}
+	for _, section := range f.Sections() {
+		st := t
+		if section.Name() != ini.DefaultSection {
+			field, ok := iniField(t, section.Name())
+			if !ok {
+				return fmt.Errorf("unknown section %q", section.Name())
+			}
+			st = field.Type
+			for st.Kind() == reflect.Ptr {
+				st = st.Elem()
+			}
+			if st.Kind() != reflect.Struct {
+				return fmt.Errorf("section %q is not a struct field", section.Name())
+			}
+		}
+		for _, key := range section.Keys() {
+			if _, ok := iniField(st, key.Name()); !ok {
+				return fmt.Errorf("unknown key %q in section %q", key.Name(), section.Name())
+			}
+		}
+	}
+	return nil
+}
+
+// iniField finds the exported field for an ini name, by its `ini` tag or its name, like gopkg.in/ini.v1 does.
+func iniField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("ini")
+		if field.PkgPath != "" || tag == "-" {
+			continue
+		}
+		fieldName := strings.Split(tag, ",")[0]
+		if fieldName == "" {
+			fieldName = field.Name
+		}
+		if fieldName == name {
+			return field, true
 		}
 	}
+	return reflect.StructField{}, false
 }
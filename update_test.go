@@ -11,6 +11,7 @@ import (
 )
 
 func TestSetErrorHandler(t *testing.T) {
+	defer SetErrorHandler(DefaultErrorHandler)
 	f := func(c chan error) func(error) {
 		return func(e error) {
 			c <- e
@@ -127,3 +128,120 @@ func TestUpdateFromJson(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateFrom(t *testing.T) {
+	type server struct {
+		Host string `json:"host" yaml:"host" toml:"host" ini:"host"`
+		Port int    `json:"port" yaml:"port" toml:"port" ini:"port"`
+	}
+	type config struct {
+		Name   string `json:"name" yaml:"name" toml:"name" ini:"name"`
+		Server server `json:"server" yaml:"server" toml:"server" ini:"server"`
+	}
+	expected := config{Name: "app", Server: server{Host: "localhost", Port: 8080}}
+
+	tests := []struct {
+		update func(Serializer, ...DecodeOption) UpdateFunction
+		data   string
+		strict bool
+		err    bool
+	}{
+		{update: UpdateFromJson, data: `{"name":"app","server":{"host":"localhost","port":8080},"extra":1}`},
+		{update: UpdateFromJson, data: `{"name":"app","server":{"host":"localhost","port":8080},"extra":1}`, strict: true, err: true},
+		{update: UpdateFromYaml, data: "name: app\nserver:\n  host: localhost\n  port: 8080\nextra: 1\n"},
+		{update: UpdateFromYaml, data: "name: app\nserver:\n  host: localhost\n  port: 8080\nextra: 1\n", strict: true, err: true},
+		{update: UpdateFromYaml, data: "name: app\nserver:\n  host: localhost\n  port: 8080\n", strict: true},
+		{update: UpdateFromYaml, data: "name: [", err: true},
+		{update: UpdateFromToml, data: "name = \"app\"\nextra = 1\n[server]\nhost = \"localhost\"\nport = 8080\n"},
+		{update: UpdateFromToml, data: "name = \"app\"\nextra = 1\n[server]\nhost = \"localhost\"\nport = 8080\n", strict: true, err: true},
+		{update: UpdateFromToml, data: "name = \"app\"\n[server]\nhost = \"localhost\"\nport = 8080\n", strict: true},
+		{update: UpdateFromIni, data: "name = app\nextra = 1\n[server]\nhost = localhost\nport = 8080\n"},
+		{update: UpdateFromIni, data: "name = app\nextra = 1\n[server]\nhost = localhost\nport = 8080\n", strict: true, err: true},
+		{update: UpdateFromIni, data: "name = app\n[server]\nhost = localhost\nport = 8080\n[other]\n", strict: true, err: true},
+		{update: UpdateFromIni, data: "name = app\n[server]\nhost = localhost\nport = eighty\n", strict: true, err: true},
+		{update: UpdateFromIni, data: "name = app\n[server]\nhost = localhost\nport = 8080\n", strict: true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			c := container{
+				data: &config{Name: "old"},
+				mu:   &sync.Mutex{},
+			}
+			var errs []error
+			opts := []DecodeOption{Replace(), WithErrorHandler(func(e error) { errs = append(errs, e) })}
+			if test.strict {
+				opts = append(opts, Strict())
+			}
+
+			test.update(c, opts...)(strings.NewReader(test.data))
+
+			actual := *c.data.(*config)
+			if test.err {
+				if len(errs) != 1 {
+					t.Errorf("expected an error, but got %v\n", errs)
+				}
+				if actual.Name != "old" {
+					t.Errorf("expected the object to be unchanged, but got %v\n", actual)
+				}
+				return
+			}
+			if len(errs) != 0 {
+				t.Errorf("unexpected errors: %v\n", errs)
+			}
+			if actual != expected {
+				t.Errorf("expected %v, but got %v\n", expected, actual)
+			}
+		})
+	}
+}
+
+func TestUpdateFrom_InPlace(t *testing.T) {
+	type config struct {
+		Name    string `json:"name" yaml:"name"`
+		Port    int    `json:"port" yaml:"port"`
+		Timeout int    `json:"timeout" yaml:"timeout"`
+	}
+
+	tests := []struct {
+		update   func(Serializer, ...DecodeOption) UpdateFunction
+		data     string
+		opts     []DecodeOption
+		expected config
+	}{
+		{update: UpdateFromJson, data: `{"port": 8080}`, expected: config{Name: "app", Port: 8080, Timeout: 30}},
+		{update: UpdateFromYaml, data: "port: 8080\n", expected: config{Name: "app", Port: 8080, Timeout: 30}},
+		{update: UpdateFromJson, data: `{"port": 8080}`, opts: []DecodeOption{Replace()}, expected: config{Port: 8080}},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			c := container{
+				data: &config{Name: "app", Timeout: 30},
+				mu:   &sync.Mutex{},
+			}
+			test.update(c, test.opts...)(strings.NewReader(test.data))
+			if actual := *c.data.(*config); actual != test.expected {
+				t.Errorf("expected %v, but got %v\n", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestUpdateFrom_DefaultErrorHandler(t *testing.T) {
+	defer SetErrorHandler(DefaultErrorHandler)
+	c := container{
+		data: &struct{}{},
+		mu:   &sync.Mutex{},
+	}
+	update := UpdateFromJson(c)
+
+	var errs []error
+	SetErrorHandler(func(e error) {
+		errs = append(errs, e)
+	})
+	update(strings.NewReader("not json"))
+	if len(errs) != 1 {
+		t.Errorf("expected the error handler set after the update func was created to get the error; got %v\n", errs)
+	}
+}
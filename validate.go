@@ -0,0 +1,100 @@
+package gosprout
+
+import (
+	"bytes"
+	"github.com/fire00f1y/go-sprout/resource"
+	"github.com/fire00f1y/go-sprout/resource/decompress"
+	"github.com/fire00f1y/go-sprout/schema"
+	"io"
+	"io/ioutil"
+)
+
+var (
+	defaultDocumentMaxSize int64 = 100 << 20
+)
+
+// ValidateConfig controls how UpdateValidated and UpdateSchema read and report documents.
+type ValidateConfig struct {
+	// MaxSize is the maximum size in bytes of the document, after decompression. It defaults to 100 MiB, and a
+	// negative value means there is no limit.
+	MaxSize int64
+	// ErrorHandler is given the errors, instead of the DefaultErrorHandler.
+	ErrorHandler ErrorHandler
+}
+
+// UpdateValidated validates each JSON or YAML document with the validator's current schema before passing it on to
+// the update func, like one from UpdateInto. A document which does not match is not applied, and the error handler
+// is given a *schema.ValidationError with every violation and the JSON pointer of each invalid value, so the
+// current config stays in place.
+//
+// The schema can itself be watched, with UpdateSchema as the update func of its resource. A new schema applies to
+// the documents refreshed after it is loaded, and documents are rejected with schema.NoSchemaError until there is
+// one.
+func UpdateValidated(v *schema.Validator, update UpdateFunction, cfg ValidateConfig) UpdateFunction {
+	return func(r io.Reader) {
+		if err := validated(v, r, cfg, update); err != nil {
+			reportError(cfg.ErrorHandler, err)
+		}
+	}
+}
+
+// UpdateSchema compiles each document as the validator's new schema. A schema which does not compile is given to the
+// error handler as a schema.InvalidSchemaError, and the validator keeps its current schema.
+func UpdateSchema(v *schema.Validator, cfg ValidateConfig) UpdateFunction {
+	return func(r io.Reader) {
+		if err := compiled(v, r, cfg); err != nil {
+			reportError(cfg.ErrorHandler, err)
+		}
+	}
+}
+
+func compiled(v *schema.Validator, r io.Reader, cfg ValidateConfig) error {
+	data, _, err := readDocument(r, cfg.MaxSize)
+	if err != nil {
+		return err
+	}
+	s, err := schema.Compile(data)
+	if err != nil {
+		return err
+	}
+	v.SetSchema(s)
+	return nil
+}
+
+func validated(v *schema.Validator, r io.Reader, cfg ValidateConfig, update UpdateFunction) error {
+	data, m, err := readDocument(r, cfg.MaxSize)
+	if err != nil {
+		return err
+	}
+	if err := v.ValidateBytes(data); err != nil {
+		return err
+	}
+	update(resource.WithMetadata(bytes.NewReader(data), m))
+	return nil
+}
+
+// readDocument decompresses and reads a document. The decompress package only limits the size of compressed data,
+// so the limit is applied here as well for a document which is not compressed.
+func readDocument(r io.Reader, maxSize int64) ([]byte, resource.Metadata, error) {
+	if maxSize == 0 {
+		maxSize = defaultDocumentMaxSize
+	}
+	dr, err := decompress.NewReader(r, decompress.Config{MaxSize: maxSize})
+	if err != nil {
+		return nil, resource.Metadata{}, err
+	}
+	defer dr.Close()
+	m, _ := resource.MetadataOf(dr)
+	if maxSize < 0 {
+		data, err := ioutil.ReadAll(dr)
+		return data, m, err
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(dr, maxSize+1))
+	if err != nil {
+		return nil, m, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, m, decompress.SizeLimitError
+	}
+	return data, m, nil
+}
@@ -0,0 +1,162 @@
+package gosprout
+
+import (
+	"errors"
+	"github.com/fire00f1y/go-sprout/resource"
+	"github.com/fire00f1y/go-sprout/resource/decompress"
+	"github.com/fire00f1y/go-sprout/schema"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUpdateValidated(t *testing.T) {
+	v := schema.NewValidator(schema.MustCompile([]byte(`{
+		"type": "object",
+		"required": ["name", "port"],
+		"properties": {"name": {"type": "string"}, "port": {"type": "integer", "maximum": 65535}}
+	}`)))
+
+	tests := []struct {
+		data     string
+		metadata resource.Metadata
+		expected decodeConfig
+		pointers []string
+	}{
+		{
+			data:     `{"name": "app", "port": 8080, "debug": true}`,
+			expected: decodeConfig{Name: "app", Port: 8080, Debug: true},
+		},
+		{
+			data:     "name: app\nport: 443\n",
+			metadata: resource.Metadata{ContentType: "application/yaml"},
+			expected: decodeConfig{Name: "app", Port: 443},
+		},
+		{
+			data:     `{"name": 1, "port": 70000}`,
+			pointers: []string{"/name", "/port"},
+		},
+		{
+			data:     "debug: true\n",
+			metadata: resource.Metadata{ContentType: "application/yaml"},
+			pointers: []string{"", ""},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			cfg := &lockedConfig{}
+			var errs []error
+			update := UpdateValidated(v, UpdateInto(cfg), ValidateConfig{
+				ErrorHandler: func(e error) {
+					errs = append(errs, e)
+				},
+			})
+			update(resource.WithMetadata(strings.NewReader(test.data), test.metadata))
+
+			if test.pointers == nil {
+				if len(errs) > 0 {
+					t.Fatalf("unexpected errors: %v\n", errs)
+				}
+				if cfg.cfg != test.expected {
+					t.Errorf("expected %v; got %v\n", test.expected, cfg.cfg)
+				}
+				return
+			}
+			var validationErr *schema.ValidationError
+			if len(errs) != 1 || !errors.As(errs[0], &validationErr) {
+				t.Fatalf("expected a *schema.ValidationError; got %v\n", errs)
+			}
+			var pointers []string
+			for _, violation := range validationErr.Violations {
+				pointers = append(pointers, violation.Pointer)
+			}
+			if !reflect.DeepEqual(pointers, test.pointers) {
+				t.Errorf("expected violations at %q; got %q\n", test.pointers, pointers)
+			}
+			if cfg.cfg != (decodeConfig{}) {
+				t.Errorf("expected the config to be left alone; got %v\n", cfg.cfg)
+			}
+		})
+	}
+}
+
+func TestUpdateValidated_NoSchema(t *testing.T) {
+	var got error
+	update := UpdateValidated(schema.NewValidator(nil), WriteUpdate(&strings.Builder{}), ValidateConfig{
+		ErrorHandler: func(e error) {
+			got = e
+		},
+	})
+	update(strings.NewReader(`{}`))
+	if got != schema.NoSchemaError {
+		t.Errorf("expected %v; got %v\n", schema.NoSchemaError, got)
+	}
+}
+
+func TestUpdateSchema(t *testing.T) {
+	defer SetErrorHandler(DefaultErrorHandler)
+	var errs []error
+	SetErrorHandler(func(e error) {
+		errs = append(errs, e)
+	})
+
+	v := schema.NewValidator(nil)
+	update := UpdateSchema(v, ValidateConfig{})
+	update(strings.NewReader(`{"required": ["port"]}`))
+	if err := v.ValidateBytes([]byte(`{}`)); err == nil || !strings.Contains(err.Error(), `missing required property "port"`) {
+		t.Errorf("expected the port to be required; got %v\n", err)
+	}
+
+	update(strings.NewReader(`{"type": 1}`))
+	if len(errs) != 1 || !errors.Is(errs[0], schema.InvalidSchemaError) {
+		t.Errorf("expected the default error handler to get an InvalidSchemaError; got %v\n", errs)
+	}
+	if err := v.ValidateBytes([]byte(`{"port": 1}`)); err != nil {
+		t.Errorf("expected the previous schema to be kept; got %v\n", err)
+	}
+}
+
+func TestUpdateValidated_MaxSize(t *testing.T) {
+	v := schema.NewValidator(schema.MustCompile([]byte(`{"type": "object"}`)))
+	doc := `{"name": "app", "port": 8080}`
+
+	tests := []struct {
+		maxSize int64
+		err     error
+	}{
+		{maxSize: int64(len(doc))},
+		{maxSize: int64(len(doc)) - 1, err: decompress.SizeLimitError},
+		{maxSize: -1},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var errs []error
+			cfg := ValidateConfig{
+				MaxSize: test.maxSize,
+				ErrorHandler: func(e error) {
+					errs = append(errs, e)
+				},
+			}
+			applied := false
+			UpdateValidated(v, func(io.Reader) { applied = true }, cfg)(strings.NewReader(doc))
+			UpdateSchema(schema.NewValidator(nil), cfg)(strings.NewReader(doc))
+
+			if test.err == nil {
+				if len(errs) != 0 || !applied {
+					t.Errorf("expected the document to be applied; got %v\n", errs)
+				}
+				return
+			}
+			if len(errs) != 2 || errs[0] != test.err || errs[1] != test.err {
+				t.Errorf("expected %v for the document and the schema; got %v\n", test.err, errs)
+			}
+			if applied {
+				t.Errorf("expected the oversized document not to be applied\n")
+			}
+		})
+	}
+}